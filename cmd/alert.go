@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"indexer/pkg/alert"
+	"indexer/pkg/config"
+	"indexer/pkg/indexer"
+	"log"
+	"time"
+)
+
+// creates the alerting engine, nil when no webhook is configured
+func newAlertEngine(cfg config.AppCfg) *alert.Engine {
+	if cfg.Alert.WebhookURL == "" {
+		return nil
+	}
+	return alert.New(alert.NewWebhook(cfg.Alert.WebhookURL), cfg.Alert.GasMinEpochs+1,
+		alert.MissedSlotStreak{Threshold: cfg.Alert.MissedSlotStreak, SlotsPerEpoch: cfg.Chain.SlotsPerEpoch},
+		alert.ReorgDepth{Depth: cfg.Alert.ReorgDepth},
+		alert.EpochStall{After: cfg.Alert.EpochStall},
+		alert.ValidatorMissedDuty{},
+		alert.GasAnomaly{ZScore: cfg.Alert.GasZScore, MinEpochs: cfg.Alert.GasMinEpochs},
+	)
+}
+
+// feeds reorgs & the passing of time to the alerting engine
+func watchForAlerts(ctx context.Context, engine *alert.Engine, reorgStream <-chan indexer.ReorgResult) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := engine.Tick(ctx)
+			if err != nil {
+				log.Printf("engine.Tick() failed, err: %v\n", err.Error())
+			}
+		case reorgResult, ok := <-reorgStream:
+			if !ok {
+				reorgStream = nil
+				continue
+			}
+			if reorgResult.Error != nil {
+				log.Printf("reorg subscription failed, err: %v\n", reorgResult.Error.Error())
+				continue
+			}
+			err := engine.ObserveReorg(ctx, *reorgResult.Reorg)
+			if err != nil {
+				log.Printf("engine.ObserveReorg() failed, err: %v\n", err.Error())
+			}
+		}
+	}
+}
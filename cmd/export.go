@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"indexer/pkg/models"
+	"indexer/pkg/store"
+	"log"
+	"os"
+)
+
+const (
+	exportUsage = "usage: indexer export [-from epoch] [-to epoch] <file, - for stdout>"
+	importUsage = "usage: indexer import [-from epoch] [-to epoch] [-conflict skip|overwrite|version] <file, - for stdin>"
+)
+
+// writes the stored epochs of a range to a file as an export, see store.Export
+func exportIndex(ctx context.Context, repo store.Repository, args []string) {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	from := flags.Uint64("from", 0, "first epoch exported")
+	to := flags.Uint64("to", 0, "last epoch exported, 0 for the latest")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		log.Fatalln(exportUsage)
+	}
+
+	out := os.Stdout
+	if path := flags.Arg(0); path != "-" {
+		f, err := os.Create(path)
+		if err != nil {
+			log.Fatalf("os.Create() failed, err: %v\n", err.Error())
+		}
+		defer f.Close()
+		out = f
+	}
+	trailer, err := store.Export(ctx, repo, out, models.RangeQuery{From: *from, To: *to})
+	if err != nil {
+		log.Fatalf("store.Export() failed, err: %v\n", err.Error())
+	}
+	if out != os.Stdout {
+		err = out.Sync()
+		if err != nil {
+			log.Fatalf("export sync failed, err: %v\n", err.Error())
+		}
+	}
+	log.Printf("export complete, %d epochs, sha256 %s\n", trailer.Epochs, trailer.SHA256)
+}
+
+// writes the epochs of an export within a range to the store, epochs stored before are skipped unless another
+// conflict policy is given, nothing is written from an export which fails its checksum
+func importIndex(ctx context.Context, repo store.Repository, args []string) {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	from := flags.Uint64("from", 0, "first epoch imported")
+	to := flags.Uint64("to", 0, "last epoch imported, 0 for the last one of the export")
+	conflict := flags.String("conflict", "skip", "conflict policy of epochs stored before, skip, overwrite or version")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		log.Fatalln(importUsage)
+	}
+	policy, err := models.ParseConflictPolicy(*conflict)
+	if err != nil {
+		log.Fatalf("models.ParseConflictPolicy() failed, err: %v\n", err.Error())
+	}
+
+	in := os.Stdin
+	if path := flags.Arg(0); path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			log.Fatalf("os.Open() failed, err: %v\n", err.Error())
+		}
+		defer f.Close()
+		in = f
+	}
+	result, err := store.Import(ctx, repo, in, models.RangeQuery{From: *from, To: *to}, policy)
+	if err != nil {
+		log.Fatalf("store.Import() failed, err: %v\n", err.Error())
+	}
+	log.Printf("import complete, %d rows inserted, %d updated & %d skipped\n", result.Inserted, result.Updated, result.Skipped)
+}
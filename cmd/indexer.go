@@ -2,12 +2,19 @@ package main
 
 import (
 	"context"
+	"fmt"
+	"indexer/pkg/builders"
 	"indexer/pkg/config"
+	"indexer/pkg/contracts"
 	"indexer/pkg/db"
+	"indexer/pkg/execution"
 	"indexer/pkg/handler"
 	"indexer/pkg/indexer"
+	"indexer/pkg/models"
+	"indexer/pkg/sink"
 	"indexer/pkg/store"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -16,32 +23,85 @@ import (
 )
 
 func main() {
+	os.Exit(start())
+}
+
+// runs the sub command if any, serves otherwise, the exit status is returned so that deferred calls, e.g. closing
+// the store, run before the process exits
+func start() int {
 
 	// initialize a cancellable context
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	// parse app's config
-	cfg, err := config.Parse()
+	// parse app's config, of the sub command if any
+	var command string
+	if len(os.Args) > 1 {
+		command = os.Args[1]
+	}
+	cfg, err := config.Parse(command)
 	if err != nil {
 		log.Fatalf("config.Parse() failed, err: %v\n", err.Error())
 	}
 
-	// connect database
-	pool, err := db.Connect(ctx, cfg.Postgres)
-	if err != nil {
-		log.Fatalf("db.Connect() failed, err: %v\n", err.Error())
+	// schema management runs on its own, before the store is migrated to the latest version when opened
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		migrate(cfg, os.Args[2:])
+		return 0
 	}
-	defer pool.Close()
 
-	// run migrations
-	err = db.Migrate(cfg.Postgres)
+	// connect & migrate the configured database
+	repo, feed, closeStore, err := openStore(ctx, cfg)
 	if err != nil {
-		log.Fatalf("db.Migrate() failed, err: %v\n", err.Error())
+		log.Fatalf("openStore() failed, err: %v\n", err.Error())
+	}
+	defer closeStore()
+
+	// create execution layer client for receipt enrichment, contract event decoder & builder classifier, if configured
+	enricher := &enricher{}
+	if cfg.ExecutionURL != "" {
+		enricher.executionClient = execution.New(cfg.ExecutionURL)
+	}
+	if cfg.ContractsFile != "" {
+		if cfg.ExecutionURL == "" {
+			log.Fatalln("CONTRACTS_FILE requires EXECUTION_URL to be set")
+		}
+		enricher.decoder, err = contracts.Load(cfg.ContractsFile)
+		if err != nil {
+			log.Fatalf("contracts.Load() failed, err: %v\n", err.Error())
+		}
+	}
+	if cfg.BuildersFile != "" {
+		// without an execution client only listed builders are recognised
+		var transactions builders.Transactions
+		if enricher.executionClient != nil {
+			transactions = enricher.executionClient
+		}
+		enricher.classifier, err = builders.Load(cfg.BuildersFile, transactions)
+		if err != nil {
+			log.Fatalf("builders.Load() failed, err: %v\n", err.Error())
+		}
 	}
 
-	// create data store
-	repo := store.New(pool)
+	// run sub command if any, serve otherwise
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "ingest":
+			ingest(ctx, repo, enricher, cfg.Chain, os.Args[2:])
+			return 0
+		case "verify":
+			return verifyRange(ctx, repo, enricher, cfg.ClientURL, os.Args[2:])
+		case "export":
+			exportIndex(ctx, repo, os.Args[2:])
+			return 0
+		case "import":
+			importIndex(ctx, repo, os.Args[2:])
+			return 0
+		case "serve":
+		default:
+			log.Fatalf("unknown command %q, expected one of: serve, ingest, migrate, verify, export, import\n", os.Args[1])
+		}
+	}
 
 	// create new instance of indexer
 	chain, err := indexer.New(ctx, cfg.ClientURL)
@@ -49,35 +109,84 @@ func main() {
 		log.Fatalf("indexer.New() failed, err: %v\n", err.Error())
 	}
 
-	// subscribe to incoming Epochs
-	epochStream := chain.SubscribeToEpochs(ctx)
+	// record the fork schedule, so that epochs can be told apart by fork
+	forks, err := chain.ForkSchedule(ctx)
+	if err != nil {
+		log.Fatalf("chain.ForkSchedule() failed, err: %v\n", err.Error())
+	}
+	err = repo.SaveForkSchedule(ctx, forks)
+	if err != nil {
+		log.Fatalf("repo.SaveForkSchedule() failed, err: %v\n", err.Error())
+	}
+
+	// alert on missed slots, deep reorgs, stalls, missed validator duties & gas anomalies, if configured
+	alertEngine := newAlertEngine(*cfg)
+	if alertEngine != nil {
+		go watchForAlerts(ctx, alertEngine, chain.SubscribeToReorgs(ctx))
+	}
+
+	// track watched validators one epoch behind the head, so that late attestations are accounted for
+	trackStream := make(chan uint64, 8)
+	tracker := indexer.NewValidatorTracker(chain)
+	go track(ctx, repo, tracker, alertEngine, cfg.WatchedValidators, trackStream)
+
+	// deliver epochs to every configured sink, each with its own queue & retry policy
+	dispatcher := sink.NewDispatcher()
+	for _, spec := range cfg.Sinks {
+		s, policy, err := sink.Parse(spec, repo)
+		if err != nil {
+			log.Fatalf("sink.Parse() failed, err: %v\n", err.Error())
+		}
+		dispatcher.Add(s, policy)
+	}
+	dispatcher.Start()
+
+	// subscribe to incoming Epochs, indexing stops ahead of the shutdown so that sinks can drain
+	indexingCtx, stopIndexing := context.WithCancel(ctx)
+	epochStream := chain.SubscribeToEpochs(indexingCtx)
+	// closed once the stream is, i.e. once nothing is dispatched anymore
+	indexed := make(chan struct{})
 	go func(ctx context.Context) {
+		defer close(indexed)
 		for epochResult := range epochStream {
 			if epochResult.Error != nil {
 				log.Printf("subscription failed, err: %v\n", epochResult.Error.Error())
 			} else if epochResult.Epoch != nil {
-				err = repo.Create(ctx, *epochResult.Epoch)
-				if err != nil {
-					log.Printf("repo.Create() failed, err: %v\n", err.Error())
+				enricher.enrich(ctx, epochResult.Epoch)
+				dispatcher.Dispatch(ctx, *epochResult.Epoch)
+				if alertEngine != nil {
+					err = alertEngine.ObserveEpoch(ctx, *epochResult.Epoch)
+					if err != nil {
+						log.Printf("alertEngine.ObserveEpoch() failed, err: %v\n", err.Error())
+					}
 				}
-				err = repo.KeepOnlyTop5(ctx, epochResult.Epoch.EpochNumber)
-				if err != nil {
-					log.Printf("repo.KeepOnlyTop5() failed, err: %v\n", err.Error())
+				if epochResult.Epoch.EpochNumber > 0 {
+					select {
+					case trackStream <- epochResult.Epoch.EpochNumber - 1:
+					default:
+						log.Printf("validator tracking is lagging, epoch %d skipped\n", epochResult.Epoch.EpochNumber-1)
+					}
 				}
 			}
 		}
-	}(ctx)
+	}(indexingCtx)
 
 	// initialize http handler
-	httpHandler := handler.New(repo)
+	httpHandler := handler.New(repo, feed, tracker)
 
-	// initialize http server
+	// initialize http server, event streams outlive any write timeout so every other route is timed out on its own
+	routes := http.NewServeMux()
+	routes.Handle("/epochs/stream", httpHandler)
+	routes.Handle("/", http.TimeoutHandler(httpHandler, 5*time.Second, http.StatusText(http.StatusServiceUnavailable)))
+	streamsCtx, stopStreams := context.WithCancel(ctx)
 	server := &http.Server{
-		Addr:         ":8080",
-		ReadTimeout:  1 * time.Second,
-		WriteTimeout: 5 * time.Second,
-		Handler:      httpHandler,
+		Addr:        ":8080",
+		ReadTimeout: 1 * time.Second,
+		Handler:     routes,
+		BaseContext: func(net.Listener) context.Context { return streamsCtx },
 	}
+	// streams never go idle, they are ended for Shutdown to complete
+	server.RegisterOnShutdown(stopStreams)
 	// start server
 	go func() {
 		log.Println("server listening on http://localhost:8080")
@@ -94,5 +203,79 @@ func main() {
 	ctxWithTimeOut, cancel := context.WithTimeout(ctx, 2*time.Second)
 	defer cancel()
 	server.Shutdown(ctxWithTimeOut)
+	stopIndexing()
+	select {
+	case <-indexed:
+	case <-ctxWithTimeOut.Done():
+	}
+	err = dispatcher.Close(ctxWithTimeOut)
+	if err != nil {
+		log.Printf("dispatcher.Close() failed, err: %v\n", err.Error())
+	}
+	if alertEngine != nil {
+		err = alertEngine.Close(ctxWithTimeOut)
+		if err != nil {
+			log.Printf("alertEngine.Close() failed, err: %v\n", err.Error())
+		}
+	}
 	log.Println("graceful shutdown complete")
+	return 0
+}
+
+// opens the repository of the configured backend, migrated to the latest schema, along with its feed of written
+// epochs, postgres only, & the func closing it
+func openStore(ctx context.Context, cfg *config.AppCfg) (store.Repository, handler.Feed, func(), error) {
+	switch cfg.Store {
+	case "memory":
+		return store.NewMemory(), nil, func() {}, nil
+	case "sqlite":
+		err := db.MigrateSQLite(cfg.SQLite.Path)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("db.MigrateSQLite() failed, err: %v", err.Error())
+		}
+		conn, err := db.OpenSQLite(cfg.SQLite.Path)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("db.OpenSQLite() failed, err: %v", err.Error())
+		}
+		return store.NewSQLite(conn), nil, func() { conn.Close() }, nil
+	default:
+		pool, err := db.Connect(ctx, cfg.Postgres)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("db.Connect() failed, err: %v", err.Error())
+		}
+		err = db.Migrate(cfg.Postgres)
+		if err != nil {
+			pool.Close()
+			return nil, nil, nil, fmt.Errorf("db.Migrate() failed, err: %v", err.Error())
+		}
+		return store.New(pool), store.NewSubscriber(pool), pool.Close, nil
+	}
+}
+
+// enriches epochs with execution layer data before they are stored, each step is optional
+type enricher struct {
+	executionClient *execution.Client
+	decoder         *contracts.Decoder
+	classifier      *builders.Classifier
+}
+
+func (e *enricher) enrich(ctx context.Context, epoch *models.Epoch) {
+	if e.executionClient != nil {
+		err := e.executionClient.Enrich(ctx, epoch)
+		if err != nil {
+			log.Printf("executionClient.Enrich() failed, err: %v\n", err.Error())
+		}
+		if e.decoder != nil {
+			err = e.decoder.Decode(epoch)
+			if err != nil {
+				log.Printf("decoder.Decode() failed, err: %v\n", err.Error())
+			}
+		}
+	}
+	if e.classifier != nil {
+		err := e.classifier.Classify(ctx, epoch)
+		if err != nil {
+			log.Printf("classifier.Classify() failed, err: %v\n", err.Error())
+		}
+	}
 }
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"indexer/pkg/config"
+	"indexer/pkg/indexer"
+	"indexer/pkg/models"
+	"indexer/pkg/store"
+	"log"
+)
+
+// number of epochs written per transaction by repositories with a bulk path
+const ingestBatchSize = 256
+
+// implemented by repositories able to write many epochs at once, e.g. store.Store
+type bulkCreator interface {
+	CreateBatch(context.Context, []models.Epoch, models.ConflictPolicy) (models.WriteResult, error)
+}
+
+// store.Store implements bulkCreator
+var _ bulkCreator = &store.Store{}
+
+// indexes history from local .era files or a directory of .ssz blocks, without a beacon node, epochs stored
+// before are skipped unless another conflict policy is given
+func ingest(ctx context.Context, repo store.Repository, enricher *enricher, chain config.ChainCfg, args []string) {
+	if len(args) < 1 || len(args) > 2 {
+		log.Fatalln("usage: indexer ingest <path to .era/.ssz file or directory> [skip|overwrite|version]")
+	}
+	var conflict string
+	if len(args) == 2 {
+		conflict = args[1]
+	}
+	policy, err := models.ParseConflictPolicy(conflict)
+	if err != nil {
+		log.Fatalf("models.ParseConflictPolicy() failed, err: %v\n", err.Error())
+	}
+
+	var epochs, failures int
+	written := models.WriteResult{}
+	archive := indexer.NewArchive(args[0], chain)
+	forks, err := archive.ForkSchedule(ctx)
+	if err != nil {
+		log.Fatalf("archive.ForkSchedule() failed, err: %v\n", err.Error())
+	}
+	err = repo.SaveForkSchedule(ctx, forks)
+	if err != nil {
+		log.Fatalf("repo.SaveForkSchedule() failed, err: %v\n", err.Error())
+	}
+	// repositories with a bulk path are written ingestBatchSize epochs per transaction, one epoch at a time otherwise
+	bulk, isBulk := repo.(bulkCreator)
+	var batch []models.Epoch
+	write := func() {
+		var result models.WriteResult
+		var err error
+		if isBulk {
+			result, err = bulk.CreateBatch(ctx, batch, policy)
+		} else {
+			result, err = repo.Create(ctx, batch[0], policy)
+		}
+		if err != nil {
+			log.Printf("epochs %d-%d could not be stored, err: %v\n", batch[0].EpochNumber, batch[len(batch)-1].EpochNumber, err.Error())
+			failures += len(batch)
+		} else {
+			epochs += len(batch)
+			written.Inserted += result.Inserted
+			written.Updated += result.Updated
+			written.Skipped += result.Skipped
+		}
+		batch = batch[:0]
+	}
+	for epochResult := range archive.SubscribeToEpochs(ctx) {
+		if epochResult.Error != nil {
+			log.Printf("ingestion failed, err: %v\n", epochResult.Error.Error())
+			failures++
+		} else if epochResult.Epoch != nil {
+			enricher.enrich(ctx, epochResult.Epoch)
+			batch = append(batch, *epochResult.Epoch)
+			if !isBulk || len(batch) == ingestBatchSize {
+				write()
+			}
+		}
+	}
+	if len(batch) > 0 {
+		write()
+	}
+	log.Printf("ingestion complete, %d epochs indexed (%d rows inserted, %d updated, %d skipped), %d failures\n",
+		epochs, written.Inserted, written.Updated, written.Skipped, failures)
+}
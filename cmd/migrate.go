@@ -0,0 +1,113 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"indexer/pkg/config"
+	"indexer/pkg/db"
+	"log"
+	"strconv"
+)
+
+const migrateUsage = `usage: indexer migrate version
+       indexer migrate up [-dry-run] [version]
+       indexer migrate down [-dry-run] <version>
+       indexer migrate force <version>`
+
+// manages the schema of the configured database, runs before the store is opened as opening it migrates up
+func migrate(cfg *config.AppCfg, args []string) {
+	if len(args) < 1 {
+		log.Fatalln(migrateUsage)
+	}
+	flags := flag.NewFlagSet("migrate "+args[0], flag.ExitOnError)
+	dryRun := flags.Bool("dry-run", false, "list the migrations which would run, without running them")
+	flags.Parse(args[1:])
+
+	var m *db.Migrator
+	var err error
+	switch cfg.Store {
+	case "memory":
+		log.Fatalln("the memory store has no schema to migrate")
+	case "sqlite":
+		m, err = db.NewSQLiteMigrator(cfg.SQLite.Path)
+	default:
+		m, err = db.NewMigrator(cfg.Postgres)
+	}
+	if err != nil {
+		log.Fatalf("migrator creation failed, err: %v\n", err.Error())
+	}
+	defer m.Close()
+
+	version, dirty, err := m.Version()
+	if err != nil {
+		log.Fatalf("m.Version() failed, err: %v\n", err.Error())
+	}
+	switch args[0] {
+	case "version":
+		state := ""
+		if dirty {
+			state = " (dirty, fix the failed migration & force a version)"
+		}
+		fmt.Printf("version %d%s, latest %d\n", version, state, m.Latest())
+		return
+	case "up":
+		target := m.Latest()
+		if flags.NArg() > 0 {
+			target = parseVersion(flags.Arg(0))
+		}
+		if target < version {
+			log.Fatalf("version %d is below the current version %d, migrate down instead\n", target, version)
+		}
+		run(m, target, *dryRun)
+	case "down":
+		if flags.NArg() != 1 {
+			log.Fatalln(migrateUsage)
+		}
+		target := parseVersion(flags.Arg(0))
+		if target > version {
+			log.Fatalf("version %d is above the current version %d, migrate up instead\n", target, version)
+		}
+		run(m, target, *dryRun)
+	case "force":
+		if flags.NArg() != 1 {
+			log.Fatalln(migrateUsage)
+		}
+		err = m.Force(parseVersion(flags.Arg(0)))
+		if err != nil {
+			log.Fatalf("m.Force() failed, err: %v\n", err.Error())
+		}
+	default:
+		log.Fatalln(migrateUsage)
+	}
+	version, _, _ = m.Version()
+	log.Printf("schema at version %d\n", version)
+}
+
+// migrates to target, or lists the migrations it takes on a dry-run
+func run(m *db.Migrator, target uint, dryRun bool) {
+	if !dryRun {
+		err := m.To(target)
+		if err != nil {
+			log.Fatalf("m.To() failed, err: %v\n", err.Error())
+		}
+		return
+	}
+	steps, err := m.Plan(target)
+	if err != nil {
+		log.Fatalf("m.Plan() failed, err: %v\n", err.Error())
+	}
+	if len(steps) == 0 {
+		fmt.Println("no pending migrations")
+	}
+	for _, step := range steps {
+		fmt.Println(step)
+	}
+}
+
+func parseVersion(raw string) uint {
+	version, err := strconv.ParseUint(raw, 10, 32)
+	if err != nil {
+		log.Fatalf("invalid version %q, err: %v\n", raw, err.Error())
+	}
+	return uint(version)
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"indexer/pkg/alert"
+	"indexer/pkg/indexer"
+	"indexer/pkg/store"
+	"log"
+)
+
+// records the activity of watched validators, configured & added via the API, for every epoch received
+func track(ctx context.Context, repo store.Repository, tracker *indexer.ValidatorTracker, alertEngine *alert.Engine, configured []string, epochs <-chan uint64) {
+	for epoch := range epochs {
+		watched, err := repo.GetWatchedValidators(ctx)
+		if err != nil {
+			log.Printf("repo.GetWatchedValidators() failed, err: %v\n", err.Error())
+			continue
+		}
+		watchlist := append(append([]string{}, configured...), watched...)
+		if len(watchlist) == 0 {
+			continue
+		}
+		activity, err := tracker.Track(ctx, epoch, watchlist)
+		if err != nil {
+			log.Printf("tracker.Track(%d) failed, err: %v\n", epoch, err.Error())
+			continue
+		}
+		err = repo.CreateValidatorActivity(ctx, activity)
+		if err != nil {
+			log.Printf("repo.CreateValidatorActivity() failed, err: %v\n", err.Error())
+		}
+		if alertEngine != nil {
+			err = alertEngine.ObserveValidatorActivity(ctx, activity)
+			if err != nil {
+				log.Printf("alertEngine.ObserveValidatorActivity() failed, err: %v\n", err.Error())
+			}
+		}
+	}
+}
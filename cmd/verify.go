@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"indexer/pkg/indexer"
+	"indexer/pkg/store"
+	"indexer/pkg/verify"
+	"log"
+	"strconv"
+)
+
+// compares the stored slots & blocks of a range of epochs with the beacon node, findings are printed one JSON
+// object per line, the exit status returned is 1 if any are left unrepaired or the range could not be verified, the
+// store is closed by the caller once it returned
+func verifyRange(ctx context.Context, repo store.Repository, enricher *enricher, clientURL string, args []string) int {
+	if len(args) < 2 || len(args) > 3 || (len(args) == 3 && args[2] != "repair") {
+		log.Println("usage: indexer verify <from epoch> <to epoch> [repair]")
+		return 1
+	}
+	from, err := strconv.ParseUint(args[0], 10, 64)
+	if err != nil {
+		log.Printf("invalid from epoch %q, err: %v\n", args[0], err.Error())
+		return 1
+	}
+	to, err := strconv.ParseUint(args[1], 10, 64)
+	if err != nil || to < from {
+		log.Printf("invalid to epoch %q, expected a number not below %d\n", args[1], from)
+		return 1
+	}
+	repair := len(args) == 3
+
+	chain, err := indexer.New(ctx, clientURL)
+	if err != nil {
+		log.Printf("indexer.New() failed, err: %v\n", err.Error())
+		return 1
+	}
+	verifier := verify.New(chain, repo, enricher.enrich)
+	counts := make(map[verify.Kind]int)
+	failures := 0
+	for number := from; number <= to; number++ {
+		findings, err := verifier.Epoch(ctx, number, repair)
+		if err != nil {
+			log.Printf("epoch %d could not be verified, err: %v\n", number, err.Error())
+			failures++
+		}
+		for _, finding := range findings {
+			fmt.Println(finding)
+			counts[finding.Kind]++
+		}
+	}
+	state := "left as is"
+	if repair {
+		state = "re-indexed"
+	}
+	log.Printf("verification of epochs %d-%d complete, %d gaps, %d mismatches & %d orphans %s, %d failures\n",
+		from, to, counts[verify.Gap], counts[verify.Mismatch], counts[verify.Orphan], state, failures)
+	if failures > 0 || (!repair && len(counts) > 0) {
+		return 1
+	}
+	return 0
+}
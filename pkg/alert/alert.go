@@ -0,0 +1,254 @@
+package alert
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"indexer/pkg/models"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	StatusFiring   = "firing"
+	StatusResolved = "resolved"
+	// number of alerts waiting for delivery, alerts beyond it are retried on the next evaluation
+	queueSize = 64
+)
+
+// represents a notification about a rule condition starting or ceasing to hold
+type Alert struct {
+	Rule     string                 `json:"rule"`
+	Key      string                 `json:"key"`
+	Status   string                 `json:"status"`
+	Summary  string                 `json:"summary"`
+	Details  map[string]interface{} `json:"details,omitempty"`
+	StartsAt time.Time              `json:"startsAt"`
+	EndsAt   *time.Time             `json:"endsAt,omitempty"`
+}
+
+// fmt.Stringer implementation of an Alert
+func (a Alert) String() string {
+	b, _ := json.Marshal(a)
+	return string(b)
+}
+
+// a condition currently holding, identified by its key so that it is only notified once
+type Finding struct {
+	Key     string
+	Summary string
+	Details map[string]interface{}
+}
+
+// Rule inspects the observed state & returns every condition currently holding
+type Rule interface {
+	Name() string
+	Evaluate(*State) []Finding
+}
+
+// Notifier delivers alerts, e.g. as webhooks
+type Notifier interface {
+	Notify(context.Context, Alert) error
+}
+
+// State is everything observed so far that rules can be evaluated against
+type State struct {
+	Now time.Time
+	// most recent epochs, oldest first
+	Epochs            []models.Epoch
+	LastEpochAt       time.Time
+	Reorgs            []models.Reorg
+	ValidatorActivity []models.ValidatorActivity
+}
+
+// Engine evaluates rules whenever something is observed & notifies firing & resolved alerts, notifications are
+// delivered one by one in the background, so that observing never waits on the notifier, until the engine is closed
+type Engine struct {
+	mu        sync.Mutex
+	rules     []Rule
+	notifier  Notifier
+	state     State
+	maxEpochs int
+	active    map[string]Alert
+	queue     chan delivery
+	pending   sync.WaitGroup
+	closed    bool
+	// deliveries run on the engine's own ctx, so that queued alerts outlive the observation which raised them
+	ctx       context.Context
+	cancel    context.CancelFunc
+	delivered chan struct{}
+}
+
+// an alert queued for delivery, previous is the alert it replaces in active, restored if the delivery fails
+type delivery struct {
+	alert    Alert
+	previous Alert
+}
+
+// Creates new engine, maxEpochs bounds the number of recent epochs kept for rules looking back in time
+func New(notifier Notifier, maxEpochs int, rules ...Rule) *Engine {
+	ctx, cancel := context.WithCancel(context.Background())
+	e := &Engine{
+		rules:     rules,
+		notifier:  notifier,
+		maxEpochs: maxEpochs,
+		active:    make(map[string]Alert),
+		state:     State{LastEpochAt: time.Now()},
+		queue:     make(chan delivery, queueSize),
+		ctx:       ctx,
+		cancel:    cancel,
+		delivered: make(chan struct{}),
+	}
+	go e.deliver()
+	return e
+}
+
+// blocks until every queued alert was delivered or given up on
+func (e *Engine) Drain() {
+	e.pending.Wait()
+}
+
+// stops queuing alerts & waits for the queued ones to be delivered, deliveries still running once ctx is done are
+// cancelled & logged as failed
+func (e *Engine) Close(ctx context.Context) error {
+	e.mu.Lock()
+	if !e.closed {
+		e.closed = true
+		close(e.queue)
+	}
+	e.mu.Unlock()
+
+	var err error
+	select {
+	case <-e.delivered:
+	case <-ctx.Done():
+		err = fmt.Errorf("alert delivery cut short, err: %v", ctx.Err().Error())
+		e.cancel()
+		<-e.delivered
+	}
+	e.cancel()
+	return err
+}
+
+func (e *Engine) ObserveEpoch(ctx context.Context, epoch models.Epoch) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.state.Epochs = append(e.state.Epochs, epoch)
+	if len(e.state.Epochs) > e.maxEpochs {
+		e.state.Epochs = e.state.Epochs[len(e.state.Epochs)-e.maxEpochs:]
+	}
+	e.state.LastEpochAt = time.Now()
+	// reorgs are reported until the next epoch arrives
+	e.state.Reorgs = nil
+	return e.evaluate()
+}
+
+func (e *Engine) ObserveReorg(ctx context.Context, reorg models.Reorg) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.state.Reorgs = append(e.state.Reorgs, reorg)
+	return e.evaluate()
+}
+
+func (e *Engine) ObserveValidatorActivity(ctx context.Context, activity []models.ValidatorActivity) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.state.ValidatorActivity = activity
+	return e.evaluate()
+}
+
+// re-evaluates rules depending on the passing of time
+func (e *Engine) Tick(ctx context.Context) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.evaluate()
+}
+
+// notifies new findings as firing & vanished ones as resolved, findings already notified are not sent again
+func (e *Engine) evaluate() error {
+	if e.closed {
+		return fmt.Errorf("alert engine closed")
+	}
+	e.state.Now = time.Now()
+	firing := make(map[string]bool)
+	var alerts []Alert
+	for _, rule := range e.rules {
+		for _, finding := range rule.Evaluate(&e.state) {
+			id := rule.Name() + "/" + finding.Key
+			firing[id] = true
+			if _, ok := e.active[id]; ok {
+				continue
+			}
+			alert := Alert{
+				Rule:     rule.Name(),
+				Key:      finding.Key,
+				Status:   StatusFiring,
+				Summary:  finding.Summary,
+				Details:  finding.Details,
+				StartsAt: e.state.Now,
+			}
+			e.active[id] = alert
+			alerts = append(alerts, alert)
+		}
+	}
+	resolved := make(map[string]Alert)
+	for id, alert := range e.active {
+		if firing[id] {
+			continue
+		}
+		resolved[id] = alert
+		endsAt := e.state.Now
+		alert.Status = StatusResolved
+		alert.EndsAt = &endsAt
+		delete(e.active, id)
+		alerts = append(alerts, alert)
+	}
+	sort.SliceStable(alerts, func(i, j int) bool { return alerts[i].Rule+alerts[i].Key < alerts[j].Rule+alerts[j].Key })
+
+	var dropped int
+	for _, alert := range alerts {
+		e.pending.Add(1)
+		d := delivery{alert: alert, previous: resolved[alert.Rule+"/"+alert.Key]}
+		select {
+		case e.queue <- d:
+		default:
+			e.pending.Done()
+			e.undo(d)
+			dropped++
+		}
+	}
+	if dropped > 0 {
+		return fmt.Errorf("alert queue full, %d alerts postponed to the next evaluation", dropped)
+	}
+	return nil
+}
+
+// delivers queued alerts, failed ones are undone so that they are notified again on the next evaluation
+func (e *Engine) deliver() {
+	defer close(e.delivered)
+	for d := range e.queue {
+		err := e.notifier.Notify(e.ctx, d.alert)
+		if err != nil {
+			log.Printf("alert %s/%s could not be delivered, err: %v\n", d.alert.Rule, d.alert.Key, err.Error())
+			e.mu.Lock()
+			e.undo(d)
+			e.mu.Unlock()
+		}
+		e.pending.Done()
+	}
+}
+
+// reverts the state change of an alert which was not delivered, unless the condition changed again since, must be
+// called with mu held
+func (e *Engine) undo(d delivery) {
+	id := d.alert.Rule + "/" + d.alert.Key
+	active, ok := e.active[id]
+	switch {
+	case d.alert.Status == StatusFiring && ok && active.StartsAt.Equal(d.alert.StartsAt):
+		delete(e.active, id)
+	case d.alert.Status == StatusResolved && !ok:
+		e.active[id] = d.previous
+	}
+}
@@ -0,0 +1,208 @@
+package alert
+
+import (
+	"context"
+	"encoding/json"
+	"indexer/pkg/models"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// local webhook receiver recording every alert delivered
+type receiver struct {
+	mu     sync.Mutex
+	alerts []Alert
+	fail   bool
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if rc.fail {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	var alert Alert
+	if err := json.NewDecoder(r.Body).Decode(&alert); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	rc.alerts = append(rc.alerts, alert)
+}
+
+func (rc *receiver) failing(fail bool) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.fail = fail
+}
+
+func (rc *receiver) received() []Alert {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	alerts := rc.alerts
+	rc.alerts = nil
+	return alerts
+}
+
+// an epoch of 4 slots, proposed holds the slot offsets which have a block
+func epoch(number uint64, gasUsed uint64, proposed ...uint64) models.Epoch {
+	e := models.Epoch{EpochNumber: number}
+	for _, offset := range proposed {
+		e.Slots = append(e.Slots, models.Slot{
+			SlotNumber: number*4 + offset,
+			Block:      models.Block{GasUsed: gasUsed, GasLimit: 100},
+		})
+	}
+	return e
+}
+
+func TestEngine(t *testing.T) {
+	rc := &receiver{}
+	server := httptest.NewServer(rc)
+	defer server.Close()
+	webhook := NewWebhook(server.URL)
+	webhook.backoff = time.Millisecond
+
+	ctx := context.Background()
+	engine := New(webhook, 10,
+		MissedSlotStreak{Threshold: 2, SlotsPerEpoch: 4},
+		ReorgDepth{Depth: 1},
+		EpochStall{After: time.Hour},
+		ValidatorMissedDuty{},
+		GasAnomaly{ZScore: 3, MinEpochs: 3},
+	)
+
+	t.Run("should stay silent while nothing is wrong", func(t *testing.T) {
+		for n := uint64(0); n < 4; n++ {
+			assert.Nil(t, engine.ObserveEpoch(ctx, epoch(n, 50+n, 0, 1, 2, 3)))
+		}
+		assert.Nil(t, engine.ObserveReorg(ctx, models.Reorg{Slot: 17, Depth: 1}))
+		assert.Nil(t, engine.Tick(ctx))
+		engine.Drain()
+		assert.Empty(t, rc.received())
+	})
+
+	t.Run("should fire once per condition", func(t *testing.T) {
+		assert.Nil(t, engine.ObserveEpoch(ctx, epoch(4, 99, 0, 3)))
+		assert.Nil(t, engine.ObserveReorg(ctx, models.Reorg{Slot: 18, Depth: 3}))
+		assert.Nil(t, engine.ObserveValidatorActivity(ctx, []models.ValidatorActivity{
+			{ValidatorIndex: 7, EpochNumber: 3, AttesterDuties: 1},
+			{ValidatorIndex: 8, EpochNumber: 3, AttesterDuties: 1, AttestationsIncluded: 1},
+		}))
+		assert.Nil(t, engine.Tick(ctx))
+		engine.Drain()
+
+		alerts := rc.received()
+		var fired []string
+		for _, alert := range alerts {
+			assert.Equal(t, StatusFiring, alert.Status)
+			fired = append(fired, alert.Rule+"/"+alert.Key)
+		}
+		assert.ElementsMatch(t, []string{"gas_anomaly/utilisation", "missed_slot_streak/streak", "deep_reorg/18", "validator_missed_duty/7"}, fired)
+	})
+
+	t.Run("should resolve conditions no longer holding", func(t *testing.T) {
+		assert.Nil(t, engine.ObserveValidatorActivity(ctx, []models.ValidatorActivity{{ValidatorIndex: 7, EpochNumber: 4, AttesterDuties: 1, AttestationsIncluded: 1}}))
+		assert.Nil(t, engine.ObserveEpoch(ctx, epoch(5, 99, 0, 1, 2, 3)))
+		engine.Drain()
+
+		var resolved []string
+		for _, alert := range rc.received() {
+			assert.Equal(t, StatusResolved, alert.Status)
+			assert.NotNil(t, alert.EndsAt)
+			resolved = append(resolved, alert.Rule+"/"+alert.Key)
+		}
+		assert.ElementsMatch(t, []string{"gas_anomaly/utilisation", "missed_slot_streak/streak", "deep_reorg/18", "validator_missed_duty/7"}, resolved)
+	})
+
+	t.Run("should retry undelivered alerts", func(t *testing.T) {
+		engine.rules = append(engine.rules, EpochStall{After: time.Nanosecond})
+		rc.failing(true)
+		assert.Nil(t, engine.Tick(ctx))
+		engine.Drain()
+		assert.Empty(t, rc.received())
+		rc.failing(false)
+		assert.Nil(t, engine.Tick(ctx))
+		engine.Drain()
+		alerts := rc.received()
+		assert.Len(t, alerts, 1)
+		assert.Equal(t, "epoch_stall", alerts[0].Rule)
+	})
+}
+
+// never answers until released
+type stuck struct{ release chan struct{} }
+
+func (s stuck) Notify(ctx context.Context, alert Alert) error {
+	select {
+	case <-s.release:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func TestEngine_unreachableNotifier(t *testing.T) {
+	notifier := stuck{release: make(chan struct{})}
+	engine := New(notifier, 10, EpochStall{After: time.Nanosecond})
+
+	ctx := context.Background()
+	observed := make(chan error)
+	go func() {
+		for n := uint64(0); n < 3; n++ {
+			err := engine.ObserveEpoch(ctx, epoch(n, 50, 0, 1, 2, 3))
+			if err != nil {
+				observed <- err
+				return
+			}
+		}
+		observed <- engine.Tick(ctx)
+	}()
+	select {
+	case err := <-observed:
+		assert.Nil(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("observing waited on the notifier")
+	}
+	close(notifier.release)
+	engine.Drain()
+}
+
+func TestEngine_Close(t *testing.T) {
+	rc := &receiver{}
+	server := httptest.NewServer(rc)
+	defer server.Close()
+
+	t.Run("should deliver the queued alerts of cancelled observations", func(t *testing.T) {
+		engine := New(NewWebhook(server.URL), 10, EpochStall{After: time.Nanosecond})
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		assert.Nil(t, engine.Tick(ctx))
+		assert.Nil(t, engine.Close(context.Background()))
+		alerts := rc.received()
+		if assert.Len(t, alerts, 1) {
+			assert.Equal(t, "epoch_stall", alerts[0].Rule)
+		}
+		assert.Error(t, engine.Tick(context.Background()), "a closed engine must not queue alerts")
+	})
+
+	t.Run("should cancel deliveries outlasting the shutdown", func(t *testing.T) {
+		engine := New(stuck{release: make(chan struct{})}, 10, EpochStall{After: time.Nanosecond})
+		assert.Nil(t, engine.Tick(context.Background()))
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		closed := make(chan error)
+		go func() { closed <- engine.Close(ctx) }()
+		select {
+		case err := <-closed:
+			assert.Error(t, err)
+		case <-time.After(time.Second):
+			t.Fatal("Close() waited past its ctx")
+		}
+	})
+}
@@ -0,0 +1,171 @@
+package alert
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+// fires when at least Threshold consecutive slots are missed in the most recent epoch
+type MissedSlotStreak struct {
+	Threshold     int
+	SlotsPerEpoch uint64
+}
+
+func (r MissedSlotStreak) Name() string { return "missed_slot_streak" }
+
+func (r MissedSlotStreak) Evaluate(s *State) []Finding {
+	if len(s.Epochs) == 0 || r.Threshold <= 0 {
+		return nil
+	}
+	epoch := s.Epochs[len(s.Epochs)-1]
+	proposed := make(map[uint64]bool, len(epoch.Slots))
+	for _, slot := range epoch.Slots {
+		proposed[slot.SlotNumber] = true
+	}
+	first := epoch.EpochNumber * r.SlotsPerEpoch
+	longest, streak, streakStart, longestStart := 0, 0, uint64(0), uint64(0)
+	for slot := first; slot < first+r.SlotsPerEpoch; slot++ {
+		if proposed[slot] {
+			streak = 0
+			continue
+		}
+		if streak == 0 {
+			streakStart = slot
+		}
+		streak++
+		if streak > longest {
+			longest, longestStart = streak, streakStart
+		}
+	}
+	if longest < r.Threshold {
+		return nil
+	}
+	return []Finding{{
+		Key:     "streak",
+		Summary: fmt.Sprintf("%d consecutive slots missed in epoch %d", longest, epoch.EpochNumber),
+		Details: map[string]interface{}{"epochNumber": epoch.EpochNumber, "firstMissedSlot": longestStart, "missedSlots": longest},
+	}}
+}
+
+// fires when a reorg deeper than Depth is reported, resolves with the next epoch
+type ReorgDepth struct {
+	Depth uint64
+}
+
+func (r ReorgDepth) Name() string { return "deep_reorg" }
+
+func (r ReorgDepth) Evaluate(s *State) []Finding {
+	var findings []Finding
+	for _, reorg := range s.Reorgs {
+		if reorg.Depth <= r.Depth {
+			continue
+		}
+		findings = append(findings, Finding{
+			Key:     strconv.FormatUint(reorg.Slot, 10),
+			Summary: fmt.Sprintf("reorg of depth %d at slot %d", reorg.Depth, reorg.Slot),
+			Details: map[string]interface{}{"slot": reorg.Slot, "depth": reorg.Depth, "oldHeadBlock": reorg.OldHeadBlock, "newHeadBlock": reorg.NewHeadBlock},
+		})
+	}
+	return findings
+}
+
+// fires when no new epoch was observed for longer than After
+type EpochStall struct {
+	After time.Duration
+}
+
+func (r EpochStall) Name() string { return "epoch_stall" }
+
+func (r EpochStall) Evaluate(s *State) []Finding {
+	since := s.Now.Sub(s.LastEpochAt)
+	if r.After <= 0 || since <= r.After {
+		return nil
+	}
+	return []Finding{{
+		Key:     "stall",
+		Summary: fmt.Sprintf("no new epoch for %s", since.Round(time.Second)),
+		Details: map[string]interface{}{"lastEpochAt": s.LastEpochAt},
+	}}
+}
+
+// fires for every watched validator that missed a proposal, an attestation or a sync committee duty
+type ValidatorMissedDuty struct{}
+
+func (r ValidatorMissedDuty) Name() string { return "validator_missed_duty" }
+
+func (r ValidatorMissedDuty) Evaluate(s *State) []Finding {
+	var findings []Finding
+	for _, a := range s.ValidatorActivity {
+		missed := map[string]interface{}{}
+		if a.Proposed < a.ProposerDuties {
+			missed["proposals"] = a.ProposerDuties - a.Proposed
+		}
+		if a.AttestationsIncluded < a.AttesterDuties {
+			missed["attestations"] = a.AttesterDuties - a.AttestationsIncluded
+		}
+		if a.SyncParticipated < a.SyncDuties {
+			missed["syncCommittee"] = a.SyncDuties - a.SyncParticipated
+		}
+		if len(missed) == 0 {
+			continue
+		}
+		missed["epochNumber"] = a.EpochNumber
+		findings = append(findings, Finding{
+			Key:     strconv.FormatUint(a.ValidatorIndex, 10),
+			Summary: fmt.Sprintf("validator %d missed duties in epoch %d", a.ValidatorIndex, a.EpochNumber),
+			Details: missed,
+		})
+	}
+	return findings
+}
+
+// fires when the gas utilisation of the most recent epoch deviates from the preceding epochs by at least ZScore
+// standard deviations, at least MinEpochs preceding epochs are required
+type GasAnomaly struct {
+	ZScore    float64
+	MinEpochs int
+}
+
+func (r GasAnomaly) Name() string { return "gas_anomaly" }
+
+func (r GasAnomaly) Evaluate(s *State) []Finding {
+	if len(s.Epochs) <= r.MinEpochs || r.MinEpochs < 2 || r.ZScore <= 0 {
+		return nil
+	}
+	utilisation := make([]float64, len(s.Epochs))
+	for idx, epoch := range s.Epochs {
+		var used, limit uint64
+		for _, slot := range epoch.Slots {
+			used += slot.Block.GasUsed
+			limit += slot.Block.GasLimit
+		}
+		if limit > 0 {
+			utilisation[idx] = float64(used) / float64(limit)
+		}
+	}
+	history, latest := utilisation[:len(utilisation)-1], utilisation[len(utilisation)-1]
+	var mean, variance float64
+	for _, u := range history {
+		mean += u
+	}
+	mean /= float64(len(history))
+	for _, u := range history {
+		variance += (u - mean) * (u - mean)
+	}
+	stddev := math.Sqrt(variance / float64(len(history)))
+	if stddev == 0 {
+		stddev = 1e-9
+	}
+	z := (latest - mean) / stddev
+	if math.Abs(z) < r.ZScore {
+		return nil
+	}
+	epoch := s.Epochs[len(s.Epochs)-1]
+	return []Finding{{
+		Key:     "utilisation",
+		Summary: fmt.Sprintf("gas utilisation of epoch %d is %.1f%%, %.1f standard deviations from the mean of %.1f%%", epoch.EpochNumber, latest*100, z, mean*100),
+		Details: map[string]interface{}{"epochNumber": epoch.EpochNumber, "utilisation": latest, "mean": mean, "zScore": z},
+	}}
+}
@@ -0,0 +1,165 @@
+package builders
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"indexer/pkg/execution"
+	"indexer/pkg/models"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// label of blocks built by the proposer's own execution client
+	Local = "local"
+	// label of blocks paying the proposer from an unlisted fee recipient
+	Unknown = "unknown"
+)
+
+// represents a builder as listed in the builders file, blocks match by fee recipient or by extra data
+type Builder struct {
+	Label         string   `json:"label"`
+	FeeRecipients []string `json:"feeRecipients"`
+	// case insensitive substrings of the payload's extra data read as text
+	ExtraData []string `json:"extraData"`
+}
+
+// Transactions looks up the transaction at an index of an execution block, see execution.Client
+type Transactions interface {
+	TransactionByIndex(ctx context.Context, blockHash string, index uint64) (*execution.Transaction, error)
+}
+
+// Classifier labels blocks with the builder that built them
+type Classifier struct {
+	transactions Transactions
+
+	mu            sync.Mutex
+	path          string
+	modTime       time.Time
+	feeRecipients map[string]string
+	builders      []Builder
+}
+
+// loads the builders file, a JSON array of Builder, which is reloaded whenever it changes,
+// transactions is optional & enables detecting payments of unlisted builders to the proposer
+func Load(path string, transactions Transactions) (*Classifier, error) {
+	c := &Classifier{path: path, transactions: transactions}
+	err := c.reload()
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// creates a classifier for a fixed list of builders
+func New(builders []Builder, transactions Transactions) *Classifier {
+	c := &Classifier{transactions: transactions}
+	c.set(builders)
+	return c
+}
+
+func (c *Classifier) set(builders []Builder) {
+	c.builders = builders
+	c.feeRecipients = make(map[string]string)
+	for _, b := range builders {
+		for _, feeRecipient := range b.FeeRecipients {
+			c.feeRecipients[strings.ToLower(feeRecipient)] = b.Label
+		}
+	}
+}
+
+// reads the builders file if it was modified since it was last read
+func (c *Classifier) reload() error {
+	info, err := os.Stat(c.path)
+	if err != nil {
+		return err
+	}
+	if info.ModTime().Equal(c.modTime) {
+		return nil
+	}
+	data, err := os.ReadFile(c.path)
+	if err != nil {
+		return err
+	}
+	var builders []Builder
+	err = json.Unmarshal(data, &builders)
+	if err != nil {
+		return fmt.Errorf("invalid builders file, err: %v", err.Error())
+	}
+	for _, b := range builders {
+		if b.Label == "" || b.Label == Local || b.Label == Unknown {
+			return fmt.Errorf("invalid builders file, builder label %q is empty or reserved", b.Label)
+		}
+	}
+	c.set(builders)
+	c.modTime = info.ModTime()
+	return nil
+}
+
+// labels every block of the epoch carrying an execution payload
+func (c *Classifier) Classify(ctx context.Context, e *models.Epoch) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.path != "" {
+		// a broken edit keeps the previous mapping in place
+		if err := c.reload(); err != nil {
+			log.Printf("builders file reload failed, err: %v\n", err.Error())
+		}
+	}
+	for idx := range e.Slots {
+		err := c.classify(ctx, &e.Slots[idx].Block)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// a listed fee recipient or extra data identifies an external builder, otherwise a payment from the fee recipient
+// in the last transaction reveals an unlisted one, blocks matching neither were built locally
+func (c *Classifier) classify(ctx context.Context, block *models.Block) error {
+	if block.BlockNumber == 0 {
+		return nil
+	}
+	label := c.match(block)
+	if label == "" && c.transactions != nil && block.NoOfTransactions > 0 {
+		tx, err := c.transactions.TransactionByIndex(ctx, block.ExecutionBlockHash, uint64(block.NoOfTransactions-1))
+		if err != nil {
+			return fmt.Errorf("eth_getTransactionByBlockHashAndIndex(%s) failed, err: %v", block.ExecutionBlockHash, err.Error())
+		}
+		feeRecipient := strings.ToLower(block.FeeRecipient)
+		if tx.From == feeRecipient && tx.To != "" && tx.To != feeRecipient && tx.Value.Sign() > 0 {
+			label = Unknown
+		}
+	}
+	block.MEVBoost = label != ""
+	if label == "" {
+		label = Local
+	}
+	block.Builder = label
+	return nil
+}
+
+func (c *Classifier) match(block *models.Block) string {
+	if label, ok := c.feeRecipients[strings.ToLower(block.FeeRecipient)]; ok {
+		return label
+	}
+	extraData, err := hex.DecodeString(strings.TrimPrefix(block.ExtraData, "0x"))
+	if err != nil || len(extraData) == 0 {
+		return ""
+	}
+	text := strings.ToLower(string(extraData))
+	for _, b := range c.builders {
+		for _, pattern := range b.ExtraData {
+			if pattern != "" && strings.Contains(text, strings.ToLower(pattern)) {
+				return b.Label
+			}
+		}
+	}
+	return ""
+}
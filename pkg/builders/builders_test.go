@@ -0,0 +1,144 @@
+package builders
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"indexer/pkg/execution"
+	"indexer/pkg/models"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	proposer = "0x1111111111111111111111111111111111111111"
+	listed   = "0x2222222222222222222222222222222222222222"
+	unlisted = "0x3333333333333333333333333333333333333333"
+)
+
+// last transactions keyed by block hash
+type transactions map[string]*execution.Transaction
+
+func (t transactions) TransactionByIndex(_ context.Context, blockHash string, _ uint64) (*execution.Transaction, error) {
+	tx, ok := t[blockHash]
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	return tx, nil
+}
+
+func extraData(text string) string {
+	return "0x" + hex.EncodeToString([]byte(text))
+}
+
+func TestClassifier_Classify(t *testing.T) {
+	txs := transactions{
+		"0x03": {From: unlisted, To: proposer, Value: big.NewInt(1e17)},
+		"0x04": {From: proposer, To: unlisted, Value: big.NewInt(1e17)},
+		"0x05": {From: unlisted, To: proposer, Value: big.NewInt(0)},
+	}
+	classifier := New([]Builder{
+		{Label: "flashbots", FeeRecipients: []string{"0x2222222222222222222222222222222222222222"}},
+		{Label: "beaverbuild", ExtraData: []string{"BeaverBuild"}},
+	}, txs)
+
+	tests := []struct {
+		name         string
+		block        models.Block
+		wantBuilder  string
+		wantMEVBoost bool
+		wantErr      bool
+	}{
+		{
+			name:        "should skip blocks without an execution payload",
+			block:       models.Block{},
+			wantBuilder: "",
+		},
+		{
+			name:         "should match listed fee recipients",
+			block:        models.Block{BlockNumber: 1, ExecutionBlockHash: "0x01", FeeRecipient: listed, NoOfTransactions: 1},
+			wantBuilder:  "flashbots",
+			wantMEVBoost: true,
+		},
+		{
+			name:         "should match extra data case insensitively",
+			block:        models.Block{BlockNumber: 2, ExecutionBlockHash: "0x02", FeeRecipient: unlisted, ExtraData: extraData("beaverbuild.org"), NoOfTransactions: 1},
+			wantBuilder:  "beaverbuild",
+			wantMEVBoost: true,
+		},
+		{
+			name:         "should detect a payment of an unlisted builder to the proposer",
+			block:        models.Block{BlockNumber: 3, ExecutionBlockHash: "0x03", FeeRecipient: unlisted, NoOfTransactions: 1},
+			wantBuilder:  Unknown,
+			wantMEVBoost: true,
+		},
+		{
+			name:        "should not mistake a transfer from another sender for a payment",
+			block:       models.Block{BlockNumber: 4, ExecutionBlockHash: "0x04", FeeRecipient: unlisted, NoOfTransactions: 1},
+			wantBuilder: Local,
+		},
+		{
+			name:        "should not mistake a transaction without value for a payment",
+			block:       models.Block{BlockNumber: 5, ExecutionBlockHash: "0x05", FeeRecipient: unlisted, NoOfTransactions: 1},
+			wantBuilder: Local,
+		},
+		{
+			name:        "should consider empty blocks local",
+			block:       models.Block{BlockNumber: 6, ExecutionBlockHash: "0x06", FeeRecipient: proposer, ExtraData: extraData("geth")},
+			wantBuilder: Local,
+		},
+		{
+			name:    "should fail when the last transaction cannot be fetched",
+			block:   models.Block{BlockNumber: 7, ExecutionBlockHash: "0x07", FeeRecipient: proposer, NoOfTransactions: 3},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			epoch := models.Epoch{Slots: []models.Slot{{Block: tt.block}}}
+			err := classifier.Classify(context.Background(), &epoch)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantBuilder, epoch.Slots[0].Block.Builder)
+			assert.Equal(t, tt.wantMEVBoost, epoch.Slots[0].Block.MEVBoost)
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "builders.json")
+	write := func(content string, modTime time.Time) {
+		assert.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+		assert.NoError(t, os.Chtimes(path, modTime, modTime))
+	}
+	classify := func(c *Classifier) string {
+		epoch := models.Epoch{Slots: []models.Slot{{Block: models.Block{BlockNumber: 1, ExecutionBlockHash: "0x01", FeeRecipient: listed}}}}
+		assert.NoError(t, c.Classify(context.Background(), &epoch))
+		return epoch.Slots[0].Block.Builder
+	}
+
+	_, err := Load(path, nil)
+	assert.Error(t, err, "a missing file must be reported")
+	write(`[{"label":"local","feeRecipients":["`+listed+`"]}]`, time.Unix(1, 0))
+	_, err = Load(path, nil)
+	assert.Error(t, err, "reserved labels must be rejected")
+
+	write(`[{"label":"flashbots","feeRecipients":["`+listed+`"]}]`, time.Unix(2, 0))
+	classifier, err := Load(path, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "flashbots", classify(classifier))
+
+	write(`[{"label":"titan","feeRecipients":["`+listed+`"]}]`, time.Unix(3, 0))
+	assert.Equal(t, "titan", classify(classifier), "edits must be picked up")
+
+	write(`[{"label":`, time.Unix(4, 0))
+	assert.Equal(t, "titan", classify(classifier), "broken edits must keep the previous mapping")
+}
@@ -3,17 +3,38 @@ package config
 import (
 	"fmt"
 	"net/url"
+	"time"
 
 	"github.com/ardanlabs/conf/v2"
 	"github.com/joho/godotenv"
 )
 
 type AppCfg struct {
-	ClientURL string `conf:"required"`
-	Postgres  PgCfg
+	// beacon node url, required by every command but those working offline, see Parse
+	ClientURL    string
+	ExecutionURL string `conf:"help:optional execution client JSON-RPC url, enables receipt enrichment"`
+	// JSON file listing the contracts whose events are decoded, requires ExecutionURL
+	ContractsFile string `conf:"help:optional contracts file, enables contract event indexing"`
+	// JSON file labelling builders by fee recipient & extra data, reloaded whenever it changes
+	BuildersFile string `conf:"help:optional builders file, enables builder classification"`
+	// validator indices or 0x prefixed pubkeys to track, separated by ';', more can be added via the API
+	WatchedValidators []string
+	// destinations of indexed epochs separated by ';', e.g. postgres://;stdout://;nats://localhost:4222/epochs
+	Sinks []string `conf:"default:postgres://"`
+	// backend of the repository, postgres, sqlite or memory, sqlite needs no server & suits local development, memory
+	// keeps nothing across restarts & suits demos
+	Store    string `conf:"default:postgres"`
+	Postgres PgCfg
+	SQLite   SQLiteCfg
+	Chain    ChainCfg
+	Alert    AlertCfg
 }
 
-func Parse() (*AppCfg, error) {
+// commands which never reach a beacon node, CLIENT_URL is optional for them
+var offline = map[string]bool{"ingest": true, "export": true, "import": true, "migrate": true}
+
+// parses the config of command, the first argument of the binary, "" being serve
+func Parse(command string) (*AppCfg, error) {
 	cfg := AppCfg{}
 	err := godotenv.Load()
 	if err != nil {
@@ -23,16 +44,33 @@ func Parse() (*AppCfg, error) {
 	if err != nil {
 		return nil, err
 	}
+	switch cfg.Store {
+	case "postgres":
+		// only required when postgres is the backend
+		if cfg.Postgres.Host == "" || cfg.Postgres.Name == "" || cfg.Postgres.User == "" || cfg.Postgres.Password == "" {
+			return nil, fmt.Errorf("postgres host, name, user & password are required")
+		}
+	case "sqlite", "memory":
+	default:
+		return nil, fmt.Errorf("unknown store %q, expected postgres, sqlite or memory", cfg.Store)
+	}
+	if cfg.ClientURL == "" && !offline[command] {
+		return nil, fmt.Errorf("client url is required by %s", commandName(command))
+	}
+	// slots are divided into epochs
+	if cfg.Chain.SlotsPerEpoch == 0 {
+		return nil, fmt.Errorf("chain slots per epoch must be greater than 0")
+	}
 	return &cfg, nil
 }
 
 // represents postgres db connection credentials
 type PgCfg struct {
-	Host       string `conf:"required"`
-	Name       string `conf:"required"`
-	User       string `conf:"required"`
-	Password   string `conf:"required"`
-	DisableTLS bool   `conf:"required"`
+	Host       string
+	Name       string
+	User       string
+	Password   string
+	DisableTLS bool
 }
 
 // create postgres db connection string
@@ -53,3 +91,37 @@ func (p PgCfg) String() string {
 	}
 	return u.String()
 }
+
+// represents the sqlite database file, created if missing
+type SQLiteCfg struct {
+	Path string `conf:"default:indexer.db,env:SQLITE_PATH"`
+}
+
+// represents the chain spec used when no beacon node is at hand (offline ingestion), defaults to mainnet
+type ChainCfg struct {
+	// unix time of the genesis block, slot & epoch times are derived from it
+	GenesisTime        int64         `conf:"default:1606824023"`
+	SlotsPerEpoch      uint64        `conf:"default:32"`
+	SlotDuration       time.Duration `conf:"default:12s"`
+	AltairForkEpoch    uint64        `conf:"default:74240"`
+	BellatrixForkEpoch uint64        `conf:"default:144896"`
+	CapellaForkEpoch   uint64        `conf:"default:194048"`
+	DenebForkEpoch     uint64        `conf:"default:269568"`
+}
+
+// represents alerting rules thresholds, alerting is enabled by setting a webhook url
+type AlertCfg struct {
+	WebhookURL       string
+	MissedSlotStreak int           `conf:"default:3"`
+	ReorgDepth       uint64        `conf:"default:2"`
+	EpochStall       time.Duration `conf:"default:15m"`
+	GasZScore        float64       `conf:"default:3"`
+	GasMinEpochs     int           `conf:"default:10"`
+}
+
+func commandName(command string) string {
+	if command == "" {
+		return "serve"
+	}
+	return command
+}
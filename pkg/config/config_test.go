@@ -4,7 +4,9 @@ import (
 	"os"
 	"reflect"
 	"testing"
+	"time"
 
+	"github.com/joho/godotenv"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -64,6 +66,7 @@ func TestParse(t *testing.T) {
 	tests := []struct {
 		name    string
 		dotEnv  []byte
+		command string
 		want    *AppCfg
 		wantErr bool
 	}{
@@ -77,6 +80,9 @@ func TestParse(t *testing.T) {
 			POSTGRES_DISABLE_TLS=true`),
 			want: &AppCfg{
 				ClientURL: "https://dummy.client",
+				Sinks:     []string{"postgres://"},
+				Store:     "postgres",
+				SQLite:    SQLiteCfg{Path: "indexer.db"},
 				Postgres: PgCfg{
 					Host:       "localhost:5432",
 					Name:       "database",
@@ -84,9 +90,105 @@ func TestParse(t *testing.T) {
 					Password:   "password",
 					DisableTLS: true,
 				},
+				Chain: ChainCfg{
+					GenesisTime:        1606824023,
+					SlotsPerEpoch:      32,
+					SlotDuration:       12 * time.Second,
+					AltairForkEpoch:    74240,
+					BellatrixForkEpoch: 144896,
+					CapellaForkEpoch:   194048,
+					DenebForkEpoch:     269568,
+				},
+				Alert: AlertCfg{
+					MissedSlotStreak: 3,
+					ReorgDepth:       2,
+					EpochStall:       15 * time.Minute,
+					GasZScore:        3,
+					GasMinEpochs:     10,
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "should return *AppCfg without postgres credentials for sqlite",
+			dotEnv: []byte(`CLIENT_URL=https://dummy.client
+			STORE=sqlite
+			SQLITE_PATH=/tmp/indexer.db`),
+			want: &AppCfg{
+				ClientURL: "https://dummy.client",
+				Sinks:     []string{"postgres://"},
+				Store:     "sqlite",
+				SQLite:    SQLiteCfg{Path: "/tmp/indexer.db"},
+				Chain: ChainCfg{
+					GenesisTime:        1606824023,
+					SlotsPerEpoch:      32,
+					SlotDuration:       12 * time.Second,
+					AltairForkEpoch:    74240,
+					BellatrixForkEpoch: 144896,
+					CapellaForkEpoch:   194048,
+					DenebForkEpoch:     269568,
+				},
+				Alert: AlertCfg{
+					MissedSlotStreak: 3,
+					ReorgDepth:       2,
+					EpochStall:       15 * time.Minute,
+					GasZScore:        3,
+					GasMinEpochs:     10,
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "should return error for missing postgres credentials",
+			dotEnv: []byte(`CLIENT_URL=https://dummy.client
+			POSTGRES_HOST=localhost:5432`),
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "should return error for a missing client url",
+			dotEnv: []byte(`STORE=sqlite
+			SQLITE_PATH=/tmp/indexer.db`),
+			command: "verify",
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "should return *AppCfg without client url for offline commands",
+			dotEnv: []byte(`STORE=sqlite
+			SQLITE_PATH=/tmp/indexer.db`),
+			command: "ingest",
+			want: &AppCfg{
+				Sinks:  []string{"postgres://"},
+				Store:  "sqlite",
+				SQLite: SQLiteCfg{Path: "/tmp/indexer.db"},
+				Chain: ChainCfg{
+					GenesisTime:        1606824023,
+					SlotsPerEpoch:      32,
+					SlotDuration:       12 * time.Second,
+					AltairForkEpoch:    74240,
+					BellatrixForkEpoch: 144896,
+					CapellaForkEpoch:   194048,
+					DenebForkEpoch:     269568,
+				},
+				Alert: AlertCfg{
+					MissedSlotStreak: 3,
+					ReorgDepth:       2,
+					EpochStall:       15 * time.Minute,
+					GasZScore:        3,
+					GasMinEpochs:     10,
+				},
 			},
 			wantErr: false,
 		},
+		{
+			name: "should return error for 0 slots per epoch",
+			dotEnv: []byte(`CLIENT_URL=https://dummy.client
+			STORE=memory
+			CHAIN_SLOTS_PER_EPOCH=0`),
+			want:    nil,
+			wantErr: true,
+		},
 		{
 			name:    "should return error for missing .env",
 			want:    nil,
@@ -104,8 +206,15 @@ func TestParse(t *testing.T) {
 				}()
 				_, err = f.Write(tt.dotEnv)
 				assert.Nil(t, err, "write to .env must not fail")
+				// godotenv.Load() sets the process environment, cleared for the following cases
+				defer func() {
+					env, _ := godotenv.Unmarshal(string(tt.dotEnv))
+					for key := range env {
+						os.Unsetenv(key)
+					}
+				}()
 			}
-			got, err := Parse()
+			got, err := Parse(tt.command)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("Parse() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -0,0 +1,378 @@
+package contracts
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/sha3"
+)
+
+// represents an input of an ABI event or a tuple component
+type abiArg struct {
+	Name       string   `json:"name"`
+	Type       string   `json:"type"`
+	Indexed    bool     `json:"indexed"`
+	Components []abiArg `json:"components"`
+}
+
+// represents an entry of a contract's ABI JSON, only events are of interest
+type abiEntry struct {
+	Type      string   `json:"type"`
+	Name      string   `json:"name"`
+	Inputs    []abiArg `json:"inputs"`
+	Anonymous bool     `json:"anonymous"`
+}
+
+// a parsed solidity type
+type abiType struct {
+	kind       string // uint, int, address, bool, bytes, fixedbytes, string, slice, array, tuple
+	size       int    // bit size of ints, byte size of fixed bytes
+	length     int    // length of fixed arrays
+	elem       *abiType
+	components []abiArg
+	types      []abiType
+}
+
+// a parsed event, identified by topic0
+type event struct {
+	name      string
+	signature string
+	topic     string
+	inputs    []abiArg
+	types     []abiType
+}
+
+// parses the events of an ABI JSON document, anonymous events carry no topic0 & are skipped
+func parseABI(data []byte) ([]event, error) {
+	var entries []abiEntry
+	err := json.Unmarshal(data, &entries)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ABI JSON, err: %v", err.Error())
+	}
+	var events []event
+	for _, entry := range entries {
+		if entry.Type != "event" || entry.Anonymous {
+			continue
+		}
+		types := make([]abiType, len(entry.Inputs))
+		canonical := make([]string, len(entry.Inputs))
+		for idx, input := range entry.Inputs {
+			types[idx], err = parseType(input.Type, input.Components)
+			if err != nil {
+				return nil, fmt.Errorf("event %s, input %s: %w", entry.Name, input.Name, err)
+			}
+			canonical[idx] = types[idx].String()
+		}
+		signature := fmt.Sprintf("%s(%s)", entry.Name, strings.Join(canonical, ","))
+		events = append(events, event{
+			name:      entry.Name,
+			signature: signature,
+			topic:     "0x" + hex.EncodeToString(keccak256([]byte(signature))),
+			inputs:    entry.Inputs,
+			types:     types,
+		})
+	}
+	return events, nil
+}
+
+func parseType(typ string, components []abiArg) (abiType, error) {
+	// arrays are parsed right to left, uint256[2][] is a slice of uint256[2]
+	if strings.HasSuffix(typ, "]") {
+		open := strings.LastIndex(typ, "[")
+		if open < 0 {
+			return abiType{}, fmt.Errorf("invalid type %s", typ)
+		}
+		elem, err := parseType(typ[:open], components)
+		if err != nil {
+			return abiType{}, err
+		}
+		size := typ[open+1 : len(typ)-1]
+		if size == "" {
+			return abiType{kind: "slice", elem: &elem}, nil
+		}
+		length, err := strconv.Atoi(size)
+		if err != nil || length <= 0 {
+			return abiType{}, fmt.Errorf("invalid array length in %s", typ)
+		}
+		return abiType{kind: "array", length: length, elem: &elem}, nil
+	}
+
+	switch {
+	case typ == "tuple":
+		types := make([]abiType, len(components))
+		for idx, component := range components {
+			var err error
+			types[idx], err = parseType(component.Type, component.Components)
+			if err != nil {
+				return abiType{}, err
+			}
+		}
+		return abiType{kind: "tuple", components: components, types: types}, nil
+	case typ == "address", typ == "bool", typ == "string", typ == "bytes":
+		return abiType{kind: typ}, nil
+	case strings.HasPrefix(typ, "uint"), strings.HasPrefix(typ, "int"):
+		kind := "int"
+		if strings.HasPrefix(typ, "uint") {
+			kind = "uint"
+		}
+		size := 256
+		if bits := strings.TrimPrefix(typ, kind); bits != "" {
+			var err error
+			size, err = strconv.Atoi(bits)
+			if err != nil || size <= 0 || size > 256 || size%8 != 0 {
+				return abiType{}, fmt.Errorf("invalid type %s", typ)
+			}
+		}
+		return abiType{kind: kind, size: size}, nil
+	case strings.HasPrefix(typ, "bytes"):
+		size, err := strconv.Atoi(strings.TrimPrefix(typ, "bytes"))
+		if err != nil || size <= 0 || size > 32 {
+			return abiType{}, fmt.Errorf("invalid type %s", typ)
+		}
+		return abiType{kind: "fixedbytes", size: size}, nil
+	default:
+		return abiType{}, fmt.Errorf("unsupported type %s", typ)
+	}
+}
+
+// canonical type name used in event signatures
+func (t abiType) String() string {
+	switch t.kind {
+	case "uint", "int":
+		return t.kind + strconv.Itoa(t.size)
+	case "fixedbytes":
+		return "bytes" + strconv.Itoa(t.size)
+	case "slice":
+		return t.elem.String() + "[]"
+	case "array":
+		return t.elem.String() + "[" + strconv.Itoa(t.length) + "]"
+	case "tuple":
+		types := make([]string, len(t.types))
+		for idx := range t.types {
+			types[idx] = t.types[idx].String()
+		}
+		return "(" + strings.Join(types, ",") + ")"
+	default:
+		return t.kind
+	}
+}
+
+// dynamic types are encoded out of line & hashed when indexed
+func (t abiType) dynamic() bool {
+	switch t.kind {
+	case "string", "bytes", "slice":
+		return true
+	case "array":
+		return t.elem.dynamic()
+	case "tuple":
+		for _, typ := range t.types {
+			if typ.dynamic() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// number of bytes a value occupies in the head of its enclosing tuple
+func (t abiType) headSize() int {
+	if t.dynamic() {
+		return 32
+	}
+	switch t.kind {
+	case "array":
+		return t.length * t.elem.headSize()
+	case "tuple":
+		size := 0
+		for _, typ := range t.types {
+			size += typ.headSize()
+		}
+		return size
+	}
+	return 32
+}
+
+// decodes the indexed & non-indexed inputs of an event from a log
+func (e event) decode(topics []string, data []byte) (map[string]interface{}, error) {
+	if len(topics) == 0 || !strings.EqualFold(topics[0], e.topic) {
+		return nil, errors.New("topic0 does not match the event")
+	}
+
+	var nonIndexed []abiType
+	var nonIndexedNames []string
+	args := make(map[string]interface{}, len(e.inputs))
+	topicIdx := 1
+	for idx, input := range e.inputs {
+		name := input.Name
+		if name == "" {
+			name = "arg" + strconv.Itoa(idx)
+		}
+		if !input.Indexed {
+			nonIndexed = append(nonIndexed, e.types[idx])
+			nonIndexedNames = append(nonIndexedNames, name)
+			continue
+		}
+		if topicIdx >= len(topics) {
+			return nil, fmt.Errorf("missing topic for indexed input %s", name)
+		}
+		topic, err := decodeHex(topics[topicIdx])
+		if err != nil || len(topic) != 32 {
+			return nil, fmt.Errorf("invalid topic for indexed input %s", name)
+		}
+		topicIdx++
+		// only the keccak256 hash of indexed dynamic values is logged
+		if e.types[idx].dynamic() || e.types[idx].kind == "tuple" || e.types[idx].kind == "array" {
+			args[name] = "0x" + hex.EncodeToString(topic)
+			continue
+		}
+		args[name], err = decodeValue(e.types[idx], topic, 0)
+		if err != nil {
+			return nil, fmt.Errorf("indexed input %s: %w", name, err)
+		}
+	}
+
+	values, err := decodeTuple(nonIndexed, data, 0)
+	if err != nil {
+		return nil, err
+	}
+	for idx, value := range values {
+		args[nonIndexedNames[idx]] = value
+	}
+	return args, nil
+}
+
+// decodes a sequence of values encoded head/tail starting at offset
+func decodeTuple(types []abiType, data []byte, offset int) ([]interface{}, error) {
+	values := make([]interface{}, len(types))
+	head := offset
+	for idx, typ := range types {
+		if !typ.dynamic() {
+			value, err := decodeValue(typ, data, head)
+			if err != nil {
+				return nil, err
+			}
+			values[idx] = value
+			head += typ.headSize()
+			continue
+		}
+		tail, err := readInt(data, head)
+		if err != nil {
+			return nil, err
+		}
+		value, err := decodeValue(typ, data, offset+tail)
+		if err != nil {
+			return nil, err
+		}
+		values[idx] = value
+		head += 32
+	}
+	return values, nil
+}
+
+// decodes a single value located at offset, integers are returned as decimal strings to survive JSON
+func decodeValue(typ abiType, data []byte, offset int) (interface{}, error) {
+	switch typ.kind {
+	case "slice", "array":
+		length, start := typ.length, offset
+		if typ.kind == "slice" {
+			var err error
+			length, err = readInt(data, offset)
+			if err != nil {
+				return nil, err
+			}
+			start += 32
+		}
+		if length > len(data) {
+			return nil, errors.New("array length out of bounds")
+		}
+		types := make([]abiType, length)
+		for idx := range types {
+			types[idx] = *typ.elem
+		}
+		return decodeTuple(types, data, start)
+	case "tuple":
+		values, err := decodeTuple(typ.types, data, offset)
+		if err != nil {
+			return nil, err
+		}
+		tuple := make(map[string]interface{}, len(values))
+		for idx, value := range values {
+			name := typ.components[idx].Name
+			if name == "" {
+				name = "arg" + strconv.Itoa(idx)
+			}
+			tuple[name] = value
+		}
+		return tuple, nil
+	case "string", "bytes":
+		length, err := readInt(data, offset)
+		if err != nil {
+			return nil, err
+		}
+		if offset+32+length > len(data) {
+			return nil, errors.New("bytes out of bounds")
+		}
+		content := data[offset+32 : offset+32+length]
+		if typ.kind == "string" {
+			return string(content), nil
+		}
+		return "0x" + hex.EncodeToString(content), nil
+	}
+
+	word, err := readWord(data, offset)
+	if err != nil {
+		return nil, err
+	}
+	switch typ.kind {
+	case "uint":
+		return new(big.Int).SetBytes(word).String(), nil
+	case "int":
+		value := new(big.Int).SetBytes(word)
+		if word[0]&0x80 != 0 {
+			value.Sub(value, new(big.Int).Lsh(big.NewInt(1), 256))
+		}
+		return value.String(), nil
+	case "address":
+		return "0x" + hex.EncodeToString(word[12:]), nil
+	case "bool":
+		return word[31] == 1, nil
+	case "fixedbytes":
+		return "0x" + hex.EncodeToString(word[:typ.size]), nil
+	}
+	return nil, fmt.Errorf("unsupported type %s", typ.String())
+}
+
+func readWord(data []byte, offset int) ([]byte, error) {
+	if offset < 0 || offset+32 > len(data) {
+		return nil, errors.New("word out of bounds")
+	}
+	return data[offset : offset+32], nil
+}
+
+// reads a word holding an offset or a length
+func readInt(data []byte, offset int) (int, error) {
+	word, err := readWord(data, offset)
+	if err != nil {
+		return 0, err
+	}
+	value := new(big.Int).SetBytes(word)
+	if !value.IsInt64() || value.Int64() > int64(len(data)) {
+		return 0, errors.New("offset or length out of bounds")
+	}
+	return int(value.Int64()), nil
+}
+
+func decodeHex(s string) ([]byte, error) {
+	return hex.DecodeString(strings.TrimPrefix(s, "0x"))
+}
+
+func keccak256(data []byte) []byte {
+	h := sha3.NewLegacyKeccak256()
+	h.Write(data)
+	return h.Sum(nil)
+}
@@ -0,0 +1,125 @@
+package contracts
+
+import (
+	"encoding/json"
+	"fmt"
+	"indexer/pkg/models"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// represents a watched contract as listed in the contracts file
+type Contract struct {
+	Name    string `json:"name"`
+	Address string `json:"address"`
+	// path to the contract's ABI JSON, relative to the contracts file
+	ABI string `json:"abi"`
+}
+
+type watched struct {
+	name   string
+	events map[string]event
+}
+
+// Decoder turns logs of watched contracts into models.ContractEvent
+type Decoder struct {
+	contracts map[string]watched
+}
+
+// loads the contracts file, a JSON array of Contract, & their ABIs
+func Load(path string) (*Decoder, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var contracts []Contract
+	err = json.Unmarshal(data, &contracts)
+	if err != nil {
+		return nil, fmt.Errorf("invalid contracts file, err: %v", err.Error())
+	}
+	abis := make(map[string][]byte, len(contracts))
+	for _, c := range contracts {
+		abiPath := c.ABI
+		if !filepath.IsAbs(abiPath) {
+			abiPath = filepath.Join(filepath.Dir(path), abiPath)
+		}
+		abis[c.ABI], err = os.ReadFile(abiPath)
+		if err != nil {
+			return nil, fmt.Errorf("contract %s: %w", c.Name, err)
+		}
+	}
+	return New(contracts, abis)
+}
+
+// creates a decoder for the given contracts, abis are keyed by Contract.ABI
+func New(contracts []Contract, abis map[string][]byte) (*Decoder, error) {
+	d := &Decoder{contracts: make(map[string]watched, len(contracts))}
+	for _, c := range contracts {
+		address := strings.ToLower(c.Address)
+		if _, ok := d.contracts[address]; ok {
+			return nil, fmt.Errorf("contract %s: duplicate address %s", c.Name, c.Address)
+		}
+		events, err := parseABI(abis[c.ABI])
+		if err != nil {
+			return nil, fmt.Errorf("contract %s: %w", c.Name, err)
+		}
+		w := watched{name: c.Name, events: make(map[string]event, len(events))}
+		for _, e := range events {
+			w.events[e.topic] = e
+		}
+		d.contracts[address] = w
+	}
+	return d, nil
+}
+
+// decodes the logs of watched contracts found in the receipts of every block of the epoch
+func (d *Decoder) Decode(e *models.Epoch) error {
+	var failures int
+	var lastErr error
+	for idx := range e.Slots {
+		block := &e.Slots[idx].Block
+		block.Events = nil
+		for _, receipt := range block.Receipts {
+			for _, l := range receipt.Logs {
+				c, ok := d.contracts[strings.ToLower(l.Address)]
+				if !ok || len(l.Topics) == 0 {
+					continue
+				}
+				ev, ok := c.events[strings.ToLower(l.Topics[0])]
+				if !ok {
+					continue
+				}
+				args, err := decodeLog(ev, l)
+				if err != nil {
+					failures++
+					lastErr = fmt.Errorf("log %d of %s (%s.%s): %w", l.LogIndex, receipt.TransactionHash, c.name, ev.name, err)
+					continue
+				}
+				block.Events = append(block.Events, models.ContractEvent{
+					BlockNumber:     block.BlockNumber,
+					BlockRoot:       block.BlockRoot,
+					TransactionHash: receipt.TransactionHash,
+					LogIndex:        l.LogIndex,
+					Address:         strings.ToLower(l.Address),
+					Contract:        c.name,
+					Event:           ev.name,
+					Signature:       ev.signature,
+					Args:            args,
+				})
+			}
+		}
+	}
+	if failures > 0 {
+		return fmt.Errorf("%d logs could not be decoded, last err: %v", failures, lastErr)
+	}
+	return nil
+}
+
+func decodeLog(ev event, l models.Log) (map[string]interface{}, error) {
+	data, err := decodeHex(l.Data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid data, err: %v", err.Error())
+	}
+	return ev.decode(l.Topics, data)
+}
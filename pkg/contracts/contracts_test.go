@@ -0,0 +1,103 @@
+package contracts
+
+import (
+	"encoding/hex"
+	"indexer/pkg/models"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const erc20ABI = `[
+	{"type":"function","name":"transfer","inputs":[{"name":"to","type":"address"},{"name":"value","type":"uint256"}]},
+	{"type":"event","name":"Transfer","anonymous":false,"inputs":[
+		{"name":"from","type":"address","indexed":true},
+		{"name":"to","type":"address","indexed":true},
+		{"name":"value","type":"uint256","indexed":false}
+	]},
+	{"type":"event","name":"Note","anonymous":false,"inputs":[
+		{"name":"who","type":"address","indexed":true},
+		{"name":"memo","type":"string","indexed":false},
+		{"name":"amounts","type":"uint256[]","indexed":false},
+		{"name":"delta","type":"int8","indexed":false},
+		{"name":"pair","type":"tuple","indexed":false,"components":[{"name":"a","type":"bool"},{"name":"b","type":"bytes4"}]}
+	]}
+]`
+
+// left pads a hex string to a 32 byte word
+func word(s string) string {
+	return strings.Repeat("0", 64-len(s)) + s
+}
+
+func TestDecoder_Decode(t *testing.T) {
+	const token = "0x00000000000000000000000000000000000000aa"
+	from := "0x" + word("01")
+	to := "0x" + word("02")
+
+	decoder, err := New([]Contract{{Name: "token", Address: strings.ToUpper(token), ABI: "erc20.json"}}, map[string][]byte{"erc20.json": []byte(erc20ABI)})
+	assert.Nil(t, err, "New() must not fail")
+
+	var noteTopic string
+	for topic, ev := range decoder.contracts[token].events {
+		if ev.name == "Note" {
+			noteTopic = topic
+			assert.Equal(t, "Note(address,string,uint256[],int8,(bool,bytes4))", ev.signature)
+		}
+	}
+
+	// heads take 5 words (0xa0), memo lives at 0xa0 & amounts at 0xe0
+	noteData := "0x" +
+		word("a0") +
+		word("e0") +
+		strings.Repeat("f", 64) +
+		word("1") +
+		"deadbeef" + strings.Repeat("0", 56) +
+		word("5") + hex.EncodeToString([]byte("hello")) + strings.Repeat("0", 54) +
+		word("2") + word("1") + word("2")
+
+	epoch := models.Epoch{Slots: []models.Slot{{Block: models.Block{
+		BlockNumber: 100,
+		Receipts: []models.Receipt{{
+			TransactionHash: "0xtx",
+			Logs: []models.Log{
+				{Address: token, LogIndex: 0, Topics: []string{"0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef", from, to}, Data: "0x" + word("3e8")},
+				{Address: token, LogIndex: 1, Topics: []string{noteTopic, from}, Data: noteData},
+				{Address: "0x00000000000000000000000000000000000000bb", LogIndex: 2, Topics: []string{"0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef", from, to}, Data: "0x"},
+				{Address: token, LogIndex: 3, Topics: []string{"0x01"}, Data: "0x"},
+			},
+		}},
+	}}}}
+
+	err = decoder.Decode(&epoch)
+	assert.Nil(t, err, "Decode() must not fail")
+	events := epoch.Slots[0].Block.Events
+	assert.Len(t, events, 2, "only known events of watched contracts must be decoded")
+	assert.Equal(t, models.ContractEvent{
+		BlockNumber:     100,
+		TransactionHash: "0xtx",
+		LogIndex:        0,
+		Address:         token,
+		Contract:        "token",
+		Event:           "Transfer",
+		Signature:       "Transfer(address,address,uint256)",
+		Args: map[string]interface{}{
+			"from":  "0x0000000000000000000000000000000000000001",
+			"to":    "0x0000000000000000000000000000000000000002",
+			"value": "1000",
+		},
+	}, events[0])
+	assert.Equal(t, map[string]interface{}{
+		"who":     "0x0000000000000000000000000000000000000001",
+		"memo":    "hello",
+		"amounts": []interface{}{"1", "2"},
+		"delta":   "-1",
+		"pair":    map[string]interface{}{"a": true, "b": "0xdeadbeef"},
+	}, events[1].Args)
+
+	// truncated data must be reported, not silently dropped
+	epoch.Slots[0].Block.Receipts[0].Logs[0].Data = "0x01"
+	err = decoder.Decode(&epoch)
+	assert.NotNil(t, err, "Decode() must fail for malformed logs")
+	assert.Len(t, epoch.Slots[0].Block.Events, 1, "well formed logs must still be decoded")
+}
@@ -0,0 +1,183 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"indexer/pkg/config"
+	"io/fs"
+	"os"
+
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/source"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+)
+
+// returned at startup when the database was migrated by a newer binary, whose tables this one may misread
+var ErrSchemaTooNew = errors.New("database schema is newer than this binary expects")
+
+// a single migration, as listed by a dry-run
+type Step struct {
+	Version uint
+	Up      bool
+	// file name of the migration without version & direction, e.g. partitions
+	Name string
+}
+
+func (s Step) String() string {
+	direction := "down"
+	if s.Up {
+		direction = "up"
+	}
+	return fmt.Sprintf("%d %s %s", s.Version, direction, s.Name)
+}
+
+// manages the schema version of a database, up to Latest, the version this binary is built against
+type Migrator struct {
+	m      *migrate.Migrate
+	source source.Driver
+	latest uint
+}
+
+// the migrator of the postgres database of cfg
+func NewMigrator(cfg config.PgCfg) (*Migrator, error) {
+	return newMigrator(files, "migrations", cfg.String(), migrationVersion)
+}
+
+// the migrator of the SQLite database at path, created if missing
+func NewSQLiteMigrator(path string) (*Migrator, error) {
+	return newMigrator(sqliteFiles, "sqlite_migrations", "sqlite://"+path, sqliteMigrationVersion)
+}
+
+func newMigrator(fsys fs.FS, dir, url string, latest uint) (*Migrator, error) {
+	driver, err := iofs.New(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("migration files load failed, err: %v", err.Error())
+	}
+	m, err := migrate.NewWithSourceInstance("iofs", driver, url)
+	if err != nil {
+		return nil, fmt.Errorf("migrate instance creation failed, err: %v", err.Error())
+	}
+	return &Migrator{m: m, source: driver, latest: latest}, nil
+}
+
+func (m *Migrator) Close() {
+	m.m.Close()
+}
+
+func (m *Migrator) Latest() uint {
+	return m.latest
+}
+
+// the current version, 0 before any migration, dirty when a migration failed halfway & has to be forced
+func (m *Migrator) Version() (uint, bool, error) {
+	version, dirty, err := m.m.Version()
+	if errors.Is(err, migrate.ErrNilVersion) {
+		return 0, false, nil
+	}
+	return version, dirty, err
+}
+
+// migrates up to Latest, as done at startup, unless the database is ahead of it
+func (m *Migrator) Startup() error {
+	version, _, err := m.Version()
+	if err != nil {
+		return fmt.Errorf("m.Version() failed, err: %v", err.Error())
+	}
+	if version > m.latest {
+		return fmt.Errorf("%w: version %d, expected at most %d", ErrSchemaTooNew, version, m.latest)
+	}
+	return m.To(m.latest)
+}
+
+// migrates up or down to target, 0 reverts every migration
+func (m *Migrator) To(target uint) error {
+	if target > m.latest {
+		return fmt.Errorf("version %d is beyond the latest known version %d", target, m.latest)
+	}
+	var err error
+	if target == 0 {
+		err = m.m.Down()
+	} else {
+		err = m.m.Migrate(target)
+	}
+	if err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return fmt.Errorf("migration to version %d failed, err: %v", target, err.Error())
+	}
+	return nil
+}
+
+// sets the version without running any migration & clears the dirty flag, once a failed migration was fixed by hand,
+// versions beyond Latest are refused as every startup would fail with ErrSchemaTooNew after them
+func (m *Migrator) Force(version uint) error {
+	if version > m.latest {
+		return fmt.Errorf("version %d is beyond the latest known version %d", version, m.latest)
+	}
+	err := m.m.Force(int(version))
+	if err != nil {
+		return fmt.Errorf("forcing version %d failed, err: %v", version, err.Error())
+	}
+	return nil
+}
+
+// the migrations To would run for target, in order, without running them
+func (m *Migrator) Plan(target uint) ([]Step, error) {
+	if target > m.latest {
+		return nil, fmt.Errorf("version %d is beyond the latest known version %d", target, m.latest)
+	}
+	version, dirty, err := m.Version()
+	if err != nil {
+		return nil, fmt.Errorf("m.Version() failed, err: %v", err.Error())
+	}
+	if dirty {
+		return nil, migrate.ErrDirty{Version: int(version)}
+	}
+	var steps []Step
+	// up from the version after the current one
+	for next := version; next < target; {
+		if next == 0 {
+			next, err = m.source.First()
+		} else {
+			next, err = m.source.Next(next)
+		}
+		if errors.Is(err, os.ErrNotExist) || next > target {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		step, err := m.step(next, true)
+		if err != nil {
+			return nil, err
+		}
+		steps = append(steps, step)
+	}
+	// down from the current version
+	for prev := version; prev > target; {
+		step, err := m.step(prev, false)
+		if err != nil {
+			return nil, err
+		}
+		steps = append(steps, step)
+		prev, err = m.source.Prev(prev)
+		if errors.Is(err, os.ErrNotExist) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return steps, nil
+}
+
+func (m *Migrator) step(version uint, up bool) (Step, error) {
+	read := m.source.ReadDown
+	if up {
+		read = m.source.ReadUp
+	}
+	r, name, err := read(version)
+	if err != nil {
+		return Step{}, fmt.Errorf("migration %d read failed, err: %v", version, err.Error())
+	}
+	r.Close()
+	return Step{Version: version, Up: up, Name: name}, nil
+}
@@ -0,0 +1,58 @@
+package db
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMigrator(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "indexer.db")
+	m, err := NewSQLiteMigrator(path)
+	assert.NoError(t, err)
+	defer m.Close()
+
+	version, dirty, err := m.Version()
+	assert.NoError(t, err)
+	assert.Equal(t, uint(0), version)
+	assert.False(t, dirty)
+
+	// dry-runs change nothing
+	steps, err := m.Plan(m.Latest())
+	assert.NoError(t, err)
+	assert.Equal(t, []Step{{1, true, "tables"}, {2, true, "partitions"}}, steps)
+	version, _, _ = m.Version()
+	assert.Equal(t, uint(0), version)
+
+	assert.NoError(t, m.To(1))
+	version, _, _ = m.Version()
+	assert.Equal(t, uint(1), version)
+	steps, err = m.Plan(2)
+	assert.NoError(t, err)
+	assert.Equal(t, []Step{{2, true, "partitions"}}, steps)
+
+	assert.NoError(t, m.Startup())
+	version, _, _ = m.Version()
+	assert.Equal(t, uint(2), version)
+	steps, err = m.Plan(0)
+	assert.NoError(t, err)
+	assert.Equal(t, []Step{{2, false, "partitions"}, {1, false, "tables"}}, steps)
+
+	assert.NoError(t, m.To(0))
+	version, _, _ = m.Version()
+	assert.Equal(t, uint(0), version)
+	assert.Error(t, m.To(m.Latest()+1), "versions beyond the binary must be refused")
+
+	assert.Error(t, m.Force(m.Latest()+1), "forcing a version beyond the binary must be refused")
+	version, _, _ = m.Version()
+	assert.Equal(t, uint(0), version)
+	assert.NoError(t, m.Force(1))
+	version, _, _ = m.Version()
+	assert.Equal(t, uint(1), version)
+
+	// as left by a newer binary
+	assert.NoError(t, m.m.Force(int(m.Latest()+1)))
+	assert.ErrorIs(t, m.Startup(), ErrSchemaTooNew)
+	assert.ErrorIs(t, MigrateSQLite(path), ErrSchemaTooNew)
+}
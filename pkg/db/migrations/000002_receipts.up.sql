@@ -0,0 +1,13 @@
+BEGIN;
+CREATE TABLE IF NOT EXISTS receipts (
+    transaction_hash VARCHAR NOT NULL,
+    transaction_index INT NOT NULL,
+    block_number BIGINT NOT NULL,
+    status SMALLINT NOT NULL,
+    gas_used BIGINT NOT NULL,
+    effective_gas_price BIGINT NOT NULL,
+    log_count INT NOT NULL,
+    CONSTRAINT pk_receipts PRIMARY KEY(transaction_hash),
+    CONSTRAINT fk_receipts_blocks FOREIGN KEY(block_number) REFERENCES blocks(block_number) ON DELETE CASCADE
+);
+COMMIT;
\ No newline at end of file
@@ -0,0 +1,3 @@
+BEGIN;
+DROP TABLE IF EXISTS contract_events;
+COMMIT;
\ No newline at end of file
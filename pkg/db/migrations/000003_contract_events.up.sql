@@ -0,0 +1,16 @@
+BEGIN;
+CREATE TABLE IF NOT EXISTS contract_events (
+    block_number BIGINT NOT NULL,
+    transaction_hash VARCHAR NOT NULL,
+    log_index INT NOT NULL,
+    address VARCHAR NOT NULL,
+    contract VARCHAR NOT NULL,
+    event VARCHAR NOT NULL,
+    signature VARCHAR NOT NULL,
+    args JSONB NOT NULL,
+    CONSTRAINT pk_contract_events PRIMARY KEY(block_number, log_index),
+    CONSTRAINT fk_contract_events_blocks FOREIGN KEY(block_number) REFERENCES blocks(block_number) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_contract_events_contract_event ON contract_events(contract, event);
+CREATE INDEX IF NOT EXISTS idx_contract_events_address ON contract_events(address);
+COMMIT;
\ No newline at end of file
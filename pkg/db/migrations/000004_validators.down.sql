@@ -0,0 +1,4 @@
+BEGIN;
+DROP TABLE IF EXISTS validator_activity;
+DROP TABLE IF EXISTS watched_validators;
+COMMIT;
\ No newline at end of file
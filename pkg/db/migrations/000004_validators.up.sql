@@ -0,0 +1,21 @@
+BEGIN;
+CREATE TABLE IF NOT EXISTS watched_validators (
+    validator VARCHAR NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    CONSTRAINT pk_watched_validators PRIMARY KEY(validator)
+);
+CREATE TABLE IF NOT EXISTS validator_activity (
+    validator_index BIGINT NOT NULL,
+    epoch_number BIGINT NOT NULL,
+    proposer_duties INT NOT NULL,
+    proposed INT NOT NULL,
+    attester_duties INT NOT NULL,
+    attestations_included INT NOT NULL,
+    inclusion_delay INT NOT NULL,
+    sync_duties INT NOT NULL,
+    sync_participated INT NOT NULL,
+    balance BIGINT NOT NULL,
+    reward BIGINT NOT NULL,
+    CONSTRAINT pk_validator_activity PRIMARY KEY(validator_index, epoch_number)
+);
+COMMIT;
\ No newline at end of file
@@ -0,0 +1,3 @@
+BEGIN;
+DROP TABLE IF EXISTS epoch_stats;
+COMMIT;
\ No newline at end of file
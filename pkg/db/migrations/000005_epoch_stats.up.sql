@@ -0,0 +1,17 @@
+BEGIN;
+CREATE TABLE IF NOT EXISTS epoch_stats (
+    epoch_number BIGINT NOT NULL,
+    proposed_slots INT NOT NULL,
+    missed_slots INT NOT NULL,
+    no_of_transactions INT NOT NULL,
+    gas_used BIGINT NOT NULL,
+    gas_limit BIGINT NOT NULL,
+    avg_gas_used BIGINT NOT NULL,
+    gas_utilisation DOUBLE PRECISION NOT NULL,
+    min_block_time DOUBLE PRECISION NOT NULL,
+    max_block_time DOUBLE PRECISION NOT NULL,
+    avg_block_time DOUBLE PRECISION NOT NULL,
+    CONSTRAINT pk_epoch_stats PRIMARY KEY(epoch_number),
+    CONSTRAINT fk_epoch_stats_epochs FOREIGN KEY(epoch_number) REFERENCES epochs(epoch_number) ON DELETE CASCADE
+);
+COMMIT;
\ No newline at end of file
@@ -0,0 +1,7 @@
+BEGIN;
+DROP INDEX IF EXISTS idx_blocks_builder;
+ALTER TABLE blocks DROP COLUMN IF EXISTS mev_boost;
+ALTER TABLE blocks DROP COLUMN IF EXISTS builder;
+ALTER TABLE blocks DROP COLUMN IF EXISTS extra_data;
+ALTER TABLE blocks DROP COLUMN IF EXISTS fee_recipient;
+COMMIT;
\ No newline at end of file
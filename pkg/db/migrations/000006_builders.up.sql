@@ -0,0 +1,7 @@
+BEGIN;
+ALTER TABLE blocks ADD COLUMN IF NOT EXISTS fee_recipient VARCHAR NOT NULL DEFAULT '';
+ALTER TABLE blocks ADD COLUMN IF NOT EXISTS extra_data VARCHAR NOT NULL DEFAULT '';
+ALTER TABLE blocks ADD COLUMN IF NOT EXISTS builder VARCHAR NOT NULL DEFAULT '';
+ALTER TABLE blocks ADD COLUMN IF NOT EXISTS mev_boost BOOLEAN NOT NULL DEFAULT FALSE;
+CREATE INDEX IF NOT EXISTS idx_blocks_builder ON blocks(builder);
+COMMIT;
\ No newline at end of file
@@ -0,0 +1,4 @@
+BEGIN;
+DROP TABLE IF EXISTS fork_schedule;
+ALTER TABLE blocks DROP COLUMN IF EXISTS version;
+COMMIT;
\ No newline at end of file
@@ -0,0 +1,10 @@
+BEGIN;
+ALTER TABLE blocks ADD COLUMN IF NOT EXISTS version VARCHAR NOT NULL DEFAULT '';
+CREATE TABLE IF NOT EXISTS fork_schedule (
+    name VARCHAR NOT NULL,
+    previous_version VARCHAR NOT NULL,
+    current_version VARCHAR NOT NULL,
+    epoch BIGINT NOT NULL,
+    CONSTRAINT pk_fork_schedule PRIMARY KEY(name)
+);
+COMMIT;
\ No newline at end of file
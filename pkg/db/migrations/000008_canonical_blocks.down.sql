@@ -0,0 +1,25 @@
+BEGIN;
+-- competing blocks can't be keyed by number, only canonical ones are kept
+DELETE FROM blocks WHERE NOT canonical;
+
+DROP INDEX IF EXISTS idx_contract_events_block_number;
+ALTER TABLE contract_events DROP CONSTRAINT IF EXISTS fk_contract_events_blocks;
+ALTER TABLE contract_events DROP CONSTRAINT IF EXISTS pk_contract_events;
+ALTER TABLE contract_events DROP COLUMN IF EXISTS block_root;
+ALTER TABLE contract_events ADD CONSTRAINT pk_contract_events PRIMARY KEY(block_number, log_index);
+
+DROP INDEX IF EXISTS idx_receipts_transaction_hash;
+ALTER TABLE receipts DROP CONSTRAINT IF EXISTS fk_receipts_blocks;
+ALTER TABLE receipts DROP CONSTRAINT IF EXISTS pk_receipts;
+ALTER TABLE receipts DROP COLUMN IF EXISTS block_root;
+ALTER TABLE receipts ADD CONSTRAINT pk_receipts PRIMARY KEY(transaction_hash);
+
+DROP INDEX IF EXISTS idx_blocks_slot_number;
+DROP INDEX IF EXISTS idx_blocks_block_number;
+ALTER TABLE blocks DROP CONSTRAINT IF EXISTS pk_blocks;
+ALTER TABLE blocks DROP COLUMN IF EXISTS canonical;
+ALTER TABLE blocks DROP COLUMN IF EXISTS parent_root;
+ALTER TABLE blocks ADD CONSTRAINT pk_blocks PRIMARY KEY(block_number);
+ALTER TABLE receipts ADD CONSTRAINT fk_receipts_blocks FOREIGN KEY(block_number) REFERENCES blocks(block_number) ON DELETE CASCADE;
+ALTER TABLE contract_events ADD CONSTRAINT fk_contract_events_blocks FOREIGN KEY(block_number) REFERENCES blocks(block_number) ON DELETE CASCADE;
+COMMIT;
\ No newline at end of file
@@ -0,0 +1,29 @@
+BEGIN;
+-- blocks are keyed by root, so that competing blocks of a slot can be stored side by side
+ALTER TABLE receipts DROP CONSTRAINT IF EXISTS fk_receipts_blocks;
+ALTER TABLE contract_events DROP CONSTRAINT IF EXISTS fk_contract_events_blocks;
+ALTER TABLE blocks DROP CONSTRAINT IF EXISTS pk_blocks;
+ALTER TABLE blocks ADD COLUMN IF NOT EXISTS parent_root VARCHAR NOT NULL DEFAULT '';
+ALTER TABLE blocks ADD COLUMN IF NOT EXISTS canonical BOOLEAN NOT NULL DEFAULT TRUE;
+ALTER TABLE blocks ADD CONSTRAINT pk_blocks PRIMARY KEY(block_root);
+CREATE INDEX IF NOT EXISTS idx_blocks_block_number ON blocks(block_number);
+CREATE INDEX IF NOT EXISTS idx_blocks_slot_number ON blocks(slot_number);
+
+ALTER TABLE receipts ADD COLUMN IF NOT EXISTS block_root VARCHAR;
+UPDATE receipts SET block_root = blocks.block_root FROM blocks WHERE blocks.block_number = receipts.block_number;
+DELETE FROM receipts WHERE block_root IS NULL;
+ALTER TABLE receipts ALTER COLUMN block_root SET NOT NULL;
+ALTER TABLE receipts DROP CONSTRAINT IF EXISTS pk_receipts;
+ALTER TABLE receipts ADD CONSTRAINT pk_receipts PRIMARY KEY(block_root, transaction_index);
+ALTER TABLE receipts ADD CONSTRAINT fk_receipts_blocks FOREIGN KEY(block_root) REFERENCES blocks(block_root) ON DELETE CASCADE;
+CREATE INDEX IF NOT EXISTS idx_receipts_transaction_hash ON receipts(transaction_hash);
+
+ALTER TABLE contract_events ADD COLUMN IF NOT EXISTS block_root VARCHAR;
+UPDATE contract_events SET block_root = blocks.block_root FROM blocks WHERE blocks.block_number = contract_events.block_number;
+DELETE FROM contract_events WHERE block_root IS NULL;
+ALTER TABLE contract_events ALTER COLUMN block_root SET NOT NULL;
+ALTER TABLE contract_events DROP CONSTRAINT IF EXISTS pk_contract_events;
+ALTER TABLE contract_events ADD CONSTRAINT pk_contract_events PRIMARY KEY(block_root, log_index);
+ALTER TABLE contract_events ADD CONSTRAINT fk_contract_events_blocks FOREIGN KEY(block_root) REFERENCES blocks(block_root) ON DELETE CASCADE;
+CREATE INDEX IF NOT EXISTS idx_contract_events_block_number ON contract_events(block_number);
+COMMIT;
\ No newline at end of file
@@ -0,0 +1,5 @@
+BEGIN;
+ALTER TABLE blocks DROP COLUMN IF EXISTS revision;
+ALTER TABLE slots DROP COLUMN IF EXISTS revision;
+ALTER TABLE epochs DROP COLUMN IF EXISTS revision;
+COMMIT;
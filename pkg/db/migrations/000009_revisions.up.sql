@@ -0,0 +1,6 @@
+BEGIN;
+-- bumped whenever a re-processed row differs from the stored one, see models.ConflictVersion
+ALTER TABLE epochs ADD COLUMN IF NOT EXISTS revision INT NOT NULL DEFAULT 1;
+ALTER TABLE slots ADD COLUMN IF NOT EXISTS revision INT NOT NULL DEFAULT 1;
+ALTER TABLE blocks ADD COLUMN IF NOT EXISTS revision INT NOT NULL DEFAULT 1;
+COMMIT;
@@ -0,0 +1,4 @@
+BEGIN;
+-- epochs are read with their slots & slots with their blocks, blocks(slot_number) exists since 000008
+CREATE INDEX IF NOT EXISTS idx_slots_epoch_number ON slots(epoch_number);
+COMMIT;
@@ -0,0 +1,57 @@
+BEGIN;
+ALTER TABLE slots RENAME TO slots_partitioned;
+ALTER TABLE slots_partitioned DROP CONSTRAINT IF EXISTS pk_slots;
+DROP INDEX IF EXISTS idx_slots_epoch_number;
+ALTER TABLE blocks RENAME TO blocks_partitioned;
+ALTER TABLE blocks_partitioned DROP CONSTRAINT IF EXISTS fk_blocks_slots;
+ALTER TABLE blocks_partitioned DROP CONSTRAINT IF EXISTS pk_blocks;
+DROP INDEX IF EXISTS idx_blocks_builder;
+DROP INDEX IF EXISTS idx_blocks_block_number;
+DROP INDEX IF EXISTS idx_blocks_slot_number;
+
+CREATE TABLE slots (
+    slot_number BIGINT NOT NULL,
+    start_time TIMESTAMP NOT NULL,
+    end_time TIMESTAMP NOT NULL,
+    epoch_number BIGINT NOT NULL,
+    revision INT NOT NULL DEFAULT 1,
+    CONSTRAINT pk_slots PRIMARY KEY(slot_number),
+    CONSTRAINT fk_slots_epochs FOREIGN KEY(epoch_number) REFERENCES epochs(epoch_number) ON DELETE CASCADE
+);
+CREATE INDEX idx_slots_epoch_number ON slots(epoch_number);
+INSERT INTO slots SELECT slot_number, start_time, end_time, epoch_number, revision FROM slots_partitioned;
+
+CREATE TABLE blocks (
+    block_number BIGINT NOT NULL,
+    block_root VARCHAR NOT NULL,
+    parent_root VARCHAR NOT NULL DEFAULT '',
+    canonical BOOLEAN NOT NULL DEFAULT TRUE,
+    state_root VARCHAR NOT NULL,
+    slot_number BIGINT NOT NULL,
+    gas_limit BIGINT NOT NULL,
+    gas_used BIGINT NOT NULL,
+    no_of_transactions INT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    version VARCHAR NOT NULL DEFAULT '',
+    fee_recipient VARCHAR NOT NULL DEFAULT '',
+    extra_data VARCHAR NOT NULL DEFAULT '',
+    builder VARCHAR NOT NULL DEFAULT '',
+    mev_boost BOOLEAN NOT NULL DEFAULT FALSE,
+    revision INT NOT NULL DEFAULT 1,
+    CONSTRAINT pk_blocks PRIMARY KEY(block_root),
+    CONSTRAINT fk_blocks_slots FOREIGN KEY(slot_number) REFERENCES slots(slot_number) ON DELETE CASCADE
+);
+CREATE INDEX idx_blocks_builder ON blocks(builder);
+CREATE INDEX idx_blocks_block_number ON blocks(block_number);
+CREATE INDEX idx_blocks_slot_number ON blocks(slot_number);
+INSERT INTO blocks SELECT block_number, block_root, parent_root, canonical, state_root, slot_number, gas_limit, gas_used,
+    no_of_transactions, created_at, version, fee_recipient, extra_data, builder, mev_boost, revision FROM blocks_partitioned;
+
+DROP TABLE blocks_partitioned;
+DROP TABLE slots_partitioned;
+
+DELETE FROM receipts WHERE block_root NOT IN (SELECT block_root FROM blocks);
+ALTER TABLE receipts ADD CONSTRAINT fk_receipts_blocks FOREIGN KEY(block_root) REFERENCES blocks(block_root) ON DELETE CASCADE;
+DELETE FROM contract_events WHERE block_root NOT IN (SELECT block_root FROM blocks);
+ALTER TABLE contract_events ADD CONSTRAINT fk_contract_events_blocks FOREIGN KEY(block_root) REFERENCES blocks(block_root) ON DELETE CASCADE;
+COMMIT;
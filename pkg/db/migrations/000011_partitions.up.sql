@@ -0,0 +1,79 @@
+BEGIN;
+-- slots & blocks are range partitioned by slot number, in partitions of 1024 slots named after their first slot,
+-- which db.Partitions creates ahead of the head & retention drops whole. The primary key of blocks has to include
+-- the slot number, receipts & contract events can no longer reference it & are deleted along with the partitions
+ALTER TABLE receipts DROP CONSTRAINT IF EXISTS fk_receipts_blocks;
+ALTER TABLE contract_events DROP CONSTRAINT IF EXISTS fk_contract_events_blocks;
+
+ALTER TABLE blocks RENAME TO blocks_unpartitioned;
+ALTER TABLE blocks_unpartitioned DROP CONSTRAINT IF EXISTS fk_blocks_slots;
+ALTER TABLE blocks_unpartitioned DROP CONSTRAINT IF EXISTS pk_blocks;
+DROP INDEX IF EXISTS idx_blocks_builder;
+DROP INDEX IF EXISTS idx_blocks_block_number;
+DROP INDEX IF EXISTS idx_blocks_slot_number;
+ALTER TABLE slots RENAME TO slots_unpartitioned;
+ALTER TABLE slots_unpartitioned DROP CONSTRAINT IF EXISTS pk_slots;
+DROP INDEX IF EXISTS idx_slots_epoch_number;
+
+CREATE TABLE slots (
+    slot_number BIGINT NOT NULL,
+    start_time TIMESTAMP NOT NULL,
+    end_time TIMESTAMP NOT NULL,
+    epoch_number BIGINT NOT NULL,
+    revision INT NOT NULL DEFAULT 1,
+    CONSTRAINT pk_slots PRIMARY KEY(slot_number),
+    CONSTRAINT fk_slots_epochs FOREIGN KEY(epoch_number) REFERENCES epochs(epoch_number) ON DELETE CASCADE
+) PARTITION BY RANGE (slot_number);
+CREATE INDEX idx_slots_epoch_number ON slots(epoch_number);
+
+CREATE TABLE blocks (
+    block_number BIGINT NOT NULL,
+    block_root VARCHAR NOT NULL,
+    parent_root VARCHAR NOT NULL DEFAULT '',
+    canonical BOOLEAN NOT NULL DEFAULT TRUE,
+    state_root VARCHAR NOT NULL,
+    slot_number BIGINT NOT NULL,
+    gas_limit BIGINT NOT NULL,
+    gas_used BIGINT NOT NULL,
+    no_of_transactions INT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    version VARCHAR NOT NULL DEFAULT '',
+    fee_recipient VARCHAR NOT NULL DEFAULT '',
+    extra_data VARCHAR NOT NULL DEFAULT '',
+    builder VARCHAR NOT NULL DEFAULT '',
+    mev_boost BOOLEAN NOT NULL DEFAULT FALSE,
+    revision INT NOT NULL DEFAULT 1,
+    CONSTRAINT pk_blocks PRIMARY KEY(block_root, slot_number),
+    CONSTRAINT fk_blocks_slots FOREIGN KEY(slot_number) REFERENCES slots(slot_number) ON DELETE CASCADE
+) PARTITION BY RANGE (slot_number);
+CREATE INDEX idx_blocks_builder ON blocks(builder);
+CREATE INDEX idx_blocks_block_number ON blocks(block_number);
+CREATE INDEX idx_blocks_slot_number ON blocks(slot_number);
+
+-- partitions of the slots stored so far
+DO $$
+DECLARE
+    width CONSTANT BIGINT := 1024;
+    first BIGINT;
+    last BIGINT;
+    start BIGINT;
+BEGIN
+    SELECT MIN(slot_number) / width * width, MAX(slot_number) / width * width INTO first, last FROM slots_unpartitioned;
+    start := first;
+    WHILE start <= last LOOP
+        EXECUTE format('CREATE TABLE %I PARTITION OF slots FOR VALUES FROM (%s) TO (%s)', 'slots_' || start, start, start + width);
+        EXECUTE format('CREATE TABLE %I PARTITION OF blocks FOR VALUES FROM (%s) TO (%s)', 'blocks_' || start, start, start + width);
+        start := start + width;
+    END LOOP;
+END $$;
+
+INSERT INTO slots (slot_number, start_time, end_time, epoch_number, revision)
+    SELECT slot_number, start_time, end_time, epoch_number, revision FROM slots_unpartitioned;
+INSERT INTO blocks (block_number, block_root, parent_root, canonical, state_root, slot_number, gas_limit, gas_used,
+        no_of_transactions, created_at, version, fee_recipient, extra_data, builder, mev_boost, revision)
+    SELECT block_number, block_root, parent_root, canonical, state_root, slot_number, gas_limit, gas_used,
+        no_of_transactions, created_at, version, fee_recipient, extra_data, builder, mev_boost, revision
+    FROM blocks_unpartitioned;
+DROP TABLE blocks_unpartitioned;
+DROP TABLE slots_unpartitioned;
+COMMIT;
@@ -0,0 +1,50 @@
+BEGIN;
+ALTER TABLE fork_schedule DROP CONSTRAINT IF EXISTS ck_fork_schedule_epoch;
+
+ALTER TABLE validator_activity DROP CONSTRAINT IF EXISTS ck_validator_activity_numbers;
+ALTER TABLE validator_activity ALTER COLUMN balance TYPE BIGINT;
+
+ALTER TABLE watched_validators
+    ALTER COLUMN created_at TYPE TIMESTAMP USING created_at AT TIME ZONE 'UTC';
+
+ALTER TABLE contract_events DROP CONSTRAINT IF EXISTS ck_contract_events_numbers;
+ALTER TABLE contract_events
+    ALTER COLUMN block_root TYPE VARCHAR USING hex_of(block_root),
+    ALTER COLUMN transaction_hash TYPE VARCHAR USING hex_of(transaction_hash);
+
+ALTER TABLE receipts DROP CONSTRAINT IF EXISTS ck_receipts_numbers;
+ALTER TABLE receipts
+    ALTER COLUMN block_root TYPE VARCHAR USING hex_of(block_root),
+    ALTER COLUMN transaction_hash TYPE VARCHAR USING hex_of(transaction_hash),
+    ALTER COLUMN gas_used TYPE BIGINT,
+    ALTER COLUMN effective_gas_price TYPE BIGINT;
+
+ALTER TABLE blocks DROP CONSTRAINT IF EXISTS ck_blocks_numbers;
+ALTER TABLE blocks ALTER COLUMN parent_root DROP DEFAULT;
+ALTER TABLE blocks
+    ALTER COLUMN block_root TYPE VARCHAR USING hex_of(block_root),
+    ALTER COLUMN parent_root TYPE VARCHAR USING hex_of(parent_root),
+    ALTER COLUMN state_root TYPE VARCHAR USING hex_of(state_root),
+    ALTER COLUMN gas_limit TYPE BIGINT,
+    ALTER COLUMN gas_used TYPE BIGINT,
+    ALTER COLUMN created_at TYPE TIMESTAMP USING created_at AT TIME ZONE 'UTC';
+ALTER TABLE blocks ALTER COLUMN parent_root SET DEFAULT '';
+
+ALTER TABLE slots DROP CONSTRAINT IF EXISTS ck_slots_numbers;
+ALTER TABLE slots
+    ALTER COLUMN start_time TYPE TIMESTAMP USING start_time AT TIME ZONE 'UTC',
+    ALTER COLUMN end_time TYPE TIMESTAMP USING end_time AT TIME ZONE 'UTC';
+
+ALTER TABLE epoch_stats DROP CONSTRAINT IF EXISTS ck_epoch_stats_gas;
+ALTER TABLE epoch_stats
+    ALTER COLUMN gas_used TYPE BIGINT,
+    ALTER COLUMN gas_limit TYPE BIGINT,
+    ALTER COLUMN avg_gas_used TYPE BIGINT;
+
+ALTER TABLE epochs DROP CONSTRAINT IF EXISTS ck_epochs_epoch_number;
+ALTER TABLE epochs
+    ALTER COLUMN start_time TYPE TIMESTAMP USING start_time AT TIME ZONE 'UTC',
+    ALTER COLUMN end_time TYPE TIMESTAMP USING end_time AT TIME ZONE 'UTC';
+
+DROP FUNCTION IF EXISTS hex_of(BYTEA);
+COMMIT;
@@ -0,0 +1,64 @@
+BEGIN;
+-- schema v2: roots & hashes are stored as raw bytes, times with their time zone & unsigned 64 bit quantities as
+-- NUMERIC, numbers are kept as BIGINT & checked to be non-negative. Every row is rewritten through the conversions
+-- below, TIMESTAMP values are taken to be UTC as written by v1
+
+-- renders roots & hashes as the models expect them, no bytes render as an empty string
+CREATE OR REPLACE FUNCTION hex_of(b BYTEA) RETURNS VARCHAR LANGUAGE SQL IMMUTABLE STRICT AS $$
+    SELECT CASE WHEN octet_length(b) = 0 THEN '' ELSE '0x' || encode(b, 'hex') END
+$$;
+-- the reverse of hex_of, only needed to convert v1 rows
+CREATE OR REPLACE FUNCTION pg_temp.bytes_of(s VARCHAR) RETURNS BYTEA LANGUAGE SQL IMMUTABLE STRICT AS $$
+    SELECT CASE WHEN s = '' THEN ''::BYTEA ELSE decode(substr(s, 3), 'hex') END
+$$;
+
+ALTER TABLE epochs
+    ALTER COLUMN start_time TYPE TIMESTAMPTZ USING start_time AT TIME ZONE 'UTC',
+    ALTER COLUMN end_time TYPE TIMESTAMPTZ USING end_time AT TIME ZONE 'UTC',
+    ADD CONSTRAINT ck_epochs_epoch_number CHECK (epoch_number >= 0);
+
+ALTER TABLE epoch_stats
+    ALTER COLUMN gas_used TYPE NUMERIC(20, 0),
+    ALTER COLUMN gas_limit TYPE NUMERIC(20, 0),
+    ALTER COLUMN avg_gas_used TYPE NUMERIC(20, 0),
+    ADD CONSTRAINT ck_epoch_stats_gas CHECK (gas_used >= 0 AND gas_limit >= 0 AND avg_gas_used >= 0);
+
+ALTER TABLE slots
+    ALTER COLUMN start_time TYPE TIMESTAMPTZ USING start_time AT TIME ZONE 'UTC',
+    ALTER COLUMN end_time TYPE TIMESTAMPTZ USING end_time AT TIME ZONE 'UTC',
+    ADD CONSTRAINT ck_slots_numbers CHECK (slot_number >= 0 AND epoch_number >= 0);
+
+ALTER TABLE blocks ALTER COLUMN parent_root DROP DEFAULT;
+ALTER TABLE blocks
+    ALTER COLUMN block_root TYPE BYTEA USING pg_temp.bytes_of(block_root),
+    ALTER COLUMN parent_root TYPE BYTEA USING pg_temp.bytes_of(parent_root),
+    ALTER COLUMN state_root TYPE BYTEA USING pg_temp.bytes_of(state_root),
+    ALTER COLUMN gas_limit TYPE NUMERIC(20, 0),
+    ALTER COLUMN gas_used TYPE NUMERIC(20, 0),
+    ALTER COLUMN created_at TYPE TIMESTAMPTZ USING created_at AT TIME ZONE 'UTC',
+    ADD CONSTRAINT ck_blocks_numbers CHECK (block_number >= 0 AND slot_number >= 0 AND gas_limit >= 0 AND gas_used >= 0);
+ALTER TABLE blocks ALTER COLUMN parent_root SET DEFAULT ''::BYTEA;
+
+ALTER TABLE receipts
+    ALTER COLUMN block_root TYPE BYTEA USING pg_temp.bytes_of(block_root),
+    ALTER COLUMN transaction_hash TYPE BYTEA USING pg_temp.bytes_of(transaction_hash),
+    ALTER COLUMN gas_used TYPE NUMERIC(20, 0),
+    ALTER COLUMN effective_gas_price TYPE NUMERIC(20, 0),
+    ADD CONSTRAINT ck_receipts_numbers CHECK (block_number >= 0 AND transaction_index >= 0 AND gas_used >= 0
+        AND effective_gas_price >= 0);
+
+ALTER TABLE contract_events
+    ALTER COLUMN block_root TYPE BYTEA USING pg_temp.bytes_of(block_root),
+    ALTER COLUMN transaction_hash TYPE BYTEA USING pg_temp.bytes_of(transaction_hash),
+    ADD CONSTRAINT ck_contract_events_numbers CHECK (block_number >= 0 AND log_index >= 0);
+
+ALTER TABLE watched_validators
+    ALTER COLUMN created_at TYPE TIMESTAMPTZ USING created_at AT TIME ZONE 'UTC';
+
+ALTER TABLE validator_activity
+    ALTER COLUMN balance TYPE NUMERIC(20, 0),
+    ADD CONSTRAINT ck_validator_activity_numbers CHECK (validator_index >= 0 AND epoch_number >= 0 AND balance >= 0);
+
+ALTER TABLE fork_schedule
+    ADD CONSTRAINT ck_fork_schedule_epoch CHECK (epoch >= 0);
+COMMIT;
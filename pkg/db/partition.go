@@ -0,0 +1,150 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+const (
+	// slots per partition of slots & blocks, as set up by migration 000011, 32 epochs of 32 slots
+	PartitionSlots = 1024
+	// partitions created past the one holding the head, so that writes never wait for one
+	partitionsAhead = 2
+)
+
+// tables partitioned by slot number, blocks reference slots & are dropped first
+var partitionedTables = []string{"blocks", "slots"}
+
+// manages the range partitions of slots & blocks, each named after its first slot, e.g. slots_1024
+type Partitions struct {
+	pool *pgxpool.Pool
+	mu   sync.Mutex
+	// first slots of the partitions known to exist
+	known map[uint64]bool
+}
+
+func NewPartitions(pool *pgxpool.Pool) *Partitions {
+	return &Partitions{pool: pool, known: make(map[uint64]bool)}
+}
+
+// creates the partitions missing for slots from to to & the partitions ahead of them, partitions created
+// before are remembered so that this is cheap to call before every write
+func (p *Partitions) Ensure(ctx context.Context, from, to uint64) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, start := range partitionsOf(from, to+partitionsAhead*PartitionSlots) {
+		if p.known[start] {
+			continue
+		}
+		// slots first as blocks reference them
+		for idx := len(partitionedTables) - 1; idx >= 0; idx-- {
+			table := partitionedTables[idx]
+			_, err := p.pool.Exec(ctx, fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s_%d PARTITION OF %s FOR VALUES FROM (%d) TO (%d)",
+				table, start, table, start, start+PartitionSlots))
+			if err != nil {
+				return fmt.Errorf("%s partition creation failed, err: %v", table, err.Error())
+			}
+		}
+		p.known[start] = true
+	}
+	return nil
+}
+
+// drops the partitions holding slots below slot only, along with the receipts & contract events of their blocks,
+// & returns how many were dropped
+func (p *Partitions) DropBefore(ctx context.Context, slot uint64) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	starts, err := p.list(ctx)
+	if err != nil {
+		return 0, err
+	}
+	dropped := 0
+	for _, start := range starts {
+		if start+PartitionSlots > slot {
+			continue
+		}
+		err = p.drop(ctx, start)
+		if err != nil {
+			return dropped, err
+		}
+		delete(p.known, start)
+		dropped++
+	}
+	return dropped, nil
+}
+
+func (p *Partitions) drop(ctx context.Context, start uint64) error {
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	success := false
+	defer func() {
+		if success {
+			tx.Commit(ctx)
+
+		} else {
+			tx.Rollback(ctx)
+		}
+	}()
+	for _, table := range []string{"receipts", "contract_events"} {
+		_, err = tx.Exec(ctx, fmt.Sprintf("DELETE FROM %s WHERE block_root IN (SELECT block_root FROM blocks_%d)", table, start))
+		if err != nil {
+			return fmt.Errorf("%s delete query failed, err: %v", table, err.Error())
+		}
+	}
+	for _, table := range partitionedTables {
+		_, err = tx.Exec(ctx, fmt.Sprintf("DROP TABLE IF EXISTS %s_%d", table, start))
+		if err != nil {
+			return fmt.Errorf("%s partition drop failed, err: %v", table, err.Error())
+		}
+	}
+	success = true
+	return nil
+}
+
+// first slots of the existing partitions of slots
+func (p *Partitions) list(ctx context.Context) ([]uint64, error) {
+	rows, err := p.pool.Query(ctx, `SELECT c.relname FROM pg_inherits i JOIN pg_class c ON c.oid = i.inhrelid
+		WHERE i.inhparent = 'slots'::regclass`)
+	if err != nil {
+		return nil, fmt.Errorf("slots partitions query failed, err: %v", err.Error())
+	}
+	defer rows.Close()
+	var starts []uint64
+	for rows.Next() {
+		var name string
+		err = rows.Scan(&name)
+		if err != nil {
+			return nil, fmt.Errorf("slots partitions query scan failed, err: %v", err.Error())
+		}
+		// partitions not created by Partitions are left alone
+		start, err := strconv.ParseUint(strings.TrimPrefix(name, "slots_"), 10, 64)
+		if err != nil || start%PartitionSlots != 0 {
+			continue
+		}
+		starts = append(starts, start)
+	}
+	if rows.Err() != nil {
+		return nil, fmt.Errorf("slots partitions query failed, err: %v", rows.Err().Error())
+	}
+	return starts, nil
+}
+
+// first slots of the partitions holding slots from to to
+func partitionsOf(from, to uint64) []uint64 {
+	if to < from {
+		return nil
+	}
+	var starts []uint64
+	for start := from / PartitionSlots * PartitionSlots; start <= to; start += PartitionSlots {
+		starts = append(starts, start)
+	}
+	return starts
+}
@@ -0,0 +1,25 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_partitionsOf(t *testing.T) {
+	tests := []struct {
+		name     string
+		from, to uint64
+		want     []uint64
+	}{
+		{name: "a single slot", from: 5, to: 5, want: []uint64{0}},
+		{name: "within a partition", from: 1024, to: 2047, want: []uint64{1024}},
+		{name: "across partitions", from: 1000, to: 3072, want: []uint64{0, 1024, 2048, 3072}},
+		{name: "an empty range", from: 10, to: 9, want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, partitionsOf(tt.from, tt.to))
+		})
+	}
+}
@@ -3,45 +3,39 @@ package db
 import (
 	"context"
 	"embed"
-	"errors"
-	"fmt"
 	"indexer/pkg/config"
 
-	"github.com/golang-migrate/migrate/v4"
 	_ "github.com/golang-migrate/migrate/v4/database/postgres"
-	"github.com/golang-migrate/migrate/v4/source/iofs"
 	"github.com/jackc/pgx/v4/pgxpool"
 	_ "github.com/lib/pq"
 )
 
-const migrationVersion = 1
+// the schema version this binary is built against
+const migrationVersion = 12
 
 //go:embed migrations/*.sql
 var files embed.FS
 
-// connects to postgres & returns a pool
+// connects to postgres & returns a pool, sessions are in UTC so that times are rendered as they were written
 func Connect(ctx context.Context, cfg config.PgCfg) (*pgxpool.Pool, error) {
-	pool, err := pgxpool.Connect(ctx, cfg.String())
+	poolCfg, err := pgxpool.ParseConfig(cfg.String())
+	if err != nil {
+		return nil, err
+	}
+	poolCfg.ConnConfig.RuntimeParams["timezone"] = "UTC"
+	pool, err := pgxpool.ConnectConfig(ctx, poolCfg)
 	if err != nil {
 		return nil, err
 	}
 	return pool, nil
 }
 
+// migrates the database of cfg up to migrationVersion, refusing a schema ahead of it with ErrSchemaTooNew
 func Migrate(cfg config.PgCfg) error {
-	driver, err := iofs.New(files, "migrations")
+	m, err := NewMigrator(cfg)
 	if err != nil {
-		return fmt.Errorf("load migration files: %w", err)
-	}
-	m, err := migrate.NewWithSourceInstance("iofs", driver, cfg.String())
-	if err != nil {
-		return fmt.Errorf("mount iofs instance: %w", err)
-	}
-	if err := m.Migrate(migrationVersion); err != nil {
-		if errors.Is(err, migrate.ErrNoChange) {
-			return nil
-		}
-		return fmt.Errorf("migrate: %w", err)
+		return err
 	}
-	return nil
+	defer m.Close()
+	return m.Startup()
 }
@@ -0,0 +1,40 @@
+package db
+
+import (
+	"database/sql"
+	"embed"
+
+	_ "github.com/golang-migrate/migrate/v4/database/sqlite"
+	_ "modernc.org/sqlite"
+)
+
+const sqliteMigrationVersion = 2
+
+//go:embed sqlite_migrations/*.sql
+var sqliteFiles embed.FS
+
+// opens the SQLite database at path, created if missing, with foreign keys enforced. A single connection is
+// used as SQLite serialises writes anyway
+func OpenSQLite(path string) (*sql.DB, error) {
+	conn, err := sql.Open("sqlite", "file:"+path+"?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)")
+	if err != nil {
+		return nil, err
+	}
+	conn.SetMaxOpenConns(1)
+	err = conn.Ping()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// migrates the SQLite database at path up to sqliteMigrationVersion, refusing a schema ahead of it with ErrSchemaTooNew
+func MigrateSQLite(path string) error {
+	m, err := NewSQLiteMigrator(path)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+	return m.Startup()
+}
@@ -0,0 +1,9 @@
+DROP TABLE IF EXISTS fork_schedule;
+DROP TABLE IF EXISTS validator_activity;
+DROP TABLE IF EXISTS watched_validators;
+DROP TABLE IF EXISTS contract_events;
+DROP TABLE IF EXISTS receipts;
+DROP TABLE IF EXISTS blocks;
+DROP TABLE IF EXISTS slots;
+DROP TABLE IF EXISTS epoch_stats;
+DROP TABLE IF EXISTS epochs;
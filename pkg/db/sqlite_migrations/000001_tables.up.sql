@@ -0,0 +1,112 @@
+-- the schema of pkg/db/migrations as of its version 10, SQLite databases have no older history to migrate
+CREATE TABLE IF NOT EXISTS epochs (
+    epoch_number INTEGER NOT NULL,
+    start_time TIMESTAMP NOT NULL,
+    end_time TIMESTAMP NOT NULL,
+    revision INTEGER NOT NULL DEFAULT 1,
+    CONSTRAINT pk_epochs PRIMARY KEY(epoch_number)
+);
+CREATE TABLE IF NOT EXISTS epoch_stats (
+    epoch_number INTEGER NOT NULL,
+    proposed_slots INTEGER NOT NULL,
+    missed_slots INTEGER NOT NULL,
+    no_of_transactions INTEGER NOT NULL,
+    gas_used INTEGER NOT NULL,
+    gas_limit INTEGER NOT NULL,
+    avg_gas_used INTEGER NOT NULL,
+    gas_utilisation REAL NOT NULL,
+    min_block_time REAL NOT NULL,
+    max_block_time REAL NOT NULL,
+    avg_block_time REAL NOT NULL,
+    CONSTRAINT pk_epoch_stats PRIMARY KEY(epoch_number),
+    CONSTRAINT fk_epoch_stats_epochs FOREIGN KEY(epoch_number) REFERENCES epochs(epoch_number) ON DELETE CASCADE
+);
+CREATE TABLE IF NOT EXISTS slots (
+    slot_number INTEGER NOT NULL,
+    start_time TIMESTAMP NOT NULL,
+    end_time TIMESTAMP NOT NULL,
+    epoch_number INTEGER NOT NULL,
+    revision INTEGER NOT NULL DEFAULT 1,
+    CONSTRAINT pk_slots PRIMARY KEY(slot_number),
+    CONSTRAINT fk_slots_epochs FOREIGN KEY(epoch_number) REFERENCES epochs(epoch_number) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_slots_epoch_number ON slots(epoch_number);
+CREATE TABLE IF NOT EXISTS blocks (
+    block_number INTEGER NOT NULL,
+    block_root TEXT NOT NULL,
+    parent_root TEXT NOT NULL DEFAULT '',
+    canonical BOOLEAN NOT NULL DEFAULT TRUE,
+    state_root TEXT NOT NULL,
+    slot_number INTEGER NOT NULL,
+    gas_limit INTEGER NOT NULL,
+    gas_used INTEGER NOT NULL,
+    no_of_transactions INTEGER NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    version TEXT NOT NULL DEFAULT '',
+    fee_recipient TEXT NOT NULL DEFAULT '',
+    extra_data TEXT NOT NULL DEFAULT '',
+    builder TEXT NOT NULL DEFAULT '',
+    mev_boost BOOLEAN NOT NULL DEFAULT FALSE,
+    revision INTEGER NOT NULL DEFAULT 1,
+    CONSTRAINT pk_blocks PRIMARY KEY(block_root),
+    CONSTRAINT fk_blocks_slots FOREIGN KEY(slot_number) REFERENCES slots(slot_number) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_blocks_block_number ON blocks(block_number);
+CREATE INDEX IF NOT EXISTS idx_blocks_slot_number ON blocks(slot_number);
+CREATE INDEX IF NOT EXISTS idx_blocks_builder ON blocks(builder);
+CREATE TABLE IF NOT EXISTS receipts (
+    block_root TEXT NOT NULL,
+    transaction_hash TEXT NOT NULL,
+    transaction_index INTEGER NOT NULL,
+    block_number INTEGER NOT NULL,
+    status INTEGER NOT NULL,
+    gas_used INTEGER NOT NULL,
+    effective_gas_price INTEGER NOT NULL,
+    log_count INTEGER NOT NULL,
+    CONSTRAINT pk_receipts PRIMARY KEY(block_root, transaction_index),
+    CONSTRAINT fk_receipts_blocks FOREIGN KEY(block_root) REFERENCES blocks(block_root) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_receipts_transaction_hash ON receipts(transaction_hash);
+CREATE TABLE IF NOT EXISTS contract_events (
+    block_root TEXT NOT NULL,
+    block_number INTEGER NOT NULL,
+    transaction_hash TEXT NOT NULL,
+    log_index INTEGER NOT NULL,
+    address TEXT NOT NULL,
+    contract TEXT NOT NULL,
+    event TEXT NOT NULL,
+    signature TEXT NOT NULL,
+    -- JSON encoded
+    args TEXT NOT NULL,
+    CONSTRAINT pk_contract_events PRIMARY KEY(block_root, log_index),
+    CONSTRAINT fk_contract_events_blocks FOREIGN KEY(block_root) REFERENCES blocks(block_root) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_contract_events_block_number ON contract_events(block_number);
+CREATE INDEX IF NOT EXISTS idx_contract_events_contract_event ON contract_events(contract, event);
+CREATE INDEX IF NOT EXISTS idx_contract_events_address ON contract_events(address);
+CREATE TABLE IF NOT EXISTS watched_validators (
+    validator TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT pk_watched_validators PRIMARY KEY(validator)
+);
+CREATE TABLE IF NOT EXISTS validator_activity (
+    validator_index INTEGER NOT NULL,
+    epoch_number INTEGER NOT NULL,
+    proposer_duties INTEGER NOT NULL,
+    proposed INTEGER NOT NULL,
+    attester_duties INTEGER NOT NULL,
+    attestations_included INTEGER NOT NULL,
+    inclusion_delay INTEGER NOT NULL,
+    sync_duties INTEGER NOT NULL,
+    sync_participated INTEGER NOT NULL,
+    balance INTEGER NOT NULL,
+    reward INTEGER NOT NULL,
+    CONSTRAINT pk_validator_activity PRIMARY KEY(validator_index, epoch_number)
+);
+CREATE TABLE IF NOT EXISTS fork_schedule (
+    name TEXT NOT NULL,
+    previous_version TEXT NOT NULL,
+    current_version TEXT NOT NULL,
+    epoch INTEGER NOT NULL,
+    CONSTRAINT pk_fork_schedule PRIMARY KEY(name)
+);
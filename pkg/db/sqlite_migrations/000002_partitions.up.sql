@@ -0,0 +1,3 @@
+-- SQLite tables are not partitioned, blocks are keyed by root & slot number all the same, as in pkg/db/migrations
+-- as of its version 11, to share the conflict targets of upserts
+CREATE UNIQUE INDEX IF NOT EXISTS idx_blocks_block_root_slot_number ON blocks(block_root, slot_number);
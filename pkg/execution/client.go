@@ -0,0 +1,230 @@
+package execution
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"indexer/pkg/models"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Client talks JSON-RPC to an execution layer node
+type Client struct {
+	url        string
+	httpClient *http.Client
+	id         uint64
+}
+
+type rpcRequest struct {
+	JSONRPC string        `json:"jsonrpc"`
+	ID      uint64        `json:"id"`
+	Method  string        `json:"method"`
+	Params  []interface{} `json:"params"`
+}
+
+type rpcResponse struct {
+	ID     uint64          `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  *rpcError       `json:"error"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string {
+	return fmt.Sprintf("json-rpc error %d: %s", e.Code, e.Message)
+}
+
+// receipt as returned by eth_getBlockReceipts
+type rpcReceipt struct {
+	TransactionHash   string   `json:"transactionHash"`
+	TransactionIndex  string   `json:"transactionIndex"`
+	BlockHash         string   `json:"blockHash"`
+	BlockNumber       string   `json:"blockNumber"`
+	Status            string   `json:"status"`
+	GasUsed           string   `json:"gasUsed"`
+	EffectiveGasPrice string   `json:"effectiveGasPrice"`
+	Logs              []rpcLog `json:"logs"`
+}
+
+type rpcLog struct {
+	Address  string   `json:"address"`
+	Topics   []string `json:"topics"`
+	Data     string   `json:"data"`
+	LogIndex string   `json:"logIndex"`
+}
+
+// transaction as returned by eth_getTransactionByBlockHashAndIndex, only the fields of interest
+type rpcTransaction struct {
+	Hash      string `json:"hash"`
+	BlockHash string `json:"blockHash"`
+	From      string `json:"from"`
+	To        string `json:"to"`
+	Value     string `json:"value"`
+}
+
+// represents a transfer of value by a transaction, To is empty for contract creations
+type Transaction struct {
+	Hash  string
+	From  string
+	To    string
+	Value *big.Int
+}
+
+// Creates new execution layer JSON-RPC client
+func New(url string) *Client {
+	return &Client{
+		url:        url,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (c *Client) call(ctx context.Context, method string, result interface{}, params ...interface{}) error {
+	body, err := json.Marshal(rpcRequest{
+		JSONRPC: "2.0",
+		ID:      atomic.AddUint64(&c.id, 1),
+		Method:  method,
+		Params:  params,
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s failed, status: %s", method, resp.Status)
+	}
+	var rpcResp rpcResponse
+	err = json.NewDecoder(resp.Body).Decode(&rpcResp)
+	if err != nil {
+		return fmt.Errorf("%s response decode failed, err: %v", method, err.Error())
+	}
+	if rpcResp.Error != nil {
+		return rpcResp.Error
+	}
+	if len(rpcResp.Result) == 0 || string(rpcResp.Result) == "null" {
+		return fmt.Errorf("%s returned no result", method)
+	}
+	return json.Unmarshal(rpcResp.Result, result)
+}
+
+// returns the receipts of every transaction in the execution block of the given hash, receipts of any other block
+// are refused
+func (c *Client) BlockReceipts(ctx context.Context, blockHash string) ([]models.Receipt, error) {
+	var raw []rpcReceipt
+	err := c.call(ctx, "eth_getBlockReceipts", &raw, blockHash)
+	if err != nil {
+		return nil, err
+	}
+	receipts := make([]models.Receipt, 0, len(raw))
+	for _, r := range raw {
+		if !strings.EqualFold(r.BlockHash, blockHash) {
+			return nil, fmt.Errorf("receipt %s is of block %s, not %s", r.TransactionHash, r.BlockHash, blockHash)
+		}
+		receipt := models.Receipt{
+			TransactionHash: r.TransactionHash,
+			LogCount:        len(r.Logs),
+		}
+		parse := func(field, hex string) uint64 {
+			if err != nil {
+				return 0
+			}
+			var v uint64
+			v, err = parseQuantity(hex)
+			if err != nil {
+				err = fmt.Errorf("receipt %s has invalid %s, err: %v", r.TransactionHash, field, err.Error())
+			}
+			return v
+		}
+		receipt.BlockNumber = parse("blockNumber", r.BlockNumber)
+		receipt.TransactionIndex = parse("transactionIndex", r.TransactionIndex)
+		receipt.Status = parse("status", r.Status)
+		receipt.GasUsed = parse("gasUsed", r.GasUsed)
+		receipt.EffectiveGasPrice = parse("effectiveGasPrice", r.EffectiveGasPrice)
+		for _, l := range r.Logs {
+			receipt.Logs = append(receipt.Logs, models.Log{
+				Address:  strings.ToLower(l.Address),
+				Topics:   l.Topics,
+				Data:     l.Data,
+				LogIndex: parse("logIndex", l.LogIndex),
+			})
+		}
+		if err != nil {
+			return nil, err
+		}
+		receipts = append(receipts, receipt)
+	}
+	return receipts, nil
+}
+
+// returns the transaction at the given index of the execution block of the given hash, addresses are lower cased
+func (c *Client) TransactionByIndex(ctx context.Context, blockHash string, index uint64) (*Transaction, error) {
+	var raw rpcTransaction
+	err := c.call(ctx, "eth_getTransactionByBlockHashAndIndex", &raw, blockHash, "0x"+strconv.FormatUint(index, 16))
+	if err != nil {
+		return nil, err
+	}
+	if !strings.EqualFold(raw.BlockHash, blockHash) {
+		return nil, fmt.Errorf("transaction %s is of block %s, not %s", raw.Hash, raw.BlockHash, blockHash)
+	}
+	value, ok := new(big.Int).SetString(strings.TrimPrefix(raw.Value, "0x"), 16)
+	if !ok || !strings.HasPrefix(raw.Value, "0x") {
+		return nil, fmt.Errorf("transaction %s has invalid value %q", raw.Hash, raw.Value)
+	}
+	return &Transaction{
+		Hash:  raw.Hash,
+		From:  strings.ToLower(raw.From),
+		To:    strings.ToLower(raw.To),
+		Value: value,
+	}, nil
+}
+
+// attaches receipts to every block of the epoch carrying an execution payload
+func (c *Client) Enrich(ctx context.Context, e *models.Epoch) error {
+	for idx := range e.Slots {
+		block := &e.Slots[idx].Block
+		if block.BlockNumber == 0 {
+			continue
+		}
+		receipts, err := c.BlockReceipts(ctx, block.ExecutionBlockHash)
+		if err != nil {
+			return fmt.Errorf("eth_getBlockReceipts(%s) failed, err: %v", block.ExecutionBlockHash, err.Error())
+		}
+		if len(receipts) != block.NoOfTransactions {
+			return fmt.Errorf("block %d has %d transactions but %d receipts", block.BlockNumber, block.NoOfTransactions, len(receipts))
+		}
+		for idx := range receipts {
+			if receipts[idx].BlockNumber != block.BlockNumber {
+				return fmt.Errorf("block %d has receipts of block %d", block.BlockNumber, receipts[idx].BlockNumber)
+			}
+			receipts[idx].BlockRoot = block.BlockRoot
+		}
+		block.Receipts = receipts
+	}
+	return nil
+}
+
+// parses a hex encoded JSON-RPC quantity
+func parseQuantity(hex string) (uint64, error) {
+	if !strings.HasPrefix(hex, "0x") {
+		return 0, errors.New("missing 0x prefix")
+	}
+	return strconv.ParseUint(hex[2:], 16, 64)
+}
@@ -0,0 +1,146 @@
+package execution
+
+import (
+	"context"
+	"encoding/json"
+	"indexer/pkg/models"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// local JSON-RPC stand-in for an execution client, serving receipts per block hash
+func newStandIn(t *testing.T, receipts map[string]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req rpcRequest
+		err := json.NewDecoder(r.Body).Decode(&req)
+		assert.Nil(t, err, "request must be valid JSON-RPC")
+		w.Header().Set("Content-Type", "application/json")
+		if req.Method != "eth_getBlockReceipts" {
+			w.Write([]byte(`{"jsonrpc":"2.0","id":1,"error":{"code":-32601,"message":"method not found"}}`))
+			return
+		}
+		result, ok := receipts[req.Params[0].(string)]
+		if !ok {
+			result = "null"
+		}
+		w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":` + result + `}`))
+	}))
+}
+
+func TestClient_Enrich(t *testing.T) {
+	server := newStandIn(t, map[string]string{
+		"0xb10": `[
+			{"transactionHash":"0xaa","transactionIndex":"0x0","blockHash":"0xB10","blockNumber":"0x10","status":"0x1","gasUsed":"0x5208","effectiveGasPrice":"0x3b9aca00","logs":[]},
+			{"transactionHash":"0xbb","transactionIndex":"0x1","blockHash":"0xb10","blockNumber":"0x10","status":"0x0","gasUsed":"0xc350","effectiveGasPrice":"0x3b9aca01","logs":[
+				{"address":"0xAbC0000000000000000000000000000000000001","topics":["0x01"],"data":"0x","logIndex":"0x0"},
+				{"address":"0xabc0000000000000000000000000000000000002","topics":[],"data":"0x02","logIndex":"0x1"}
+			]}
+		]`,
+		"0xb11": `[{"transactionHash":"0xcc","transactionIndex":"0x0","blockHash":"0xb11","blockNumber":"0x11","status":"0x1","gasUsed":"zz","effectiveGasPrice":"0x1","logs":[]}]`,
+		// the node answers with the receipts of the block it reorged to
+		"0xb12": `[{"transactionHash":"0xdd","transactionIndex":"0x0","blockHash":"0xc12","blockNumber":"0x12","status":"0x1","gasUsed":"0x1","effectiveGasPrice":"0x1","logs":[]}]`,
+	})
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		epoch   models.Epoch
+		want    [][]models.Receipt
+		wantErr bool
+	}{
+		{
+			name: "should attach receipts to blocks with an execution payload",
+			epoch: models.Epoch{Slots: []models.Slot{
+				{Block: models.Block{BlockNumber: 0x10, ExecutionBlockHash: "0xb10", NoOfTransactions: 2}},
+				{Block: models.Block{}},
+			}},
+			want: [][]models.Receipt{
+				{
+					{TransactionHash: "0xaa", TransactionIndex: 0, BlockNumber: 0x10, Status: 1, GasUsed: 21000, EffectiveGasPrice: 1000000000, LogCount: 0},
+					{TransactionHash: "0xbb", TransactionIndex: 1, BlockNumber: 0x10, Status: 0, GasUsed: 50000, EffectiveGasPrice: 1000000001, LogCount: 2, Logs: []models.Log{
+						{Address: "0xabc0000000000000000000000000000000000001", Topics: []string{"0x01"}, Data: "0x", LogIndex: 0},
+						{Address: "0xabc0000000000000000000000000000000000002", Topics: []string{}, Data: "0x02", LogIndex: 1},
+					}},
+				},
+				nil,
+			},
+		},
+		{
+			name:    "should fail when the transaction count does not match",
+			epoch:   models.Epoch{Slots: []models.Slot{{Block: models.Block{BlockNumber: 0x10, ExecutionBlockHash: "0xb10", NoOfTransactions: 3}}}},
+			wantErr: true,
+		},
+		{
+			name:    "should fail for malformed quantities",
+			epoch:   models.Epoch{Slots: []models.Slot{{Block: models.Block{BlockNumber: 0x11, ExecutionBlockHash: "0xb11", NoOfTransactions: 1}}}},
+			wantErr: true,
+		},
+		{
+			name:    "should fail for unknown blocks",
+			epoch:   models.Epoch{Slots: []models.Slot{{Block: models.Block{BlockNumber: 0x13, ExecutionBlockHash: "0xb13"}}}},
+			wantErr: true,
+		},
+		{
+			name:    "should fail for receipts of another block",
+			epoch:   models.Epoch{Slots: []models.Slot{{Block: models.Block{BlockNumber: 0x12, ExecutionBlockHash: "0xb12", NoOfTransactions: 1}}}},
+			wantErr: true,
+		},
+		{
+			name:    "should fail for receipts of another block number",
+			epoch:   models.Epoch{Slots: []models.Slot{{Block: models.Block{BlockNumber: 0x14, ExecutionBlockHash: "0xb10", NoOfTransactions: 2}}}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := New(server.URL).Enrich(context.Background(), &tt.epoch)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Enrich() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			for idx, want := range tt.want {
+				assert.Equal(t, want, tt.epoch.Slots[idx].Block.Receipts)
+			}
+		})
+	}
+}
+
+func TestClient_TransactionByIndex(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req rpcRequest
+		err := json.NewDecoder(r.Body).Decode(&req)
+		assert.Nil(t, err, "request must be valid JSON-RPC")
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case req.Method != "eth_getTransactionByBlockHashAndIndex":
+			w.Write([]byte(`{"jsonrpc":"2.0","id":1,"error":{"code":-32601,"message":"method not found"}}`))
+		case req.Params[0] == "0xb10" && req.Params[1] == "0x2":
+			w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":{"hash":"0xaa","blockHash":"0xb10","from":"0xBUILDER","to":"0xPROPOSER","value":"0xde0b6b3a7640000"}}`))
+		case req.Params[0] == "0xb11":
+			w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":{"hash":"0xbb","blockHash":"0xb11","from":"0x01","to":null,"value":"zz"}}`))
+		case req.Params[0] == "0xb13":
+			w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":{"hash":"0xcc","blockHash":"0xc13","from":"0x01","to":"0x02","value":"0x1"}}`))
+		default:
+			w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":null}`))
+		}
+	}))
+	defer server.Close()
+	client := New(server.URL)
+
+	tx, err := client.TransactionByIndex(context.Background(), "0xb10", 2)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "0xaa", tx.Hash)
+		assert.Equal(t, "0xbuilder", tx.From)
+		assert.Equal(t, "0xproposer", tx.To)
+		assert.Equal(t, "1000000000000000000", tx.Value.String())
+	}
+	_, err = client.TransactionByIndex(context.Background(), "0xb11", 0)
+	assert.Error(t, err, "malformed values must be rejected")
+	_, err = client.TransactionByIndex(context.Background(), "0xb13", 0)
+	assert.Error(t, err, "transactions of another block must be rejected")
+	_, err = client.TransactionByIndex(context.Background(), "0xb12", 0)
+	assert.Error(t, err, "unknown transactions must be reported")
+}
@@ -1,51 +1,593 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"indexer/pkg/indexer"
+	"indexer/pkg/models"
 	"indexer/pkg/store"
 	"log"
 	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 )
 
+const (
+	// caps the number of contract events returned by a single request
+	maxEventsLimit = 1000
+	// interval of the comments keeping idle event streams open through proxies
+	keepAliveInterval = 15 * time.Second
+	// default & cap of the number of epochs returned by a single page
+	defaultPageLimit = 20
+	maxPageLimit     = 100
+)
+
+// streams the epochs written to the database until ctx is done, as store.Subscriber does
+type Feed interface {
+	Subscribe(ctx context.Context) <-chan models.EpochNotification
+}
+
+// resolves a validator, an index or a 0x prefixed pubkey, to its index, as indexer.ValidatorTracker does
+type Validators interface {
+	Index(ctx context.Context, id string) (uint64, error)
+}
+
 type HTTP struct {
 	repo store.Repository
+	// nil when the store has no feed, GET /epochs/stream is unavailable then
+	feed Feed
+	// nil without a beacon node, the history of validators is then looked up by index only
+	validators Validators
 }
 
-func New(repo store.Repository) *HTTP {
-	return &HTTP{repo}
+func New(repo store.Repository, feed Feed, validators Validators) *HTTP {
+	return &HTTP{repo, feed, validators}
 }
 
 func (h *HTTP) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	switch r.Method {
-	case http.MethodGet:
-		switch r.URL.Path {
-		default:
-			w.WriteHeader(http.StatusNotFound)
-			w.Write([]byte(http.StatusText(http.StatusNotFound)))
-			return
-		case "/":
-		}
+	var routes map[string]http.HandlerFunc
+	switch {
+	case r.URL.Path == "/":
+		routes = map[string]http.HandlerFunc{http.MethodGet: h.getEpochs}
+	case r.URL.Path == "/epochs/stream":
+		routes = map[string]http.HandlerFunc{http.MethodGet: h.streamEpochs}
+	case r.URL.Path == "/epochs/summary":
+		routes = map[string]http.HandlerFunc{http.MethodGet: h.getEpochsSummary}
+	case r.URL.Path == "/epochs":
+		routes = map[string]http.HandlerFunc{http.MethodGet: h.getEpochPage}
+	case isResource(r.URL.Path, "/epochs/", "/slots"):
+		routes = map[string]http.HandlerFunc{http.MethodGet: h.getEpochSlots}
+	case isResource(r.URL.Path, "/epochs/", ""):
+		routes = map[string]http.HandlerFunc{http.MethodGet: h.getEpoch}
+	case isResource(r.URL.Path, "/slots/", ""):
+		routes = map[string]http.HandlerFunc{http.MethodGet: h.getSlot}
+	case isResource(r.URL.Path, "/blocks/by-number/", ""):
+		routes = map[string]http.HandlerFunc{http.MethodGet: h.getBlockByNumber}
+	case isResource(r.URL.Path, "/blocks/", ""):
+		routes = map[string]http.HandlerFunc{http.MethodGet: h.getBlock}
+	case r.URL.Path == "/forks":
+		routes = map[string]http.HandlerFunc{http.MethodGet: h.getForkSchedule}
+	case r.URL.Path == "/builders":
+		routes = map[string]http.HandlerFunc{http.MethodGet: h.getBuilderShare}
+	case r.URL.Path == "/events":
+		routes = map[string]http.HandlerFunc{http.MethodGet: h.getContractEvents}
+	case r.URL.Path == "/validators":
+		routes = map[string]http.HandlerFunc{http.MethodGet: h.getWatchedValidators, http.MethodPost: h.watchValidator}
+	case strings.HasPrefix(r.URL.Path, "/validators/"):
+		routes = map[string]http.HandlerFunc{http.MethodGet: h.getValidatorHistory, http.MethodDelete: h.unwatchValidator}
 	default:
-		w.WriteHeader(http.StatusMethodNotAllowed)
-		w.Write([]byte(http.StatusText(http.StatusMethodNotAllowed)))
+		writeError(w, http.StatusNotFound, fmt.Sprintf("%s not found", r.URL.Path))
+		return
+	}
+	route, ok := routes[r.Method]
+	if !ok {
+		allowed := make([]string, 0, len(routes))
+		for method := range routes {
+			allowed = append(allowed, method)
+		}
+		sort.Strings(allowed)
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+		writeError(w, http.StatusMethodNotAllowed, fmt.Sprintf("%s not allowed on %s", r.Method, r.URL.Path))
 		return
 	}
+	route(w, r)
+}
+
+// GET /?fork=
+func (h *HTTP) getEpochs(w http.ResponseWriter, r *http.Request) {
 	epochs, err := h.repo.Get(r.Context())
 	if err != nil {
-		message := fmt.Sprintf("repo.Get() failed, err: %v", err.Error())
-		log.Print(message)
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte(message))
+		fail(w, "repo.Get()", err)
 		return
 	}
-	w.Header().Add("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	encoder := json.NewEncoder(w)
-	encoder.SetIndent("", " ")
+	forks, err := h.repo.GetForkSchedule(r.Context())
+	if err != nil {
+		fail(w, "repo.GetForkSchedule()", err)
+		return
+	}
+	fork := r.URL.Query().Get("fork")
+	if fork != "" && !hasFork(forks, fork) {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("unknown fork: %q", fork))
+		return
+	}
+	filtered := epochs[:0]
+	for _, epoch := range epochs {
+		epoch.Fork = forkAt(forks, epoch.EpochNumber)
+		if fork == "" || epoch.Fork == fork {
+			filtered = append(filtered, epoch)
+		}
+	}
+	epochs = filtered
 	if len(epochs) > 0 {
-		encoder.Encode(epochs)
+		writeJSON(w, http.StatusOK, epochs)
 	} else {
-		encoder.Encode(map[string]string{"message": "no blocks yet"})
+		writeJSON(w, http.StatusOK, map[string]string{"message": "no blocks yet"})
+	}
+}
+
+// GET /epochs/stream, server-sent events announcing every epoch written to the database by any process
+func (h *HTTP) streamEpochs(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if h.feed == nil || !ok {
+		writeError(w, http.StatusNotImplemented, "live updates need the postgres store")
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	stream := h.feed.Subscribe(r.Context())
+	keepAlive := time.NewTicker(keepAliveInterval)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case n, ok := <-stream:
+			if !ok {
+				return
+			}
+			data, err := json.Marshal(n)
+			if err != nil {
+				log.Printf("notification encoding failed, err: %v\n", err.Error())
+				continue
+			}
+			fmt.Fprintf(w, "event: epoch\nid: %d\ndata: %s\n\n", n.EpochNumber, data)
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+		}
+		flusher.Flush()
+	}
+}
+
+// GET /epochs?from=&to=&from_time=&to_time=&order=&limit=&cursor=, a page of epochs along with their fork, oldest
+// first unless order is desc, times are RFC 3339
+func (h *HTTP) getEpochPage(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	limit, ok := pageLimit(w, q, defaultPageLimit, maxPageLimit)
+	if !ok {
+		return
+	}
+	query := models.RangeQuery{Limit: limit, Cursor: q.Get("cursor")}
+	for param, dst := range map[string]*uint64{
+		"from": &query.From,
+		"to":   &query.To,
+	} {
+		if v := q.Get(param); v != "" {
+			n, err := strconv.ParseUint(v, 10, 64)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid %s: %q", param, v))
+				return
+			}
+			*dst = n
+		}
+	}
+	for param, dst := range map[string]*time.Time{
+		"from_time": &query.FromTime,
+		"to_time":   &query.ToTime,
+	} {
+		if v := q.Get(param); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid %s: %q", param, v))
+				return
+			}
+			*dst = t
+		}
+	}
+	switch order := q.Get("order"); order {
+	case "", "asc":
+	case "desc":
+		query.Descending = true
+	default:
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid order: %q, expected asc or desc", order))
+		return
+	}
+
+	page, err := h.repo.GetEpochs(r.Context(), query)
+	if errors.Is(err, store.ErrInvalidCursor) {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid cursor: %q", query.Cursor))
+		return
+	}
+	if err != nil {
+		fail(w, "repo.GetEpochs()", err)
+		return
+	}
+	forks, err := h.repo.GetForkSchedule(r.Context())
+	if err != nil {
+		fail(w, "repo.GetForkSchedule()", err)
+		return
+	}
+	for idx := range page.Epochs {
+		page.Epochs[idx].Fork = forkAt(forks, page.Epochs[idx].EpochNumber)
+	}
+	writeJSON(w, http.StatusOK, page)
+}
+
+// GET /epochs/{number}, the epoch along with its fork
+func (h *HTTP) getEpoch(w http.ResponseWriter, r *http.Request) {
+	number, ok := pathNumber(w, r.URL.Path, "/epochs/", "", "epoch")
+	if !ok {
+		return
+	}
+	epoch, err := h.repo.GetEpoch(r.Context(), number)
+	if errors.Is(err, store.ErrNotFound) {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("epoch %d not found", number))
+		return
+	}
+	if err != nil {
+		fail(w, "repo.GetEpoch()", err)
+		return
+	}
+	forks, err := h.repo.GetForkSchedule(r.Context())
+	if err != nil {
+		fail(w, "repo.GetForkSchedule()", err)
+		return
+	}
+	epoch.Fork = forkAt(forks, epoch.EpochNumber)
+	writeJSON(w, http.StatusOK, epoch)
+}
+
+// GET /epochs/{number}/slots, the slots of the epoch as a single page
+func (h *HTTP) getEpochSlots(w http.ResponseWriter, r *http.Request) {
+	number, ok := pathNumber(w, r.URL.Path, "/epochs/", "/slots", "epoch")
+	if !ok {
+		return
+	}
+	epoch, err := h.repo.GetEpoch(r.Context(), number)
+	if errors.Is(err, store.ErrNotFound) {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("epoch %d not found", number))
+		return
+	}
+	if err != nil {
+		fail(w, "repo.GetEpoch()", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, models.SlotPage{Slots: epoch.Slots})
+}
+
+// GET /slots/{number}
+func (h *HTTP) getSlot(w http.ResponseWriter, r *http.Request) {
+	number, ok := pathNumber(w, r.URL.Path, "/slots/", "", "slot")
+	if !ok {
+		return
+	}
+	slot, err := h.repo.GetSlot(r.Context(), number)
+	if errors.Is(err, store.ErrNotFound) {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("slot %d not found", number))
+		return
+	}
+	if err != nil {
+		fail(w, "repo.GetSlot()", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, slot)
+}
+
+// GET /blocks/{root}, canonical or not
+func (h *HTTP) getBlock(w http.ResponseWriter, r *http.Request) {
+	root, _ := pathID(r.URL.Path, "/blocks/", "")
+	block, err := h.repo.GetBlock(r.Context(), root)
+	if errors.Is(err, store.ErrNotFound) {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("block %s not found", root))
+		return
+	}
+	if err != nil {
+		fail(w, "repo.GetBlock()", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, block)
+}
+
+// GET /blocks/by-number/{number}, the canonical block of an execution block number
+func (h *HTTP) getBlockByNumber(w http.ResponseWriter, r *http.Request) {
+	number, ok := pathNumber(w, r.URL.Path, "/blocks/by-number/", "", "block number")
+	if !ok {
+		return
+	}
+	block, err := h.repo.GetBlockByNumber(r.Context(), number)
+	if errors.Is(err, store.ErrNotFound) {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("block %d not found", number))
+		return
+	}
+	if err != nil {
+		fail(w, "repo.GetBlockByNumber()", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, block)
+}
+
+// the id of a resource path, prefix + id + suffix, the id being a single non-empty segment
+func pathID(path, prefix, suffix string) (string, bool) {
+	if !strings.HasPrefix(path, prefix) || !strings.HasSuffix(path, suffix) || len(path) <= len(prefix)+len(suffix) {
+		return "", false
+	}
+	id := path[len(prefix) : len(path)-len(suffix)]
+	return id, !strings.Contains(id, "/")
+}
+
+func isResource(path, prefix, suffix string) bool {
+	_, ok := pathID(path, prefix, suffix)
+	return ok
+}
+
+// parses the limit of a list route, byDefault when absent, answering 400 unless it is within 1 to max
+func pageLimit(w http.ResponseWriter, q url.Values, byDefault, max uint64) (uint64, bool) {
+	v := q.Get("limit")
+	if v == "" {
+		return byDefault, true
+	}
+	limit, err := strconv.ParseUint(v, 10, 64)
+	if err != nil || limit == 0 || limit > max {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid limit: %q, expected 1 to %d", v, max))
+		return 0, false
+	}
+	return limit, true
+}
+
+// parses the numeric id of a resource path, answering 400 if it is not a number
+func pathNumber(w http.ResponseWriter, path, prefix, suffix, name string) (uint64, bool) {
+	id, _ := pathID(path, prefix, suffix)
+	number, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid %s: %q", name, id))
+		return 0, false
+	}
+	return number, true
+}
+
+// GET /forks
+func (h *HTTP) getForkSchedule(w http.ResponseWriter, r *http.Request) {
+	forks, err := h.repo.GetForkSchedule(r.Context())
+	if err != nil {
+		fail(w, "repo.GetForkSchedule()", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, forks)
+}
+
+// returns the name of the last fork activated at or before the epoch, forks are ordered by activation
+func forkAt(forks []models.Fork, epoch uint64) string {
+	name := ""
+	for _, fork := range forks {
+		if fork.Epoch > epoch {
+			break
+		}
+		name = fork.Name
+	}
+	return name
+}
+
+func hasFork(forks []models.Fork, name string) bool {
+	for _, fork := range forks {
+		if fork.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// GET /epochs/summary?limit=
+func (h *HTTP) getEpochsSummary(w http.ResponseWriter, r *http.Request) {
+	limit, ok := pageLimit(w, r.URL.Query(), maxPageLimit, maxPageLimit)
+	if !ok {
+		return
 	}
+	stats, err := h.repo.GetEpochStats(r.Context(), limit)
+	if err != nil {
+		fail(w, "repo.GetEpochStats()", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, stats)
+}
+
+// GET /events?contract=&address=&event=&from_block=&to_block=&limit=
+func (h *HTTP) getContractEvents(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	limit, ok := pageLimit(w, q, 100, maxEventsLimit)
+	if !ok {
+		return
+	}
+	filter := models.EventFilter{
+		Contract: q.Get("contract"),
+		Address:  q.Get("address"),
+		Event:    q.Get("event"),
+		Limit:    limit,
+	}
+	for param, dst := range map[string]*uint64{
+		"from_block": &filter.FromBlock,
+		"to_block":   &filter.ToBlock,
+	} {
+		if v := q.Get(param); v != "" {
+			n, err := strconv.ParseUint(v, 10, 64)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid %s: %q", param, v))
+				return
+			}
+			*dst = n
+		}
+	}
+	events, err := h.repo.GetContractEvents(r.Context(), filter)
+	if err != nil {
+		fail(w, "repo.GetContractEvents()", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, events)
+}
+
+// GET /builders?from_epoch=&to_epoch=
+func (h *HTTP) getBuilderShare(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	var fromEpoch, toEpoch uint64
+	for param, dst := range map[string]*uint64{
+		"from_epoch": &fromEpoch,
+		"to_epoch":   &toEpoch,
+	} {
+		if v := q.Get(param); v != "" {
+			n, err := strconv.ParseUint(v, 10, 64)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid %s: %q", param, v))
+				return
+			}
+			*dst = n
+		}
+	}
+	shares, err := h.repo.GetBuilderShare(r.Context(), fromEpoch, toEpoch)
+	if err != nil {
+		fail(w, "repo.GetBuilderShare()", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string][]models.BuilderShare{
+		"epochs":  shares,
+		"overall": overallShare(shares),
+	})
+}
+
+// sums per epoch shares up into the share of every builder over all of those epochs, largest share first
+func overallShare(perEpoch []models.BuilderShare) []models.BuilderShare {
+	blocks := make(map[string]int)
+	var total int
+	for _, share := range perEpoch {
+		blocks[share.Builder] += share.Blocks
+		total += share.Blocks
+	}
+	overall := make([]models.BuilderShare, 0, len(blocks))
+	for builder, n := range blocks {
+		overall = append(overall, models.BuilderShare{Builder: builder, Blocks: n, Share: float64(n) / float64(total)})
+	}
+	sort.Slice(overall, func(i, j int) bool {
+		if overall[i].Blocks != overall[j].Blocks {
+			return overall[i].Blocks > overall[j].Blocks
+		}
+		return overall[i].Builder < overall[j].Builder
+	})
+	return overall
+}
+
+// GET /validators
+func (h *HTTP) getWatchedValidators(w http.ResponseWriter, r *http.Request) {
+	validators, err := h.repo.GetWatchedValidators(r.Context())
+	if err != nil {
+		fail(w, "repo.GetWatchedValidators()", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, validators)
+}
+
+// POST /validators {"validator": "<index or 0x prefixed pubkey>"}
+func (h *HTTP) watchValidator(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Validator string `json:"validator"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid body, err: %v", err.Error()))
+		return
+	}
+	validator, err := indexer.ParseValidator(body.Validator)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	err = h.repo.WatchValidator(r.Context(), validator)
+	if err != nil {
+		fail(w, "repo.WatchValidator()", err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, map[string]string{"validator": validator})
+}
+
+// DELETE /validators/{index or pubkey}
+func (h *HTTP) unwatchValidator(w http.ResponseWriter, r *http.Request) {
+	validator, err := indexer.ParseValidator(strings.TrimPrefix(r.URL.Path, "/validators/"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	err = h.repo.UnwatchValidator(r.Context(), validator)
+	if err != nil {
+		fail(w, "repo.UnwatchValidator()", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GET /validators/{index or pubkey}?limit=
+func (h *HTTP) getValidatorHistory(w http.ResponseWriter, r *http.Request) {
+	validator, err := indexer.ParseValidator(strings.TrimPrefix(r.URL.Path, "/validators/"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	index, err := strconv.ParseUint(validator, 10, 64)
+	if err != nil {
+		// a pubkey, resolved as the watchlist resolves it
+		if h.validators == nil {
+			writeError(w, http.StatusNotImplemented, "looking validators up by pubkey needs a beacon node")
+			return
+		}
+		index, err = h.validators.Index(r.Context(), validator)
+		if errors.Is(err, indexer.ErrUnknownValidator) {
+			writeError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		if err != nil {
+			fail(w, "validators.Index()", err)
+			return
+		}
+	}
+	limit, ok := pageLimit(w, r.URL.Query(), maxPageLimit, maxPageLimit)
+	if !ok {
+		return
+	}
+	activity, err := h.repo.GetValidatorHistory(r.Context(), index, limit)
+	if err != nil {
+		fail(w, "repo.GetValidatorHistory()", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, activity)
+}
+
+// answers 500 & logs the failed call, as JSON like the resource routes answer
+func fail(w http.ResponseWriter, call string, err error) {
+	message := fmt.Sprintf("%s failed, err: %v", call, err.Error())
+	log.Print(message)
+	writeError(w, http.StatusInternalServerError, message)
+}
+
+// errors of the resource routes are JSON objects with a message, as their results are JSON
+func writeError(w http.ResponseWriter, code int, message string) {
+	writeJSON(w, code, map[string]string{"message": message})
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(code)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", " ")
+	encoder.Encode(v)
 }
@@ -1,15 +1,36 @@
 package handler
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"indexer/pkg/indexer"
 	"indexer/pkg/mock"
+	"indexer/pkg/models"
 	"indexer/pkg/store"
+	"indexer/pkg/store/storetest"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 )
 
+// a pubkey of a validator, as validators resolves it
+var pubkey = "0x" + strings.Repeat("ab", 48)
+
+// resolves the pubkey to its index, other pubkeys are unknown
+type validators map[string]uint64
+
+func (v validators) Index(ctx context.Context, id string) (uint64, error) {
+	index, ok := v[id]
+	if !ok {
+		return 0, fmt.Errorf("%w: %s", indexer.ErrUnknownValidator, id)
+	}
+	return index, nil
+}
+
 func TestHTTP_ServeHTTP(t *testing.T) {
 	type fields struct {
 		repo store.Repository
@@ -50,6 +71,198 @@ func TestHTTP_ServeHTTP(t *testing.T) {
 				code: http.StatusNotFound,
 			},
 		},
+		{
+			name: "GET on '/epochs/summary' should be 200 OK",
+			fields: fields{
+				repo: mock.New(),
+			},
+			args: args{
+				r: httptest.NewRequest(http.MethodGet, "/epochs/summary?limit=10", nil),
+			},
+			result: result{
+				code: http.StatusOK,
+			},
+		},
+		{
+			name: "GET on '/epochs/summary' with a malformed limit should be 400",
+			fields: fields{
+				repo: mock.New(),
+			},
+			args: args{
+				r: httptest.NewRequest(http.MethodGet, "/epochs/summary?limit=-1", nil),
+			},
+			result: result{
+				code: http.StatusBadRequest,
+			},
+		},
+		{
+			name: "GET on '/' with an unknown fork should be 400",
+			fields: fields{
+				repo: mock.New(),
+			},
+			args: args{
+				r: httptest.NewRequest(http.MethodGet, "/?fork=shanghai", nil),
+			},
+			result: result{
+				code: http.StatusBadRequest,
+			},
+		},
+		{
+			name: "GET on '/forks' should be 200 OK",
+			fields: fields{
+				repo: mock.New(),
+			},
+			args: args{
+				r: httptest.NewRequest(http.MethodGet, "/forks", nil),
+			},
+			result: result{
+				code: http.StatusOK,
+			},
+		},
+		{
+			name: "GET on '/builders' should be 200 OK",
+			fields: fields{
+				repo: mock.New(),
+			},
+			args: args{
+				r: httptest.NewRequest(http.MethodGet, "/builders?from_epoch=1&to_epoch=10", nil),
+			},
+			result: result{
+				code: http.StatusOK,
+			},
+		},
+		{
+			name: "GET on '/builders' with a malformed epoch should be 400",
+			fields: fields{
+				repo: mock.New(),
+			},
+			args: args{
+				r: httptest.NewRequest(http.MethodGet, "/builders?to_epoch=x", nil),
+			},
+			result: result{
+				code: http.StatusBadRequest,
+			},
+		},
+		{
+			name: "GET on '/events' should be 200 OK",
+			fields: fields{
+				repo: mock.New(),
+			},
+			args: args{
+				r: httptest.NewRequest(http.MethodGet, "/events?contract=token&event=Transfer&from_block=1&limit=10", nil),
+			},
+			result: result{
+				code: http.StatusOK,
+			},
+		},
+		{
+			name: "GET on '/events' with a malformed block number should be 400",
+			fields: fields{
+				repo: mock.New(),
+			},
+			args: args{
+				r: httptest.NewRequest(http.MethodGet, "/events?from_block=abc", nil),
+			},
+			result: result{
+				code: http.StatusBadRequest,
+			},
+		},
+		{
+			name: "GET on '/validators/{index}' should be 200 OK",
+			fields: fields{
+				repo: mock.New(),
+			},
+			args: args{
+				r: httptest.NewRequest(http.MethodGet, "/validators/42?limit=10", nil),
+			},
+			result: result{
+				code: http.StatusOK,
+			},
+		},
+		{
+			name: "GET on '/validators/{pubkey}' should be 400",
+			fields: fields{
+				repo: mock.New(),
+			},
+			args: args{
+				r: httptest.NewRequest(http.MethodGet, "/validators/0xabc", nil),
+			},
+			result: result{
+				code: http.StatusBadRequest,
+			},
+		},
+		{
+			name: "GET on '/validators/{pubkey}' without a beacon node should be 501",
+			fields: fields{
+				repo: mock.New(),
+			},
+			args: args{
+				r: httptest.NewRequest(http.MethodGet, "/validators/"+pubkey, nil),
+			},
+			result: result{
+				code: http.StatusNotImplemented,
+			},
+		},
+		{
+			name: "POST on '/validators' should be 201 Created",
+			fields: fields{
+				repo: mock.New(),
+			},
+			args: args{
+				r: httptest.NewRequest(http.MethodPost, "/validators", strings.NewReader(`{"validator":"42"}`)),
+			},
+			result: result{
+				code: http.StatusCreated,
+			},
+		},
+		{
+			name: "POST on '/validators' with an invalid validator should be 400",
+			fields: fields{
+				repo: mock.New(),
+			},
+			args: args{
+				r: httptest.NewRequest(http.MethodPost, "/validators", strings.NewReader(`{"validator":"0x01"}`)),
+			},
+			result: result{
+				code: http.StatusBadRequest,
+			},
+		},
+		{
+			name: "DELETE on '/validators/{index}' should be 204",
+			fields: fields{
+				repo: mock.New(),
+			},
+			args: args{
+				r: httptest.NewRequest(http.MethodDelete, "/validators/42", nil),
+			},
+			result: result{
+				code: http.StatusNoContent,
+			},
+		},
+		{
+			name: "POST on '/' should be 405",
+			fields: fields{
+				repo: mock.New(),
+			},
+			args: args{
+				r: httptest.NewRequest(http.MethodPost, "/", nil),
+			},
+			result: result{
+				code: http.StatusMethodNotAllowed,
+			},
+		},
+		{
+			name: "GET on '/epochs/stream' without a feed should be 501",
+			fields: fields{
+				repo: mock.New(),
+			},
+			args: args{
+				r: httptest.NewRequest(http.MethodGet, "/epochs/stream", nil),
+			},
+			result: result{
+				code: http.StatusNotImplemented,
+			},
+		},
 		{
 			name: "only GET is allowed, any other Method should be 405",
 			fields: fields{
@@ -62,6 +275,42 @@ func TestHTTP_ServeHTTP(t *testing.T) {
 				code: http.StatusNotFound,
 			},
 		},
+		{
+			name: "POST on '/epochs' should be 405",
+			fields: fields{
+				repo: mock.New(),
+			},
+			args: args{
+				r: httptest.NewRequest(http.MethodPost, "/epochs", nil),
+			},
+			result: result{
+				code: http.StatusMethodNotAllowed,
+			},
+		},
+		{
+			name: "GET on an unknown resource of an epoch should be 404",
+			fields: fields{
+				repo: mock.New(),
+			},
+			args: args{
+				r: httptest.NewRequest(http.MethodGet, "/epochs/1/blocks", nil),
+			},
+			result: result{
+				code: http.StatusNotFound,
+			},
+		},
+		{
+			name: "GET on '/blocks/by-number/{number}' of an unknown block should be 404",
+			fields: fields{
+				repo: mock.New(),
+			},
+			args: args{
+				r: httptest.NewRequest(http.MethodGet, "/blocks/by-number/1", nil),
+			},
+			result: result{
+				code: http.StatusNotFound,
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -74,3 +323,327 @@ func TestHTTP_ServeHTTP(t *testing.T) {
 		})
 	}
 }
+
+func Test_overallShare(t *testing.T) {
+	got := overallShare([]models.BuilderShare{
+		{EpochNumber: 2, Builder: "flashbots", Blocks: 3, Share: 0.75},
+		{EpochNumber: 2, Builder: "local", Blocks: 1, Share: 0.25},
+		{EpochNumber: 1, Builder: "local", Blocks: 2, Share: 0.5},
+		{EpochNumber: 1, Builder: "titan", Blocks: 2, Share: 0.5},
+	})
+	want := []models.BuilderShare{
+		{Builder: "flashbots", Blocks: 3, Share: 0.375},
+		{Builder: "local", Blocks: 3, Share: 0.375},
+		{Builder: "titan", Blocks: 2, Share: 0.25},
+	}
+	assert.Equal(t, want, got)
+}
+
+func Test_forkAt(t *testing.T) {
+	forks := []models.Fork{
+		{Name: "phase0", Epoch: 0},
+		{Name: "altair", Epoch: 0},
+		{Name: "bellatrix", Epoch: 10},
+		{Name: "capella", Epoch: 20},
+	}
+	tests := []struct {
+		epoch uint64
+		want  string
+	}{
+		{epoch: 0, want: "altair"},
+		{epoch: 9, want: "altair"},
+		{epoch: 10, want: "bellatrix"},
+		{epoch: 1000, want: "capella"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, forkAt(forks, tt.epoch), "epoch %d", tt.epoch)
+	}
+	assert.Equal(t, "", forkAt(nil, 5), "no fork without a schedule")
+}
+
+func TestHTTP_ServeHTTP_bodies(t *testing.T) {
+	ctx := context.Background()
+	repo := store.NewMemory()
+	epochs := storetest.Epochs(0, 2)
+	epochs[1].Stats = &models.EpochStats{EpochNumber: 1, ProposedSlots: 4, GasUsed: 60_000_000, GasLimit: 120_000_000, GasUtilisation: 0.5}
+	epochs[1].Slots[0].Block.Builder = "flashbots"
+	epochs[1].Slots[0].Block.Events = []models.ContractEvent{{
+		BlockNumber: 4, TransactionHash: epochs[1].Slots[0].Block.Receipts[0].TransactionHash, Address: "0xa0b8",
+		Contract: "usdc", Event: "Transfer", Signature: "Transfer(address,address,uint256)", Args: map[string]interface{}{"value": "100"},
+	}}
+	for _, epoch := range epochs {
+		_, err := repo.Create(ctx, epoch, models.ConflictSkip)
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, repo.SaveForkSchedule(ctx, []models.Fork{{Name: "phase0"}, {Name: "altair", Epoch: 1}}))
+	assert.NoError(t, repo.CreateValidatorActivity(ctx, []models.ValidatorActivity{{ValidatorIndex: 42, EpochNumber: 1, Balance: 32}}))
+
+	// epochs are read back without their stats & events
+	stored := storetest.Epochs(0, 2)
+	stored[0].Fork, stored[1].Fork = "phase0", "altair"
+	stored[1].Slots[0].Block.Builder = "flashbots"
+	events := epochs[1].Slots[0].Block.Events
+	events[0].BlockRoot = epochs[1].Slots[0].Block.BlockRoot
+	latest, err := repo.GetEpochs(ctx, models.RangeQuery{Descending: true, Limit: 1})
+	assert.NoError(t, err)
+	tests := []struct {
+		name string
+		r    *http.Request
+		code int
+		want interface{}
+	}{
+		{
+			name: "GET on '/' should return the epochs along with their fork",
+			r:    httptest.NewRequest(http.MethodGet, "/", nil),
+			code: http.StatusOK,
+			want: stored,
+		},
+		{
+			name: "GET on '/?fork=altair' should return the epochs of the fork",
+			r:    httptest.NewRequest(http.MethodGet, "/?fork=altair", nil),
+			code: http.StatusOK,
+			want: stored[1:],
+		},
+		{
+			name: "GET on '/epochs/summary' should return the stats",
+			r:    httptest.NewRequest(http.MethodGet, "/epochs/summary", nil),
+			code: http.StatusOK,
+			want: []models.EpochStats{*epochs[1].Stats},
+		},
+		{
+			name: "GET on '/forks' should return the fork schedule",
+			r:    httptest.NewRequest(http.MethodGet, "/forks", nil),
+			code: http.StatusOK,
+			want: []models.Fork{{Name: "phase0"}, {Name: "altair", Epoch: 1}},
+		},
+		{
+			name: "GET on '/builders' should return the shares",
+			r:    httptest.NewRequest(http.MethodGet, "/builders", nil),
+			code: http.StatusOK,
+			want: map[string][]models.BuilderShare{
+				"epochs":  {{EpochNumber: 1, Builder: "flashbots", Blocks: 1, Share: 1}},
+				"overall": {{Builder: "flashbots", Blocks: 1, Share: 1}},
+			},
+		},
+		{
+			name: "GET on '/events' should return the matching events",
+			r:    httptest.NewRequest(http.MethodGet, "/events?contract=usdc", nil),
+			code: http.StatusOK,
+			want: events,
+		},
+		{
+			name: "GET on '/epochs' should return a page of epochs along with their fork",
+			r:    httptest.NewRequest(http.MethodGet, "/epochs", nil),
+			code: http.StatusOK,
+			want: models.EpochPage{Epochs: stored},
+		},
+		{
+			name: "GET on '/epochs?order=desc&limit=1' should return the latest epoch & a cursor",
+			r:    httptest.NewRequest(http.MethodGet, "/epochs?order=desc&limit=1", nil),
+			code: http.StatusOK,
+			want: models.EpochPage{Epochs: stored[1:], Next: latest.Next},
+		},
+		{
+			name: "GET on '/epochs?order=up' should be 400",
+			r:    httptest.NewRequest(http.MethodGet, "/epochs?order=up", nil),
+			code: http.StatusBadRequest,
+			want: map[string]string{"message": `invalid order: "up", expected asc or desc`},
+		},
+		{
+			name: "GET on '/epochs?limit=0' should be 400",
+			r:    httptest.NewRequest(http.MethodGet, "/epochs?limit=0", nil),
+			code: http.StatusBadRequest,
+			want: map[string]string{"message": `invalid limit: "0", expected 1 to 100`},
+		},
+		{
+			name: "GET on '/epochs/summary?limit=101' should be 400",
+			r:    httptest.NewRequest(http.MethodGet, "/epochs/summary?limit=101", nil),
+			code: http.StatusBadRequest,
+			want: map[string]string{"message": `invalid limit: "101", expected 1 to 100`},
+		},
+		{
+			name: "GET on '/validators/{index}?limit=0' should be 400",
+			r:    httptest.NewRequest(http.MethodGet, "/validators/42?limit=0", nil),
+			code: http.StatusBadRequest,
+			want: map[string]string{"message": `invalid limit: "0", expected 1 to 100`},
+		},
+		{
+			name: "GET on '/events?limit=0' should be 400",
+			r:    httptest.NewRequest(http.MethodGet, "/events?limit=0", nil),
+			code: http.StatusBadRequest,
+			want: map[string]string{"message": `invalid limit: "0", expected 1 to 1000`},
+		},
+		{
+			name: "GET on '/?fork=' of an unknown fork should be 400",
+			r:    httptest.NewRequest(http.MethodGet, "/?fork=nope", nil),
+			code: http.StatusBadRequest,
+			want: map[string]string{"message": `unknown fork: "nope"`},
+		},
+		{
+			name: "POST on '/validators' of a malformed body should be 400",
+			r:    httptest.NewRequest(http.MethodPost, "/validators", strings.NewReader("{")),
+			code: http.StatusBadRequest,
+			want: map[string]string{"message": "invalid body, err: unexpected EOF"},
+		},
+		{
+			name: "GET on an unknown path should be 404",
+			r:    httptest.NewRequest(http.MethodGet, "/something_else", nil),
+			code: http.StatusNotFound,
+			want: map[string]string{"message": "/something_else not found"},
+		},
+		{
+			name: "PUT on '/validators' should be 405",
+			r:    httptest.NewRequest(http.MethodPut, "/validators", nil),
+			code: http.StatusMethodNotAllowed,
+			want: map[string]string{"message": "PUT not allowed on /validators"},
+		},
+		{
+			name: "GET on '/epochs?cursor=' of an unknown cursor should be 400",
+			r:    httptest.NewRequest(http.MethodGet, "/epochs?cursor=nope", nil),
+			code: http.StatusBadRequest,
+			want: map[string]string{"message": `invalid cursor: "nope"`},
+		},
+		{
+			name: "GET on '/epochs/{number}' should return the epoch along with its fork",
+			r:    httptest.NewRequest(http.MethodGet, "/epochs/1", nil),
+			code: http.StatusOK,
+			want: stored[1],
+		},
+		{
+			name: "GET on '/epochs/{number}' of an unknown epoch should be 404",
+			r:    httptest.NewRequest(http.MethodGet, "/epochs/9", nil),
+			code: http.StatusNotFound,
+			want: map[string]string{"message": "epoch 9 not found"},
+		},
+		{
+			name: "GET on '/epochs/{number}' of something else than a number should be 400",
+			r:    httptest.NewRequest(http.MethodGet, "/epochs/latest", nil),
+			code: http.StatusBadRequest,
+			want: map[string]string{"message": `invalid epoch: "latest"`},
+		},
+		{
+			name: "GET on '/epochs/{number}/slots' should return the slots of the epoch",
+			r:    httptest.NewRequest(http.MethodGet, "/epochs/1/slots", nil),
+			code: http.StatusOK,
+			want: models.SlotPage{Slots: stored[1].Slots},
+		},
+		{
+			name: "GET on '/epochs/{number}/slots' of an unknown epoch should be 404",
+			r:    httptest.NewRequest(http.MethodGet, "/epochs/9/slots", nil),
+			code: http.StatusNotFound,
+			want: map[string]string{"message": "epoch 9 not found"},
+		},
+		{
+			name: "GET on '/slots/{number}' should return the slot",
+			r:    httptest.NewRequest(http.MethodGet, "/slots/4", nil),
+			code: http.StatusOK,
+			want: stored[1].Slots[0],
+		},
+		{
+			name: "GET on '/slots/{number}' of an unknown slot should be 404",
+			r:    httptest.NewRequest(http.MethodGet, "/slots/99", nil),
+			code: http.StatusNotFound,
+			want: map[string]string{"message": "slot 99 not found"},
+		},
+		{
+			name: "GET on '/blocks/{root}' should return the block",
+			r:    httptest.NewRequest(http.MethodGet, "/blocks/"+stored[1].Slots[0].Block.BlockRoot, nil),
+			code: http.StatusOK,
+			want: stored[1].Slots[0].Block,
+		},
+		{
+			name: "GET on '/blocks/{root}' of an unknown root should be 404",
+			r:    httptest.NewRequest(http.MethodGet, "/blocks/0x00", nil),
+			code: http.StatusNotFound,
+			want: map[string]string{"message": "block 0x00 not found"},
+		},
+		{
+			name: "GET on '/blocks/by-number/{number}' should return the canonical block",
+			r:    httptest.NewRequest(http.MethodGet, "/blocks/by-number/4", nil),
+			code: http.StatusOK,
+			want: stored[1].Slots[0].Block,
+		},
+		{
+			name: "GET on '/blocks/by-number/{number}' of an unknown number should be 404",
+			r:    httptest.NewRequest(http.MethodGet, "/blocks/by-number/99", nil),
+			code: http.StatusNotFound,
+			want: map[string]string{"message": "block 99 not found"},
+		},
+		{
+			name: "GET on '/validators/{index}' should return the history",
+			r:    httptest.NewRequest(http.MethodGet, "/validators/42", nil),
+			code: http.StatusOK,
+			want: []models.ValidatorActivity{{ValidatorIndex: 42, EpochNumber: 1, Balance: 32}},
+		},
+		{
+			name: "GET on '/validators/{pubkey}' should return the history of the validator's index",
+			r:    httptest.NewRequest(http.MethodGet, "/validators/"+pubkey, nil),
+			code: http.StatusOK,
+			want: []models.ValidatorActivity{{ValidatorIndex: 42, EpochNumber: 1, Balance: 32}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			New(repo, nil, validators{pubkey: 42}).ServeHTTP(w, tt.r)
+			assert.Equal(t, tt.code, w.Result().StatusCode)
+			want, err := json.Marshal(tt.want)
+			assert.NoError(t, err)
+			assert.JSONEq(t, string(want), w.Body.String())
+		})
+	}
+}
+
+func TestHTTP_ServeHTTP_allow(t *testing.T) {
+	w := httptest.NewRecorder()
+	New(mock.New(), nil, nil).ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/validators/42", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Result().StatusCode)
+	assert.Equal(t, "DELETE, GET", w.Result().Header.Get("Allow"))
+}
+
+func TestHTTP_ServeHTTP_unknownPubkey(t *testing.T) {
+	w := httptest.NewRecorder()
+	New(mock.New(), nil, validators{}).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/validators/"+pubkey, nil))
+	assert.Equal(t, http.StatusNotFound, w.Result().StatusCode)
+}
+
+func TestHTTP_ServeHTTP_watchedValidators(t *testing.T) {
+	h := New(store.NewMemory(), nil, nil)
+	for _, r := range []*http.Request{
+		httptest.NewRequest(http.MethodPost, "/validators", strings.NewReader(`{"validator":"42"}`)),
+		httptest.NewRequest(http.MethodPost, "/validators", strings.NewReader(`{"validator":"7"}`)),
+		httptest.NewRequest(http.MethodDelete, "/validators/42", nil),
+	} {
+		h.ServeHTTP(httptest.NewRecorder(), r)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/validators", nil))
+	assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+	assert.JSONEq(t, `["7"]`, w.Body.String())
+}
+
+// feeds the notifications it was given & ends the stream
+type feed []models.EpochNotification
+
+func (f feed) Subscribe(ctx context.Context) <-chan models.EpochNotification {
+	stream := make(chan models.EpochNotification, len(f))
+	for _, n := range f {
+		stream <- n
+	}
+	close(stream)
+	return stream
+}
+
+func TestHTTP_ServeHTTP_stream(t *testing.T) {
+	h := New(mock.New(), feed{
+		{EpochNumber: 1, FirstSlot: 32, LastSlot: 63, HeadRoot: "0xab"},
+		{EpochNumber: 2, FirstSlot: 64, LastSlot: 95, Result: &models.WriteResult{Inserted: 33}},
+	}, nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/epochs/stream", nil))
+	assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+	assert.Equal(t, "text/event-stream", w.Result().Header.Get("Content-Type"))
+	assert.Equal(t, "event: epoch\nid: 1\ndata: {\"epochNumber\":1,\"firstSlot\":32,\"lastSlot\":63,\"headRoot\":\"0xab\"}\n\n"+
+		"event: epoch\nid: 2\ndata: {\"epochNumber\":2,\"firstSlot\":64,\"lastSlot\":95,\"result\":{\"inserted\":33,\"updated\":0,\"skipped\":0}}\n\n",
+		w.Body.String())
+}
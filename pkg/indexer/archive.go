@@ -0,0 +1,287 @@
+package indexer
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"indexer/pkg/config"
+	"indexer/pkg/models"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/attestantio/go-eth2-client/spec"
+	"github.com/attestantio/go-eth2-client/spec/altair"
+	"github.com/attestantio/go-eth2-client/spec/bellatrix"
+	"github.com/attestantio/go-eth2-client/spec/capella"
+	"github.com/attestantio/go-eth2-client/spec/deneb"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/golang/snappy"
+)
+
+// e2store record header size, see https://github.com/status-im/nimbus-eth2/blob/stable/docs/e2store.md
+const e2sHeaderSize = 8
+
+// type bytes of the e2store record of a snappy compressed block, in the order they are found in .era files
+var e2sCompressedSignedBeaconBlock = [2]byte{0x01, 0x00}
+
+// offset of the slot within an SSZ encoded SignedBeaconBlock: message offset (4) + signature (96)
+const sszSlotOffset = 4 + 96
+
+// Archive reads signed beacon blocks from local .era files or a directory of .ssz files, it never touches the network
+type Archive struct {
+	path  string
+	chain config.ChainCfg
+}
+
+// Creates new archive source for a .era/.ssz file or a directory holding either of them
+func NewArchive(path string, chain config.ChainCfg) *Archive {
+	return &Archive{path, chain}
+}
+
+// returns the fork schedule of the configured chain spec, fork versions are unknown offline
+func (a *Archive) ForkSchedule(ctx context.Context) ([]models.Fork, error) {
+	return []models.Fork{
+		{Name: spec.DataVersionPhase0.String(), Epoch: 0},
+		{Name: spec.DataVersionAltair.String(), Epoch: a.chain.AltairForkEpoch},
+		{Name: spec.DataVersionBellatrix.String(), Epoch: a.chain.BellatrixForkEpoch},
+		{Name: spec.DataVersionCapella.String(), Epoch: a.chain.CapellaForkEpoch},
+		{Name: spec.DataVersionDeneb.String(), Epoch: a.chain.DenebForkEpoch},
+	}, nil
+}
+
+func (a *Archive) SubscribeToEpochs(ctx context.Context) <-chan EpochResult {
+	epochStream := make(chan EpochResult)
+
+	go func() {
+		defer close(epochStream)
+
+		emit := func(result EpochResult) bool {
+			select {
+			case <-ctx.Done():
+				return false
+			case epochStream <- result:
+				return true
+			}
+		}
+
+		builder := newEpochBuilder(time.Unix(a.chain.GenesisTime, 0), a.chain.SlotsPerEpoch, a.chain.SlotDuration)
+		err := a.walk(ctx, func(block *spec.VersionedSignedBeaconBlock) error {
+			aBlock, err := toBlock(block)
+			if err != nil {
+				return err
+			}
+			root, err := block.Root()
+			if err != nil {
+				return fmt.Errorf("block.Root() failed, err: %v", err.Error())
+			}
+			aBlock.BlockRoot = root.String()
+			if anEpoch := builder.add(models.Slot{SlotNumber: aBlock.SlotNumber, Block: aBlock}); anEpoch != nil {
+				if !emit(EpochResult{Epoch: anEpoch, Error: nil}) {
+					return ctx.Err()
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			emit(EpochResult{
+				Epoch: nil,
+				Error: fmt.Errorf("archive ingestion failed, err: %v", err.Error()),
+			})
+			return
+		}
+		if anEpoch := builder.flush(); anEpoch != nil {
+			emit(EpochResult{Epoch: anEpoch, Error: nil})
+		}
+	}()
+
+	return epochStream
+}
+
+// calls fn for every block in the archive in slot order
+func (a *Archive) walk(ctx context.Context, fn func(*spec.VersionedSignedBeaconBlock) error) error {
+	info, err := os.Stat(a.path)
+	if err != nil {
+		return err
+	}
+	files := []string{a.path}
+	if info.IsDir() {
+		entries, err := os.ReadDir(a.path)
+		if err != nil {
+			return err
+		}
+		files = files[:0]
+		for _, entry := range entries {
+			if !entry.IsDir() {
+				files = append(files, filepath.Join(a.path, entry.Name()))
+			}
+		}
+	}
+
+	var eras, ssz []string
+	for _, file := range files {
+		switch strings.ToLower(filepath.Ext(file)) {
+		case ".era":
+			eras = append(eras, file)
+		case ".ssz":
+			ssz = append(ssz, file)
+		}
+	}
+	switch {
+	case len(eras) > 0 && len(ssz) > 0:
+		return errors.New("mixing .era & .ssz files is not supported")
+	case len(eras) > 0:
+		// era files are named <network>-<era number>-<root>.era, lexical order is chronological
+		sort.Strings(eras)
+		for _, file := range eras {
+			if err := a.walkEra(ctx, file, fn); err != nil {
+				return fmt.Errorf("%s: %w", filepath.Base(file), err)
+			}
+		}
+	case len(ssz) > 0:
+		return a.walkSSZ(ctx, ssz, fn)
+	default:
+		return fmt.Errorf("no .era or .ssz files found at %s", a.path)
+	}
+	return nil
+}
+
+// reads the compressed blocks of an e2store encoded .era file, skipping all other records
+func (a *Archive) walkEra(ctx context.Context, file string, fn func(*spec.VersionedSignedBeaconBlock) error) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	header := make([]byte, e2sHeaderSize)
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		_, err := io.ReadFull(r, header)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("read e2store header: %w", err)
+		}
+		recordType := [2]byte{header[0], header[1]}
+		length := int64(binary.LittleEndian.Uint32(header[2:6]))
+		if recordType != e2sCompressedSignedBeaconBlock {
+			if _, err := io.CopyN(io.Discard, r, length); err != nil {
+				return fmt.Errorf("skip e2store record: %w", err)
+			}
+			continue
+		}
+		data, err := io.ReadAll(snappy.NewReader(io.LimitReader(r, length)))
+		if err != nil {
+			return fmt.Errorf("decompress block: %w", err)
+		}
+		block, err := decodeSignedBlock(data, a.chain)
+		if err != nil {
+			return err
+		}
+		if err := fn(block); err != nil {
+			return err
+		}
+	}
+}
+
+// reads one SSZ encoded signed block per file, ordered by slot
+func (a *Archive) walkSSZ(ctx context.Context, files []string, fn func(*spec.VersionedSignedBeaconBlock) error) error {
+	slots := make(map[string]uint64, len(files))
+	for _, file := range files {
+		slot, err := readSSZSlot(file)
+		if err != nil {
+			return fmt.Errorf("%s: %w", filepath.Base(file), err)
+		}
+		slots[file] = slot
+	}
+	sort.SliceStable(files, func(i, j int) bool { return slots[files[i]] < slots[files[j]] })
+
+	for _, file := range files {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		block, err := decodeSignedBlock(data, a.chain)
+		if err != nil {
+			return fmt.Errorf("%s: %w", filepath.Base(file), err)
+		}
+		if err := fn(block); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readSSZSlot(file string) (uint64, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	prefix := make([]byte, sszSlotOffset+8)
+	if _, err := io.ReadFull(f, prefix); err != nil {
+		return 0, fmt.Errorf("read slot: %w", err)
+	}
+	return binary.LittleEndian.Uint64(prefix[sszSlotOffset:]), nil
+}
+
+// decodes an SSZ encoded signed block, its fork is derived from its slot & the configured fork epochs
+func decodeSignedBlock(data []byte, chain config.ChainCfg) (*spec.VersionedSignedBeaconBlock, error) {
+	if len(data) < sszSlotOffset+8 {
+		return nil, fmt.Errorf("signed block too short, %d bytes", len(data))
+	}
+	slot := binary.LittleEndian.Uint64(data[sszSlotOffset : sszSlotOffset+8])
+
+	var err error
+	block := &spec.VersionedSignedBeaconBlock{Version: forkAt(chain, slot/chain.SlotsPerEpoch)}
+	switch block.Version {
+	case spec.DataVersionPhase0:
+		block.Phase0 = &phase0.SignedBeaconBlock{}
+		err = block.Phase0.UnmarshalSSZ(data)
+	case spec.DataVersionAltair:
+		block.Altair = &altair.SignedBeaconBlock{}
+		err = block.Altair.UnmarshalSSZ(data)
+	case spec.DataVersionBellatrix:
+		block.Bellatrix = &bellatrix.SignedBeaconBlock{}
+		err = block.Bellatrix.UnmarshalSSZ(data)
+	case spec.DataVersionCapella:
+		block.Capella = &capella.SignedBeaconBlock{}
+		err = block.Capella.UnmarshalSSZ(data)
+	case spec.DataVersionDeneb:
+		block.Deneb = &deneb.SignedBeaconBlock{}
+		err = block.Deneb.UnmarshalSSZ(data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("decode %s block at slot %d: %w", block.Version.String(), slot, err)
+	}
+	return block, nil
+}
+
+// returns the fork active at the given epoch
+func forkAt(chain config.ChainCfg, epoch uint64) spec.DataVersion {
+	switch {
+	case epoch >= chain.DenebForkEpoch:
+		return spec.DataVersionDeneb
+	case epoch >= chain.CapellaForkEpoch:
+		return spec.DataVersionCapella
+	case epoch >= chain.BellatrixForkEpoch:
+		return spec.DataVersionBellatrix
+	case epoch >= chain.AltairForkEpoch:
+		return spec.DataVersionAltair
+	default:
+		return spec.DataVersionPhase0
+	}
+}
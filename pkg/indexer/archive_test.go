@@ -0,0 +1,125 @@
+package indexer
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"indexer/pkg/config"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/golang/snappy"
+	"github.com/stretchr/testify/assert"
+)
+
+func phase0Block(t *testing.T, slot uint64) []byte {
+	block := &phase0.SignedBeaconBlock{
+		Message: &phase0.BeaconBlock{
+			Slot: phase0.Slot(slot),
+			Body: &phase0.BeaconBlockBody{
+				ETH1Data: &phase0.ETH1Data{
+					BlockHash: make([]byte, 32),
+				},
+			},
+		},
+	}
+	data, err := block.MarshalSSZ()
+	assert.Nil(t, err, "MarshalSSZ() must not fail")
+	return data
+}
+
+// the type bytes of e2store records as the spec lists them
+var (
+	e2sVersion   = [2]byte{0x65, 0x32}
+	e2sBlock     = [2]byte{0x01, 0x00}
+	e2sSlotIndex = [2]byte{0x69, 0x32}
+)
+
+// an e2store record: 2 type bytes, a 4 byte little endian length, 2 reserved bytes & the data
+func e2sRecord(t *testing.T, recordType [2]byte, data []byte) []byte {
+	header := []byte{recordType[0], recordType[1], 0, 0, 0, 0, 0, 0}
+	binary.LittleEndian.PutUint32(header[2:6], uint32(len(data)))
+	return append(header, data...)
+}
+
+func TestArchive_SubscribeToEpochs(t *testing.T) {
+	chain := config.ChainCfg{
+		GenesisTime:        1606824023,
+		SlotsPerEpoch:      2,
+		SlotDuration:       12 * time.Second,
+		AltairForkEpoch:    1000,
+		BellatrixForkEpoch: 1000,
+		CapellaForkEpoch:   1000,
+		DenebForkEpoch:     1000,
+	}
+	slots := []uint64{4, 0, 1, 3, 2}
+
+	sszDir := t.TempDir()
+	for _, slot := range slots {
+		err := os.WriteFile(filepath.Join(sszDir, fmt.Sprintf("block-%d.ssz", 10-slot)), phase0Block(t, slot), 0o600)
+		assert.Nil(t, err, "os.WriteFile() must not fail")
+	}
+
+	eraDir := t.TempDir()
+	era := e2sRecord(t, e2sVersion, nil)
+	for slot := uint64(0); slot < 5; slot++ {
+		compressed := &bytes.Buffer{}
+		w := snappy.NewBufferedWriter(compressed)
+		_, err := w.Write(phase0Block(t, slot))
+		assert.Nil(t, err, "snappy write must not fail")
+		assert.Nil(t, w.Close(), "snappy close must not fail")
+		era = append(era, e2sRecord(t, e2sBlock, compressed.Bytes())...)
+	}
+	era = append(era, e2sRecord(t, e2sSlotIndex, make([]byte, 24))...)
+	err := os.WriteFile(filepath.Join(eraDir, "mainnet-00000-00000000.era"), era, 0o600)
+	assert.Nil(t, err, "os.WriteFile() must not fail")
+
+	tests := []struct {
+		name string
+		path string
+	}{
+		{
+			name: "should build epochs from a directory of .ssz blocks",
+			path: sszDir,
+		},
+		{
+			name: "should build epochs from .era files",
+			path: eraDir,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var epochs []uint64
+			var slotsPerEpoch []int
+			for result := range NewArchive(tt.path, chain).SubscribeToEpochs(context.Background()) {
+				assert.Nil(t, result.Error, "ingestion must not fail")
+				if result.Epoch != nil {
+					epochs = append(epochs, result.Epoch.EpochNumber)
+					slotsPerEpoch = append(slotsPerEpoch, len(result.Epoch.Slots))
+					assert.Equal(t, time.Unix(1606824023+int64(result.Epoch.EpochNumber)*24, 0), result.Epoch.StartTime, "epoch must start at its genesis based time")
+					for _, slot := range result.Epoch.Slots {
+						assert.Equal(t, result.Epoch.EpochNumber, slot.EpochNumber, "slot must belong to its epoch")
+						assert.NotEmpty(t, slot.Block.BlockRoot, "block root must be computed")
+						assert.Equal(t, "phase0", slot.Block.Version, "block must record its fork")
+					}
+				}
+			}
+			assert.Equal(t, []uint64{0, 1, 2}, epochs)
+			assert.Equal(t, []int{2, 2, 1}, slotsPerEpoch)
+		})
+	}
+
+	t.Run("should fail for an empty directory", func(t *testing.T) {
+		var errs int
+		for result := range NewArchive(t.TempDir(), chain).SubscribeToEpochs(context.Background()) {
+			if result.Error != nil {
+				errs++
+			}
+		}
+		assert.Equal(t, 1, errs)
+	})
+}
@@ -0,0 +1,235 @@
+package indexer
+
+import (
+	"encoding/hex"
+	"fmt"
+	"indexer/pkg/models"
+	"log"
+	"time"
+
+	"github.com/attestantio/go-eth2-client/spec"
+)
+
+// assembles incoming slots into epochs, shared by every ingestion source, slot & epoch times are derived from
+// the genesis time so that they are exact even when the first slots of an epoch are missed
+type epochBuilder struct {
+	genesisTime   time.Time
+	slotsPerEpoch uint64
+	slotDuration  time.Duration
+	epochDuration time.Duration
+	lastEpoch     uint64
+	slots         []models.Slot
+}
+
+func newEpochBuilder(genesisTime time.Time, slotsPerEpoch uint64, slotDuration time.Duration) *epochBuilder {
+	return &epochBuilder{
+		genesisTime:   genesisTime,
+		slotsPerEpoch: slotsPerEpoch,
+		slotDuration:  slotDuration,
+		epochDuration: time.Duration(slotsPerEpoch) * slotDuration,
+		slots:         make([]models.Slot, 0, slotsPerEpoch),
+	}
+}
+
+// adds a slot, returns the previous epoch once the slot crosses an epoch boundary, a block for a slot already
+// added replaces its block, which is kept as a candidate, blocks of epochs already returned are dropped
+func (eb *epochBuilder) add(aSlot models.Slot) *models.Epoch {
+	epoch := aSlot.SlotNumber / eb.slotsPerEpoch
+	if len(eb.slots) > 0 && epoch < eb.lastEpoch {
+		log.Printf("block %s of slot %d arrived after its epoch was complete, dropped\n", aSlot.Block.BlockRoot, aSlot.SlotNumber)
+		return nil
+	}
+
+	aSlot.Block.SlotNumber = aSlot.SlotNumber
+	aSlot.Block.Canonical = true
+	aSlot.StartTime = eb.genesisTime.Add(time.Duration(aSlot.SlotNumber) * eb.slotDuration)
+	aSlot.EndTime = aSlot.StartTime.Add(eb.slotDuration)
+	aSlot.EpochNumber = epoch
+
+	var anEpoch *models.Epoch
+	if len(eb.slots) > 0 && eb.lastEpoch != epoch {
+		anEpoch = eb.flush()
+	}
+	eb.lastEpoch = epoch
+
+	for idx := range eb.slots {
+		if eb.slots[idx].SlotNumber == aSlot.SlotNumber {
+			previous := eb.slots[idx].Block
+			previous.Canonical = false
+			eb.slots[idx].Candidates = append(eb.slots[idx].Candidates, previous)
+			eb.slots[idx].Block = aSlot.Block
+			return anEpoch
+		}
+	}
+	eb.slots = append(eb.slots, aSlot)
+	return anEpoch
+}
+
+// returns the epoch assembled so far, nil if there is none
+func (eb *epochBuilder) flush() *models.Epoch {
+	if len(eb.slots) == 0 {
+		return nil
+	}
+	anEpoch := eb.epoch(eb.lastEpoch, canonicalize(eb.slots))
+	eb.slots = make([]models.Slot, 0, eb.slotsPerEpoch)
+	return anEpoch
+}
+
+// an epoch of the given slots with its times & stats, slots left out are missed
+func (eb *epochBuilder) epoch(number uint64, slots []models.Slot) *models.Epoch {
+	anEpoch := models.Epoch{
+		EpochNumber: number,
+		Slots:       slots,
+	}
+	anEpoch.StartTime = eb.genesisTime.Add(time.Duration(number) * eb.epochDuration)
+	anEpoch.EndTime = anEpoch.StartTime.Add(eb.epochDuration)
+	anEpoch.Stats = summarizeEpoch(anEpoch, eb.slotsPerEpoch)
+	return &anEpoch
+}
+
+// follows parent roots from the last block back, blocks off that chain become candidates of their slot, which is
+// left without a block if none of its blocks is on the chain, slots before the earliest block reached are left as is
+func canonicalize(slots []models.Slot) []models.Slot {
+	if len(slots) == 0 || slots[len(slots)-1].Block.BlockRoot == "" {
+		return slots
+	}
+	byRoot := make(map[string]models.Block)
+	for _, slot := range slots {
+		for _, block := range append([]models.Block{slot.Block}, slot.Candidates...) {
+			if block.BlockRoot != "" {
+				byRoot[block.BlockRoot] = block
+			}
+		}
+	}
+	onChain := make(map[string]bool)
+	earliest := slots[len(slots)-1].SlotNumber
+	for root := slots[len(slots)-1].Block.BlockRoot; root != ""; {
+		block, ok := byRoot[root]
+		if !ok {
+			break
+		}
+		onChain[root] = true
+		earliest = block.SlotNumber
+		root = block.ParentRoot
+	}
+
+	for idx := range slots {
+		if slots[idx].SlotNumber < earliest {
+			continue
+		}
+		blocks := append([]models.Block{slots[idx].Block}, slots[idx].Candidates...)
+		slots[idx].Block = models.Block{}
+		slots[idx].Candidates = nil
+		for _, block := range blocks {
+			if block.BlockRoot == "" {
+				continue
+			}
+			block.Canonical = onChain[block.BlockRoot]
+			if block.Canonical {
+				slots[idx].Block = block
+			} else {
+				slots[idx].Candidates = append(slots[idx].Candidates, block)
+			}
+		}
+	}
+	return slots
+}
+
+// aggregates the canonical blocks of an epoch, slots without a block are missed, whether every block of the slot
+// was reorged out or it never had one
+func summarizeEpoch(epoch models.Epoch, slotsPerEpoch uint64) *models.EpochStats {
+	stats := &models.EpochStats{EpochNumber: epoch.EpochNumber}
+	var previous *models.Block
+	for idx := range epoch.Slots {
+		block := &epoch.Slots[idx].Block
+		if block.BlockRoot == "" {
+			continue
+		}
+		stats.ProposedSlots++
+		stats.NoOfTransactions += block.NoOfTransactions
+		stats.GasUsed += block.GasUsed
+		stats.GasLimit += block.GasLimit
+		if previous != nil {
+			blockTime := block.CreatedAt.Sub(previous.CreatedAt).Seconds()
+			if stats.ProposedSlots == 2 || blockTime < stats.MinBlockTime {
+				stats.MinBlockTime = blockTime
+			}
+			if blockTime > stats.MaxBlockTime {
+				stats.MaxBlockTime = blockTime
+			}
+			stats.AvgBlockTime += blockTime
+		}
+		previous = block
+	}
+	stats.MissedSlots = int(slotsPerEpoch) - stats.ProposedSlots
+	if stats.MissedSlots < 0 {
+		stats.MissedSlots = 0
+	}
+	if stats.ProposedSlots > 0 {
+		stats.AvgGasUsed = stats.GasUsed / uint64(stats.ProposedSlots)
+	}
+	if stats.ProposedSlots > 1 {
+		stats.AvgBlockTime /= float64(stats.ProposedSlots - 1)
+	}
+	if stats.GasLimit > 0 {
+		stats.GasUtilisation = float64(stats.GasUsed) / float64(stats.GasLimit)
+	}
+	return stats
+}
+
+// maps a signed beacon block of any supported fork to a models.Block, BlockRoot is left to the caller
+func toBlock(block *spec.VersionedSignedBeaconBlock) (models.Block, error) {
+	aBlock := models.Block{}
+	slot, err := block.Slot()
+	if err != nil {
+		return aBlock, fmt.Errorf("block.Slot() failed, err: %v", err.Error())
+	}
+	aBlock.SlotNumber = uint64(slot)
+	stateRoot, err := block.StateRoot()
+	if err != nil {
+		return aBlock, fmt.Errorf("block.StateRoot() failed, err: %v", err.Error())
+	}
+	aBlock.StateRoot = stateRoot.String()
+	aBlock.Version = block.Version.String()
+	parentRoot, err := block.ParentRoot()
+	if err != nil {
+		return aBlock, fmt.Errorf("block.ParentRoot() failed, err: %v", err.Error())
+	}
+	aBlock.ParentRoot = parentRoot.String()
+
+	switch block.Version {
+	case spec.DataVersionBellatrix:
+		aBlock.BlockNumber = block.Bellatrix.Message.Body.ExecutionPayload.BlockNumber
+		aBlock.ExecutionBlockHash = fmt.Sprintf("%#x", block.Bellatrix.Message.Body.ExecutionPayload.BlockHash[:])
+		aBlock.GasLimit = block.Bellatrix.Message.Body.ExecutionPayload.GasLimit
+		aBlock.GasUsed = block.Bellatrix.Message.Body.ExecutionPayload.GasUsed
+		aBlock.NoOfTransactions = len(block.Bellatrix.Message.Body.ExecutionPayload.Transactions)
+		aBlock.CreatedAt = time.Unix(int64(block.Bellatrix.Message.Body.ExecutionPayload.Timestamp), 0)
+		aBlock.FeeRecipient = fmt.Sprintf("%#x", block.Bellatrix.Message.Body.ExecutionPayload.FeeRecipient[:])
+		aBlock.ExtraData = toHex(block.Bellatrix.Message.Body.ExecutionPayload.ExtraData)
+	case spec.DataVersionCapella:
+		aBlock.BlockNumber = block.Capella.Message.Body.ExecutionPayload.BlockNumber
+		aBlock.ExecutionBlockHash = fmt.Sprintf("%#x", block.Capella.Message.Body.ExecutionPayload.BlockHash[:])
+		aBlock.GasLimit = block.Capella.Message.Body.ExecutionPayload.GasLimit
+		aBlock.GasUsed = block.Capella.Message.Body.ExecutionPayload.GasUsed
+		aBlock.NoOfTransactions = len(block.Capella.Message.Body.ExecutionPayload.Transactions)
+		aBlock.CreatedAt = time.Unix(int64(block.Capella.Message.Body.ExecutionPayload.Timestamp), 0)
+		aBlock.FeeRecipient = fmt.Sprintf("%#x", block.Capella.Message.Body.ExecutionPayload.FeeRecipient[:])
+		aBlock.ExtraData = toHex(block.Capella.Message.Body.ExecutionPayload.ExtraData)
+	case spec.DataVersionDeneb:
+		aBlock.BlockNumber = block.Deneb.Message.Body.ExecutionPayload.BlockNumber
+		aBlock.ExecutionBlockHash = fmt.Sprintf("%#x", block.Deneb.Message.Body.ExecutionPayload.BlockHash[:])
+		aBlock.GasLimit = block.Deneb.Message.Body.ExecutionPayload.GasLimit
+		aBlock.GasUsed = block.Deneb.Message.Body.ExecutionPayload.GasUsed
+		aBlock.NoOfTransactions = len(block.Deneb.Message.Body.ExecutionPayload.Transactions)
+		aBlock.CreatedAt = time.Unix(int64(block.Deneb.Message.Body.ExecutionPayload.Timestamp), 0)
+		aBlock.FeeRecipient = fmt.Sprintf("%#x", block.Deneb.Message.Body.ExecutionPayload.FeeRecipient[:])
+		aBlock.ExtraData = toHex(block.Deneb.Message.Body.ExecutionPayload.ExtraData)
+	}
+	return aBlock, nil
+}
+
+// 0x prefixed hex encoding, 0x for empty data
+func toHex(data []byte) string {
+	return "0x" + hex.EncodeToString(data)
+}
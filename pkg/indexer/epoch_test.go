@@ -0,0 +1,161 @@
+package indexer
+
+import (
+	"fmt"
+	"indexer/pkg/models"
+	"testing"
+	"time"
+
+	"github.com/attestantio/go-eth2-client/spec"
+	"github.com/attestantio/go-eth2-client/spec/bellatrix"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_summarizeEpoch(t *testing.T) {
+	genesis := time.Unix(1606824023, 0)
+	block := func(slot uint64, gasUsed uint64, txs int) models.Slot {
+		return models.Slot{SlotNumber: slot, Block: models.Block{
+			BlockRoot:        fmt.Sprintf("0x%064x", slot),
+			SlotNumber:       slot,
+			GasLimit:         100,
+			GasUsed:          gasUsed,
+			NoOfTransactions: txs,
+			CreatedAt:        genesis.Add(time.Duration(slot) * 12 * time.Second),
+		}}
+	}
+
+	tests := []struct {
+		name  string
+		epoch models.Epoch
+		want  *models.EpochStats
+	}{
+		{
+			name:  "should count every slot as missed without blocks",
+			epoch: models.Epoch{EpochNumber: 1},
+			want:  &models.EpochStats{EpochNumber: 1, MissedSlots: 4},
+		},
+		{
+			name:  "should not report block times for a single block",
+			epoch: models.Epoch{EpochNumber: 1, Slots: []models.Slot{block(4, 50, 2)}},
+			want: &models.EpochStats{EpochNumber: 1, ProposedSlots: 1, MissedSlots: 3, NoOfTransactions: 2,
+				GasUsed: 50, GasLimit: 100, AvgGasUsed: 50, GasUtilisation: 0.5},
+		},
+		{
+			name:  "should aggregate gas & block times, a missed slot doubling the block time",
+			epoch: models.Epoch{EpochNumber: 1, Slots: []models.Slot{block(4, 50, 2), block(5, 100, 3), block(7, 30, 1)}},
+			want: &models.EpochStats{EpochNumber: 1, ProposedSlots: 3, MissedSlots: 1, NoOfTransactions: 6,
+				GasUsed: 180, GasLimit: 300, AvgGasUsed: 60, GasUtilisation: 0.6,
+				MinBlockTime: 12, MaxBlockTime: 24, AvgBlockTime: 18},
+		},
+		{
+			name:  "should count a slot without a block as missed",
+			epoch: models.Epoch{EpochNumber: 1, Slots: []models.Slot{block(4, 50, 2), {SlotNumber: 5}}},
+			want: &models.EpochStats{EpochNumber: 1, ProposedSlots: 1, MissedSlots: 3, NoOfTransactions: 2,
+				GasUsed: 50, GasLimit: 100, AvgGasUsed: 50, GasUtilisation: 0.5},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, summarizeEpoch(tt.epoch, 4))
+		})
+	}
+}
+
+func Test_epochBuilder(t *testing.T) {
+	genesis := time.Unix(1606824023, 0)
+	builder := newEpochBuilder(genesis, 4, 12*time.Second)
+	// the payload timestamp is kept as is, even when it disagrees with the slot time
+	payloadTime := genesis.Add(time.Hour)
+	for _, slot := range []uint64{5, 7} {
+		assert.Nil(t, builder.add(models.Slot{SlotNumber: slot, Block: models.Block{CreatedAt: payloadTime}}))
+	}
+	epoch := builder.add(models.Slot{SlotNumber: 9})
+	if assert.NotNil(t, epoch, "crossing the epoch boundary must emit the epoch") {
+		assert.Equal(t, uint64(1), epoch.EpochNumber)
+		// the first slot of the epoch was missed, times must still be those of slot 4
+		assert.Equal(t, genesis.Add(48*time.Second), epoch.StartTime)
+		assert.Equal(t, genesis.Add(96*time.Second), epoch.EndTime)
+		assert.Equal(t, genesis.Add(84*time.Second), epoch.Slots[1].StartTime)
+		assert.Equal(t, genesis.Add(96*time.Second), epoch.Slots[1].EndTime)
+		assert.Equal(t, payloadTime, epoch.Slots[1].Block.CreatedAt)
+	}
+}
+
+func Test_epochBuilder_epoch(t *testing.T) {
+	genesis := time.Unix(1606824023, 0)
+	builder := newEpochBuilder(genesis, 4, 12*time.Second)
+	// every slot was missed
+	epoch := builder.epoch(2, nil)
+	assert.Equal(t, uint64(2), epoch.EpochNumber)
+	assert.Equal(t, genesis.Add(96*time.Second), epoch.StartTime)
+	assert.Equal(t, genesis.Add(144*time.Second), epoch.EndTime)
+	if assert.NotNil(t, epoch.Stats, "an epoch without blocks must have stats as well") {
+		assert.Equal(t, &models.EpochStats{EpochNumber: 2, MissedSlots: 4}, epoch.Stats)
+	}
+}
+
+func Test_epochBuilder_reorg(t *testing.T) {
+	genesis := time.Unix(1606824023, 0)
+	block := func(slot uint64, root, parent string) models.Slot {
+		return models.Slot{SlotNumber: slot, Block: models.Block{BlockRoot: root, ParentRoot: parent, GasLimit: 100}}
+	}
+	builder := newEpochBuilder(genesis, 4, 12*time.Second)
+	for _, slot := range []models.Slot{
+		block(4, "a", "z"),
+		block(5, "b", "a"),
+		// competing block for slot 5, then a block on top of it
+		block(5, "b'", "a"),
+		block(6, "c", "b'"),
+		// reorg dropping slots 5 & 6 altogether
+		block(7, "d", "a"),
+	} {
+		assert.Nil(t, builder.add(slot))
+	}
+	epoch := builder.add(block(8, "e", "d"))
+	assert.Nil(t, builder.add(block(6, "late", "a")), "blocks of a returned epoch must be dropped")
+	if !assert.NotNil(t, epoch) || !assert.Len(t, epoch.Slots, 4) {
+		return
+	}
+
+	canonical := func(slot models.Slot) string {
+		return slot.Block.BlockRoot
+	}
+	candidates := func(slot models.Slot) []string {
+		var roots []string
+		for _, b := range slot.Candidates {
+			assert.False(t, b.Canonical, "candidates must not be canonical")
+			roots = append(roots, b.BlockRoot)
+		}
+		return roots
+	}
+	assert.Equal(t, "a", canonical(epoch.Slots[0]))
+	assert.True(t, epoch.Slots[0].Block.Canonical)
+	assert.Equal(t, "", canonical(epoch.Slots[1]))
+	assert.ElementsMatch(t, []string{"b", "b'"}, candidates(epoch.Slots[1]))
+	assert.Equal(t, "", canonical(epoch.Slots[2]))
+	assert.Equal(t, []string{"c"}, candidates(epoch.Slots[2]))
+	assert.Equal(t, "d", canonical(epoch.Slots[3]))
+	assert.Nil(t, epoch.Slots[3].Candidates)
+	assert.Equal(t, 2, epoch.Stats.ProposedSlots, "reorged out slots must count as missed")
+	assert.Equal(t, 2, epoch.Stats.MissedSlots)
+}
+
+func Test_toBlock(t *testing.T) {
+	block := &spec.VersionedSignedBeaconBlock{
+		Version: spec.DataVersionBellatrix,
+		Bellatrix: &bellatrix.SignedBeaconBlock{Message: &bellatrix.BeaconBlock{
+			Slot: 4700013,
+			Body: &bellatrix.BeaconBlockBody{ExecutionPayload: &bellatrix.ExecutionPayload{
+				BlockNumber:  15537394,
+				BlockHash:    [32]byte{0x56, 0xa9},
+				GasLimit:     30_000_000,
+				Transactions: []bellatrix.Transaction{{0x02}},
+			}},
+		}},
+	}
+	aBlock, err := toBlock(block)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(15537394), aBlock.BlockNumber)
+	assert.Equal(t, fmt.Sprintf("0x56a9%060x", 0), aBlock.ExecutionBlockHash, "receipts are looked up by the payload's hash")
+	assert.Equal(t, 1, aBlock.NoOfTransactions)
+}
@@ -7,11 +7,15 @@ import (
 	"fmt"
 	"indexer/pkg/models"
 	"log"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	v1 "github.com/attestantio/go-eth2-client/api/v1"
 	"github.com/attestantio/go-eth2-client/http"
 	"github.com/attestantio/go-eth2-client/spec"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
 	"github.com/rs/zerolog"
 )
 
@@ -24,6 +28,14 @@ type EpochResult struct {
 	Error error
 }
 
+type ReorgResult struct {
+	Reorg *models.Reorg
+	Error error
+}
+
+// returned when the hash_tree_root of a fetched block does not match the root announced by the node
+var ErrBlockRootMismatch = errors.New("block root mismatch")
+
 // Creates new instance of Ethereum http client service
 func New(ctx context.Context, clientURL string) (*BeaconChain, error) {
 	client, err := http.New(ctx, http.WithAddress(clientURL), http.WithLogLevel(zerolog.ErrorLevel))
@@ -37,192 +49,289 @@ func New(ctx context.Context, clientURL string) (*BeaconChain, error) {
 	return &BeaconChain{httpClient}, nil
 }
 
+// streams the epochs built from block events until ctx is done, the stream is then closed
 func (b *BeaconChain) SubscribeToEpochs(ctx context.Context) <-chan EpochResult {
 
 	var (
-		slotPerEpoch     uint64
-		slotDuration     time.Duration
-		epochDuration    time.Duration
-		epochStream      chan EpochResult = make(chan EpochResult)
-		lastEpoch        uint64
-		slots            []models.Slot = make([]models.Slot, 0, slotPerEpoch)
-		err              error
-		closeEpochStream bool
+		slotPerEpoch uint64
+		slotDuration time.Duration
+		genesisTime  time.Time
+		epochStream  chan EpochResult = make(chan EpochResult)
+		err          error
+		// held by the events callback while it runs, so that the stream is never closed under it
+		mu     sync.Mutex
+		closed bool
 	)
-
-	defer func() {
-		if closeEpochStream {
-			close(epochStream)
+	// gives up on a result once ctx is done, as nobody may be reading anymore
+	send := func(result EpochResult) {
+		select {
+		case epochStream <- result:
+		case <-ctx.Done():
 		}
-	}()
+	}
 
 	go func() {
+		// the stream is closed once, here, by the goroutine owning it
+		defer func() {
+			mu.Lock()
+			defer mu.Unlock()
+			closed = true
+			close(epochStream)
+		}()
+
 		slotPerEpoch, err = b.httpClient.SlotsPerEpoch(ctx)
 		if err != nil {
-			closeEpochStream = true
-			epochStream <- EpochResult{
+			send(EpochResult{
 				Epoch: nil,
 				Error: fmt.Errorf("could not find SlotsPerEpoch, err: %v", err.Error()),
-			}
+			})
 			return
 		}
 
 		slotDuration, err = b.httpClient.SlotDuration(ctx)
 		if err != nil {
-			closeEpochStream = true
-			epochStream <- EpochResult{
+			send(EpochResult{
 				Epoch: nil,
 				Error: fmt.Errorf("could not find SlotDuration, err: %v", err.Error()),
-			}
+			})
 			return
 		}
 
-		epochDurStr := fmt.Sprintf("%ds", slotPerEpoch*uint64(slotDuration.Seconds()))
-		epochDuration, err = time.ParseDuration(epochDurStr)
+		genesisTime, err = b.httpClient.GenesisTime(ctx)
 		if err != nil {
-			closeEpochStream = true
-			epochStream <- EpochResult{
+			send(EpochResult{
 				Epoch: nil,
-				Error: fmt.Errorf("could not parse EpochDuration, err: %v", err.Error()),
-			}
+				Error: fmt.Errorf("could not find GenesisTime, err: %v", err.Error()),
+			})
+			return
 		}
 
+		builder := newEpochBuilder(genesisTime, slotPerEpoch, slotDuration)
+
 		// subscribe to block event
 		err = b.httpClient.Events(ctx, []string{"block"}, func(e *v1.Event) {
+			mu.Lock()
+			defer mu.Unlock()
 			// respect cancellation/unsubscription
-			select {
-			case <-ctx.Done():
-				close(epochStream)
-			default:
+			if closed || ctx.Err() != nil {
+				return
 			}
 			evtBytes, err := json.Marshal(e.Data)
 			if err != nil {
-				epochStream <- EpochResult{
+				send(EpochResult{
 					Epoch: nil,
 					Error: fmt.Errorf("json.Marshal(e.Data) failed, err %v", err.Error()),
-				}
+				})
 				return
 			}
 			var blockEvent *v1.BlockEvent = &v1.BlockEvent{}
 			err = blockEvent.UnmarshalJSON(evtBytes)
 			if err != nil {
-				epochStream <- EpochResult{
+				send(EpochResult{
 					Epoch: nil,
 					Error: fmt.Errorf("blockEvent.UnmarshalJSON(evtBytes) failed, err %v", err.Error()),
-				}
+				})
 				return
 			}
 
-			epoch := uint64(blockEvent.Slot) / 32
-			if lastEpoch == 0 {
-				lastEpoch = epoch
-			}
-
 			aSlot := models.Slot{
 				SlotNumber: uint64(blockEvent.Slot),
 			}
-			aBlock := models.Block{
-				BlockRoot: blockEvent.Block.String(),
-			}
 
 			// if a signed beacon block for the block ID is not available this will return nil without an error.
 			block, err := b.httpClient.SignedBeaconBlock(ctx, blockEvent.Block.String())
 			if err != nil {
-				epochStream <- EpochResult{
+				send(EpochResult{
 					Epoch: nil,
 					Error: fmt.Errorf("httpClient.SignedBeaconBlock() failed, err: %v", err.Error()),
-				}
+				})
 				return
 			}
 			if block == nil {
-				epochStream <- EpochResult{
+				send(EpochResult{
 					Epoch: nil,
 					Error: fmt.Errorf("no signed beacon block for the block ID (%s)", blockEvent.Block.String()),
-				}
+				})
 				return
 			}
 
-			switch block.Version {
-			case spec.DataVersionBellatrix:
-				aBlock.BlockNumber = block.Bellatrix.Message.Body.ExecutionPayload.BlockNumber
-				aBlock.GasLimit = block.Bellatrix.Message.Body.ExecutionPayload.GasLimit
-				aBlock.GasUsed = block.Bellatrix.Message.Body.ExecutionPayload.GasUsed
-				aBlock.NoOfTransactions = len(block.Bellatrix.Message.Body.ExecutionPayload.Transactions)
-				stateRoot, err := block.StateRoot()
-				if err != nil {
-					epochStream <- EpochResult{
-						Epoch: nil,
-						Error: fmt.Errorf("block.StateRoot() failed, err: %v", err.Error()),
-					}
-					return
-				}
-				aBlock.StateRoot = stateRoot.String()
-				aBlock.CreatedAt = time.Unix(int64(block.Bellatrix.Message.Body.ExecutionPayload.Timestamp), 0)
-			case spec.DataVersionCapella:
-				aBlock.BlockNumber = block.Capella.Message.Body.ExecutionPayload.BlockNumber
-				aBlock.GasLimit = block.Capella.Message.Body.ExecutionPayload.GasLimit
-				aBlock.GasUsed = block.Capella.Message.Body.ExecutionPayload.GasUsed
-				aBlock.NoOfTransactions = len(block.Capella.Message.Body.ExecutionPayload.Transactions)
-				stateRoot, err := block.StateRoot()
-				if err != nil {
-					epochStream <- EpochResult{
-						Epoch: nil,
-						Error: fmt.Errorf("block.StateRoot() failed, err: %v", err.Error()),
-					}
-					return
-				}
-				aBlock.StateRoot = stateRoot.String()
-				aBlock.CreatedAt = time.Unix(int64(block.Capella.Message.Body.ExecutionPayload.Timestamp), 0)
-			case spec.DataVersionDeneb:
-				aBlock.BlockNumber = block.Deneb.Message.Body.ExecutionPayload.BlockNumber
-				aBlock.GasLimit = block.Deneb.Message.Body.ExecutionPayload.GasLimit
-				aBlock.GasUsed = block.Deneb.Message.Body.ExecutionPayload.GasUsed
-				aBlock.NoOfTransactions = len(block.Deneb.Message.Body.ExecutionPayload.Transactions)
-				stateRoot, err := block.StateRoot()
-				if err != nil {
-					epochStream <- EpochResult{
-						Epoch: nil,
-						Error: fmt.Errorf("block.StateRoot() failed, err: %v", err.Error()),
-					}
-					return
-				}
-				aBlock.StateRoot = stateRoot.String()
-				aBlock.CreatedAt = time.Unix(int64(block.Deneb.Message.Body.ExecutionPayload.Timestamp), 0)
+			// never trust the provider, re-compute the root of the block & reject it on mismatch
+			err = verifyBlockRoot(block, blockEvent.Block)
+			if err != nil {
+				send(EpochResult{
+					Epoch: nil,
+					Error: fmt.Errorf("block (%s) rejected, err: %w", blockEvent.Block.String(), err),
+				})
+				return
 			}
 
-			aBlock.SlotNumber = aSlot.SlotNumber
-			aSlot.StartTime = aBlock.CreatedAt
-			aSlot.EndTime = aSlot.StartTime.Add(slotDuration)
+			aBlock, err := toBlock(block)
+			if err != nil {
+				send(EpochResult{
+					Epoch: nil,
+					Error: err,
+				})
+				return
+			}
+			aBlock.BlockRoot = blockEvent.Block.String()
 			aSlot.Block = aBlock
-			aSlot.EpochNumber = lastEpoch
-			slots = append(slots, aSlot)
 
-			if lastEpoch != epoch && len(slots) > 0 {
-				anEpoch := models.Epoch{
-					EpochNumber: lastEpoch,
-					Slots:       slots,
-				}
-				anEpoch.EpochNumber = lastEpoch
-				anEpoch.StartTime = aBlock.CreatedAt
-				anEpoch.StartTime = anEpoch.Slots[0].Block.CreatedAt
-				anEpoch.EndTime = anEpoch.StartTime.Add(epochDuration)
+			if anEpoch := builder.add(aSlot); anEpoch != nil {
 				log.Println("new epoch", anEpoch.EpochNumber)
-				slots = []models.Slot{}
-				lastEpoch = epoch
-				epochStream <- EpochResult{
-					Epoch: &anEpoch,
+				send(EpochResult{
+					Epoch: anEpoch,
 					Error: nil,
-				}
+				})
 			}
 		})
 		if err != nil {
-			closeEpochStream = true
-			epochStream <- EpochResult{
+			send(EpochResult{
 				Epoch: nil,
 				Error: fmt.Errorf("block subscription failed, err %v", err.Error()),
-			}
+			})
+			return
 		}
+		// events are handled in the background until ctx is done
+		<-ctx.Done()
 	}()
 
 	return epochStream
 }
+
+func (b *BeaconChain) SubscribeToReorgs(ctx context.Context) <-chan ReorgResult {
+	reorgStream := make(chan ReorgResult)
+
+	go func() {
+		err := b.httpClient.Events(ctx, []string{"chain_reorg"}, func(e *v1.Event) {
+			reorgEvent, ok := e.Data.(*v1.ChainReorgEvent)
+			if !ok {
+				reorgStream <- ReorgResult{
+					Reorg: nil,
+					Error: fmt.Errorf("unexpected chain_reorg event data %T", e.Data),
+				}
+				return
+			}
+			reorgStream <- ReorgResult{
+				Reorg: &models.Reorg{
+					Slot:         uint64(reorgEvent.Slot),
+					Depth:        reorgEvent.Depth,
+					EpochNumber:  uint64(reorgEvent.Epoch),
+					OldHeadBlock: reorgEvent.OldHeadBlock.String(),
+					NewHeadBlock: reorgEvent.NewHeadBlock.String(),
+				},
+				Error: nil,
+			}
+		})
+		if err != nil {
+			reorgStream <- ReorgResult{
+				Reorg: nil,
+				Error: fmt.Errorf("chain_reorg subscription failed, err %v", err.Error()),
+			}
+			close(reorgStream)
+		}
+	}()
+
+	return reorgStream
+}
+
+// returns the node's fork schedule, forks are named after the spec.DataVersion they introduce, as the fork version
+// constants of the node's spec tell
+func (b *BeaconChain) ForkSchedule(ctx context.Context) ([]models.Fork, error) {
+	schedule, err := b.httpClient.ForkSchedule(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("httpClient.ForkSchedule() failed, err: %v", err.Error())
+	}
+	chainSpec, err := b.httpClient.Spec(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("httpClient.Spec() failed, err: %v", err.Error())
+	}
+	return toForks(schedule, chainSpec), nil
+}
+
+// maps a fork schedule to models.Fork, the name of a fork is taken from the <NAME>_FORK_VERSION constant of the spec
+// matching its current version, GENESIS being phase0, forks of versions missing from the spec are named after it
+func toForks(schedule []*phase0.Fork, chainSpec map[string]interface{}) []models.Fork {
+	names := make(map[phase0.Version]string)
+	for key, value := range chainSpec {
+		version, ok := value.(phase0.Version)
+		if !ok || !strings.HasSuffix(key, "_FORK_VERSION") {
+			continue
+		}
+		name := strings.ToLower(strings.TrimSuffix(key, "_FORK_VERSION"))
+		if name == "genesis" {
+			name = spec.DataVersionPhase0.String()
+		}
+		names[version] = name
+	}
+	forks := make([]models.Fork, len(schedule))
+	for idx, fork := range schedule {
+		forks[idx] = models.Fork{
+			Name:            names[fork.CurrentVersion],
+			PreviousVersion: fmt.Sprintf("%#x", fork.PreviousVersion[:]),
+			CurrentVersion:  fmt.Sprintf("%#x", fork.CurrentVersion[:]),
+			Epoch:           uint64(fork.Epoch),
+		}
+		if forks[idx].Name == "" {
+			log.Printf("fork version %s of epoch %d is not in the spec\n", forks[idx].CurrentVersion, fork.Epoch)
+			forks[idx].Name = forks[idx].CurrentVersion
+		}
+	}
+	return forks
+}
+
+// fetches the canonical blocks of an epoch from the beacon node & assembles them as SubscribeToEpochs does, roots
+// are computed from the blocks rather than announced by the node, missed slots are left out
+func (b *BeaconChain) Epoch(ctx context.Context, number uint64) (*models.Epoch, error) {
+	slotsPerEpoch, err := b.httpClient.SlotsPerEpoch(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not find SlotsPerEpoch, err: %v", err.Error())
+	}
+	slotDuration, err := b.httpClient.SlotDuration(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not find SlotDuration, err: %v", err.Error())
+	}
+	genesisTime, err := b.httpClient.GenesisTime(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not find GenesisTime, err: %v", err.Error())
+	}
+
+	builder := newEpochBuilder(genesisTime, slotsPerEpoch, slotDuration)
+	for slot := number * slotsPerEpoch; slot < (number+1)*slotsPerEpoch; slot++ {
+		block, err := b.httpClient.SignedBeaconBlock(ctx, strconv.FormatUint(slot, 10))
+		if err != nil {
+			return nil, fmt.Errorf("httpClient.SignedBeaconBlock() failed, err: %v", err.Error())
+		}
+		// missed slot
+		if block == nil {
+			continue
+		}
+		root, err := block.Root()
+		if err != nil {
+			return nil, fmt.Errorf("block.Root() failed, err: %v", err.Error())
+		}
+		aBlock, err := toBlock(block)
+		if err != nil {
+			return nil, err
+		}
+		aBlock.BlockRoot = root.String()
+		builder.add(models.Slot{SlotNumber: slot, Block: aBlock})
+	}
+	anEpoch := builder.flush()
+	if anEpoch == nil {
+		// every slot was missed
+		anEpoch = builder.epoch(number, nil)
+	}
+	return anEpoch, nil
+}
+
+// computes hash_tree_root of the block message & ensures it matches the expected root
+func verifyBlockRoot(block *spec.VersionedSignedBeaconBlock, expected phase0.Root) error {
+	root, err := block.Root()
+	if err != nil {
+		return fmt.Errorf("block.Root() failed, err: %v", err.Error())
+	}
+	if root != expected {
+		return fmt.Errorf("%w, expected: %s, computed: %s", ErrBlockRootMismatch, expected.String(), root.String())
+	}
+	return nil
+}
@@ -0,0 +1,288 @@
+package indexer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"indexer/pkg/models"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	api "github.com/attestantio/go-eth2-client/api/v1"
+	"github.com/attestantio/go-eth2-client/spec"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_verifyBlockRoot(t *testing.T) {
+	block := &spec.VersionedSignedBeaconBlock{
+		Version: spec.DataVersionPhase0,
+		Phase0: &phase0.SignedBeaconBlock{
+			Message: &phase0.BeaconBlock{
+				Slot:          1,
+				ProposerIndex: 2,
+				Body: &phase0.BeaconBlockBody{
+					ETH1Data: &phase0.ETH1Data{
+						BlockHash: make([]byte, 32),
+					},
+				},
+			},
+		},
+	}
+	root, err := block.Phase0.Message.HashTreeRoot()
+	assert.Nil(t, err, "HashTreeRoot() must not fail")
+
+	tests := []struct {
+		name     string
+		block    *spec.VersionedSignedBeaconBlock
+		expected phase0.Root
+		wantErr  error
+	}{
+		{
+			name:     "should accept a block hashing to the announced root",
+			block:    block,
+			expected: root,
+			wantErr:  nil,
+		},
+		{
+			name:     "should reject a block not hashing to the announced root",
+			block:    block,
+			expected: phase0.Root{0x01},
+			wantErr:  ErrBlockRootMismatch,
+		},
+		{
+			name:     "should fail for an unknown block version",
+			block:    &spec.VersionedSignedBeaconBlock{Version: spec.DataVersion(99)},
+			expected: root,
+			wantErr:  errors.New("unknown version"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := verifyBlockRoot(tt.block, tt.expected)
+			switch {
+			case tt.wantErr == nil:
+				assert.Nil(t, err, "verifyBlockRoot() must not fail")
+			case errors.Is(tt.wantErr, ErrBlockRootMismatch):
+				assert.ErrorIs(t, err, ErrBlockRootMismatch)
+			default:
+				assert.ErrorContains(t, err, tt.wantErr.Error())
+			}
+		})
+	}
+}
+
+func Test_toForks(t *testing.T) {
+	chainSpec := map[string]interface{}{
+		"GENESIS_FORK_VERSION":   phase0.Version{0x90, 0x00, 0x00, 0x69},
+		"ALTAIR_FORK_VERSION":    phase0.Version{0x90, 0x00, 0x00, 0x70},
+		"BELLATRIX_FORK_VERSION": phase0.Version{0x90, 0x00, 0x00, 0x71},
+		"CAPELLA_FORK_VERSION":   phase0.Version{0x90, 0x00, 0x00, 0x72},
+		"BELLATRIX_FORK_EPOCH":   phase0.Epoch(100),
+		"SLOTS_PER_EPOCH":        uint64(32),
+	}
+	// the node leaves out the forks before bellatrix & lists one missing from the spec
+	schedule := []*phase0.Fork{
+		{PreviousVersion: phase0.Version{0x90, 0x00, 0x00, 0x70}, CurrentVersion: phase0.Version{0x90, 0x00, 0x00, 0x71}, Epoch: 100},
+		{PreviousVersion: phase0.Version{0x90, 0x00, 0x00, 0x71}, CurrentVersion: phase0.Version{0x90, 0x00, 0x00, 0x72}, Epoch: 56832},
+		{PreviousVersion: phase0.Version{0x90, 0x00, 0x00, 0x72}, CurrentVersion: phase0.Version{0x90, 0x00, 0x00, 0x73}, Epoch: 132608},
+	}
+	forks := toForks(schedule, chainSpec)
+	var names []string
+	for _, fork := range forks {
+		names = append(names, fork.Name)
+	}
+	assert.Equal(t, []string{"bellatrix", "capella", "0x90000073"}, names)
+	assert.Equal(t, "0x90000070", forks[0].PreviousVersion)
+	assert.Equal(t, uint64(56832), forks[1].Epoch)
+
+	genesis := toForks([]*phase0.Fork{{CurrentVersion: phase0.Version{0x90, 0x00, 0x00, 0x69}}}, chainSpec)
+	assert.Equal(t, spec.DataVersionPhase0.String(), genesis[0].Name)
+}
+
+// serves the phase0 blocks of slots, by slot or root, as a beacon node would, with 4 slots per epoch, other slots
+// are missed. Block events of the announced slots are streamed, then of slot 1000 until the subscriber leaves
+func beaconNode(t *testing.T, genesis time.Time, blocks map[uint64]*phase0.SignedBeaconBlock, announced []uint64) *httptest.Server {
+	respond := func(w http.ResponseWriter, v interface{}) {
+		err := json.NewEncoder(w).Encode(v)
+		if err != nil {
+			t.Errorf("response encoding failed, err: %v", err.Error())
+		}
+	}
+	slotOf := make(map[string]uint64)
+	rootOf := func(slot uint64) phase0.Root {
+		block, ok := blocks[slot]
+		if !ok {
+			return phase0.Root{0xff}
+		}
+		root, err := block.Message.HashTreeRoot()
+		if err != nil {
+			t.Fatalf("HashTreeRoot() failed, err: %v", err.Error())
+		}
+		return root
+	}
+	for slot := range blocks {
+		slotOf[rootOf(slot).String()] = slot
+	}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch path := r.URL.Path; {
+		case path == "/eth/v1/beacon/genesis":
+			respond(w, map[string]interface{}{"data": &api.Genesis{GenesisTime: genesis}})
+		case path == "/eth/v1/config/spec":
+			respond(w, map[string]interface{}{"data": map[string]string{
+				"SLOTS_PER_EPOCH": "4", "SECONDS_PER_SLOT": "12", "GENESIS_FORK_VERSION": "0x00000000",
+			}})
+		case path == "/eth/v1/config/deposit_contract":
+			respond(w, map[string]interface{}{"data": &api.DepositContract{ChainID: 1, Address: make([]byte, 20)}})
+		case path == "/eth/v1/config/fork_schedule":
+			respond(w, map[string]interface{}{"data": []*phase0.Fork{{}}})
+		case path == "/eth/v1/node/version":
+			respond(w, map[string]interface{}{"data": map[string]string{"version": "fake/v1"}})
+		case path == "/eth/v1/events":
+			w.Header().Set("Content-Type", "text/event-stream")
+			announce := func(slot uint64) {
+				fmt.Fprintf(w, "event: block\ndata: {\"slot\":\"%d\",\"block\":\"%s\",\"execution_optimistic\":false}\n\n", slot, rootOf(slot))
+				w.(http.Flusher).Flush()
+			}
+			for _, slot := range announced {
+				announce(slot)
+			}
+			for {
+				select {
+				case <-r.Context().Done():
+					return
+				case <-time.After(10 * time.Millisecond):
+					announce(1000)
+				}
+			}
+		case strings.HasPrefix(path, "/eth/v2/beacon/blocks/"):
+			id := strings.TrimPrefix(path, "/eth/v2/beacon/blocks/")
+			slot, ok := slotOf[id]
+			if !ok {
+				fmt.Sscan(id, &slot)
+			}
+			block, ok := blocks[slot]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			respond(w, map[string]interface{}{"version": "phase0", "data": block})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+// phase0 blocks of slots, each on top of the previous one, along with their roots
+func chainOf(t *testing.T, slots ...uint64) (map[uint64]*phase0.SignedBeaconBlock, map[uint64]string) {
+	blocks := make(map[uint64]*phase0.SignedBeaconBlock)
+	roots := make(map[uint64]string)
+	var parent phase0.Root
+	for _, slot := range slots {
+		block := &phase0.SignedBeaconBlock{Message: &phase0.BeaconBlock{
+			Slot:       phase0.Slot(slot),
+			ParentRoot: parent,
+			Body: &phase0.BeaconBlockBody{
+				ETH1Data:          &phase0.ETH1Data{BlockHash: make([]byte, 32)},
+				ProposerSlashings: []*phase0.ProposerSlashing{},
+				AttesterSlashings: []*phase0.AttesterSlashing{},
+				Attestations:      []*phase0.Attestation{},
+				Deposits:          []*phase0.Deposit{},
+				VoluntaryExits:    []*phase0.SignedVoluntaryExit{},
+			},
+		}}
+		root, err := block.Message.HashTreeRoot()
+		if err != nil {
+			t.Fatalf("HashTreeRoot() failed, err: %v", err.Error())
+		}
+		blocks[slot], roots[slot], parent = block, phase0.Root(root).String(), root
+	}
+	return blocks, roots
+}
+
+func TestBeaconChain_Epoch(t *testing.T) {
+	genesis := time.Unix(1606824023, 0).UTC()
+	// slots 4, 6 & 7 of epoch 1 have a block, each on top of the previous one
+	blocks, roots := chainOf(t, 4, 6, 7)
+	server := beaconNode(t, genesis, blocks, nil)
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	chain, err := New(ctx, server.URL)
+	if err != nil {
+		t.Fatalf("New() failed, err: %v", err.Error())
+	}
+
+	epoch, err := chain.Epoch(ctx, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, genesis.Add(48*time.Second), epoch.StartTime.UTC())
+	assert.Equal(t, genesis.Add(96*time.Second), epoch.EndTime.UTC())
+	var slots []uint64
+	for _, slot := range epoch.Slots {
+		slots = append(slots, slot.SlotNumber)
+		assert.Equal(t, roots[slot.SlotNumber], slot.Block.BlockRoot, "roots must be computed from the blocks")
+		assert.True(t, slot.Block.Canonical)
+		assert.Equal(t, uint64(1), slot.EpochNumber)
+	}
+	assert.Equal(t, []uint64{4, 6, 7}, slots, "missed slots must be left out")
+	assert.Equal(t, roots[6], epoch.Slots[2].Block.ParentRoot)
+	if assert.NotNil(t, epoch.Stats) {
+		assert.Equal(t, 3, epoch.Stats.ProposedSlots)
+		assert.Equal(t, 1, epoch.Stats.MissedSlots)
+	}
+
+	// every slot missed
+	epoch, err = chain.Epoch(ctx, 2)
+	assert.NoError(t, err)
+	assert.Empty(t, epoch.Slots)
+	assert.Equal(t, genesis.Add(96*time.Second), epoch.StartTime.UTC())
+	assert.Equal(t, &models.EpochStats{EpochNumber: 2, MissedSlots: 4}, epoch.Stats)
+}
+
+func TestBeaconChain_SubscribeToEpochs_cancel(t *testing.T) {
+	genesis := time.Unix(1606824023, 0).UTC()
+	// epoch 1 is complete once the block of slot 8 arrives, events keep coming after it
+	blocks, roots := chainOf(t, 4, 5, 6, 7, 8)
+	server := beaconNode(t, genesis, blocks, []uint64{4, 5, 6, 7, 8})
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	chain, err := New(ctx, server.URL)
+	if err != nil {
+		t.Fatalf("New() failed, err: %v", err.Error())
+	}
+	stream := chain.SubscribeToEpochs(ctx)
+	var epoch *models.Epoch
+	for result := range stream {
+		if result.Epoch != nil {
+			epoch = result.Epoch
+			break
+		}
+	}
+	if assert.NotNil(t, epoch) {
+		assert.Equal(t, uint64(1), epoch.EpochNumber)
+		assert.Equal(t, roots[7], epoch.Slots[3].Block.BlockRoot)
+	}
+
+	// cancelled mid-stream, the stream must be closed once without any further event being sent on it
+	cancel()
+	closed := make(chan struct{})
+	go func() {
+		for range stream {
+		}
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the stream was not closed after the cancellation")
+	}
+	// events still arriving must not be sent on the closed stream
+	time.Sleep(50 * time.Millisecond)
+}
@@ -0,0 +1,294 @@
+package indexer
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"indexer/pkg/models"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	v1 "github.com/attestantio/go-eth2-client/api/v1"
+	"github.com/attestantio/go-eth2-client/spec"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/prysmaticlabs/go-bitfield"
+)
+
+// returned by ValidatorTracker.Index for pubkeys the beacon node does not know, e.g. not deposited yet
+var ErrUnknownValidator = errors.New("unknown validator")
+
+// ValidatorTracker records the duties of watched validators & their outcome, epoch by epoch
+type ValidatorTracker struct {
+	chain *BeaconChain
+	// guards indices, which the API resolves pubkeys through too
+	mu      sync.Mutex
+	indices map[string]phase0.ValidatorIndex
+}
+
+// the parts of a block needed to evaluate validator duties
+type dutyBlock struct {
+	proposer     phase0.ValidatorIndex
+	attestations []*phase0.Attestation
+	syncBits     bitfield.Bitvector512
+}
+
+// everything needed to evaluate the duties of watched validators during an epoch
+type dutyInputs struct {
+	epoch            uint64
+	slotsPerEpoch    uint64
+	proposerDuties   []*v1.ProposerDuty
+	attesterDuties   []*v1.AttesterDuty
+	syncDuties       []*v1.SyncCommitteeDuty
+	blocks           map[phase0.Slot]dutyBlock
+	balances         map[phase0.ValidatorIndex]phase0.Gwei
+	previousBalances map[phase0.ValidatorIndex]phase0.Gwei
+}
+
+// Creates new tracker on top of the beacon node used by the indexer
+func NewValidatorTracker(chain *BeaconChain) *ValidatorTracker {
+	return &ValidatorTracker{
+		chain:   chain,
+		indices: make(map[string]phase0.ValidatorIndex),
+	}
+}
+
+// evaluates the duties of the watched validators (indices or 0x prefixed pubkeys) during the given epoch,
+// attestations may be included up to an epoch later, so the next epoch must be complete before tracking
+func (t *ValidatorTracker) Track(ctx context.Context, epoch uint64, watchlist []string) ([]models.ValidatorActivity, error) {
+	indices, err := t.resolve(ctx, watchlist)
+	if err != nil {
+		return nil, err
+	}
+	if len(indices) == 0 {
+		return nil, nil
+	}
+
+	in := dutyInputs{epoch: epoch, blocks: make(map[phase0.Slot]dutyBlock)}
+	in.slotsPerEpoch, err = t.chain.httpClient.SlotsPerEpoch(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("httpClient.SlotsPerEpoch() failed, err: %v", err.Error())
+	}
+	in.proposerDuties, err = t.chain.httpClient.ProposerDuties(ctx, phase0.Epoch(epoch), indices)
+	if err != nil {
+		return nil, fmt.Errorf("httpClient.ProposerDuties() failed, err: %v", err.Error())
+	}
+	in.attesterDuties, err = t.chain.httpClient.AttesterDuties(ctx, phase0.Epoch(epoch), indices)
+	if err != nil {
+		return nil, fmt.Errorf("httpClient.AttesterDuties() failed, err: %v", err.Error())
+	}
+	in.syncDuties, err = t.chain.httpClient.SyncCommitteeDuties(ctx, phase0.Epoch(epoch), indices)
+	if err != nil {
+		return nil, fmt.Errorf("httpClient.SyncCommitteeDuties() failed, err: %v", err.Error())
+	}
+
+	// blocks of this epoch & the next, where attestations of this epoch may still be included
+	firstSlot := epoch * in.slotsPerEpoch
+	for slot := firstSlot; slot < firstSlot+2*in.slotsPerEpoch; slot++ {
+		block, err := t.chain.httpClient.SignedBeaconBlock(ctx, strconv.FormatUint(slot, 10))
+		if err != nil {
+			return nil, fmt.Errorf("httpClient.SignedBeaconBlock(%d) failed, err: %v", slot, err.Error())
+		}
+		if block == nil {
+			continue
+		}
+		in.blocks[phase0.Slot(slot)], err = toDutyBlock(block)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	in.balances, err = t.chain.httpClient.ValidatorBalances(ctx, strconv.FormatUint(firstSlot+in.slotsPerEpoch-1, 10), indices)
+	if err != nil {
+		return nil, fmt.Errorf("httpClient.ValidatorBalances() failed, err: %v", err.Error())
+	}
+	if firstSlot > 0 {
+		in.previousBalances, err = t.chain.httpClient.ValidatorBalances(ctx, strconv.FormatUint(firstSlot-1, 10), indices)
+		if err != nil {
+			return nil, fmt.Errorf("httpClient.ValidatorBalances() failed, err: %v", err.Error())
+		}
+	}
+
+	return summarizeDuties(in, indices), nil
+}
+
+// resolves a validator, an index or a 0x prefixed pubkey, to its index the way watchlist entries are resolved
+func (t *ValidatorTracker) Index(ctx context.Context, id string) (uint64, error) {
+	indices, err := t.resolve(ctx, []string{id})
+	if err != nil {
+		return 0, err
+	}
+	if len(indices) == 0 {
+		return 0, fmt.Errorf("%w: %s", ErrUnknownValidator, id)
+	}
+	return uint64(indices[0]), nil
+}
+
+// maps watchlist entries to validator indices, pubkeys are looked up once & cached
+func (t *ValidatorTracker) resolve(ctx context.Context, watchlist []string) ([]phase0.ValidatorIndex, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var pubkeys []phase0.BLSPubKey
+	normalized := make([]string, 0, len(watchlist))
+	for _, id := range watchlist {
+		id, err := ParseValidator(id)
+		if err != nil {
+			return nil, err
+		}
+		normalized = append(normalized, id)
+		if _, ok := t.indices[id]; ok {
+			continue
+		}
+		if index, err := strconv.ParseUint(id, 10, 64); err == nil {
+			t.indices[id] = phase0.ValidatorIndex(index)
+			continue
+		}
+		var pubkey phase0.BLSPubKey
+		b, _ := hex.DecodeString(id[2:])
+		copy(pubkey[:], b)
+		pubkeys = append(pubkeys, pubkey)
+	}
+	if len(pubkeys) > 0 {
+		validators, err := t.chain.httpClient.ValidatorsByPubKey(ctx, "head", pubkeys)
+		if err != nil {
+			return nil, fmt.Errorf("httpClient.ValidatorsByPubKey() failed, err: %v", err.Error())
+		}
+		for index, validator := range validators {
+			t.indices[validator.Validator.PublicKey.String()] = index
+		}
+	}
+
+	seen := make(map[phase0.ValidatorIndex]bool, len(normalized))
+	indices := make([]phase0.ValidatorIndex, 0, len(normalized))
+	for _, id := range normalized {
+		// unknown pubkeys are not yet deposited, they'll be looked up again next time
+		index, ok := t.indices[id]
+		if !ok || seen[index] {
+			continue
+		}
+		seen[index] = true
+		indices = append(indices, index)
+	}
+	sort.Slice(indices, func(i, j int) bool { return indices[i] < indices[j] })
+	return indices, nil
+}
+
+// validates a watchlist entry, returns the validator index or the lower cased 0x prefixed pubkey
+func ParseValidator(id string) (string, error) {
+	id = strings.ToLower(strings.TrimSpace(id))
+	if _, err := strconv.ParseUint(id, 10, 64); err == nil {
+		return id, nil
+	}
+	b, err := hex.DecodeString(strings.TrimPrefix(id, "0x"))
+	if err != nil || !strings.HasPrefix(id, "0x") || len(b) != len(phase0.BLSPubKey{}) {
+		return "", fmt.Errorf("invalid validator %q, expected an index or a 0x prefixed pubkey", id)
+	}
+	return id, nil
+}
+
+func toDutyBlock(block *spec.VersionedSignedBeaconBlock) (dutyBlock, error) {
+	attestations, err := block.Attestations()
+	if err != nil {
+		return dutyBlock{}, fmt.Errorf("block.Attestations() failed, err: %v", err.Error())
+	}
+	db := dutyBlock{attestations: attestations}
+	switch block.Version {
+	case spec.DataVersionPhase0:
+		db.proposer = block.Phase0.Message.ProposerIndex
+	case spec.DataVersionAltair:
+		db.proposer = block.Altair.Message.ProposerIndex
+		db.syncBits = block.Altair.Message.Body.SyncAggregate.SyncCommitteeBits
+	case spec.DataVersionBellatrix:
+		db.proposer = block.Bellatrix.Message.ProposerIndex
+		db.syncBits = block.Bellatrix.Message.Body.SyncAggregate.SyncCommitteeBits
+	case spec.DataVersionCapella:
+		db.proposer = block.Capella.Message.ProposerIndex
+		db.syncBits = block.Capella.Message.Body.SyncAggregate.SyncCommitteeBits
+	case spec.DataVersionDeneb:
+		db.proposer = block.Deneb.Message.ProposerIndex
+		db.syncBits = block.Deneb.Message.Body.SyncAggregate.SyncCommitteeBits
+	}
+	return db, nil
+}
+
+// evaluates duties against the blocks that made it on chain
+func summarizeDuties(in dutyInputs, indices []phase0.ValidatorIndex) []models.ValidatorActivity {
+	activity := make(map[phase0.ValidatorIndex]*models.ValidatorActivity, len(indices))
+	result := make([]models.ValidatorActivity, len(indices))
+	for idx, index := range indices {
+		result[idx] = models.ValidatorActivity{ValidatorIndex: uint64(index), EpochNumber: in.epoch}
+		activity[index] = &result[idx]
+	}
+
+	for _, duty := range in.proposerDuties {
+		a, ok := activity[duty.ValidatorIndex]
+		if !ok {
+			continue
+		}
+		a.ProposerDuties++
+		if block, ok := in.blocks[duty.Slot]; ok && block.proposer == duty.ValidatorIndex {
+			a.Proposed++
+		}
+	}
+
+	lastSlot := phase0.Slot((in.epoch + 2) * in.slotsPerEpoch)
+	for _, duty := range in.attesterDuties {
+		a, ok := activity[duty.ValidatorIndex]
+		if !ok {
+			continue
+		}
+		a.AttesterDuties++
+		// the earliest block including the attestation determines the inclusion delay
+	inclusion:
+		for slot := duty.Slot + 1; slot < lastSlot; slot++ {
+			block, ok := in.blocks[slot]
+			if !ok {
+				continue
+			}
+			for _, attestation := range block.attestations {
+				if attestation.Data == nil || attestation.Data.Slot != duty.Slot || attestation.Data.Index != duty.CommitteeIndex {
+					continue
+				}
+				if duty.ValidatorCommitteeIndex < attestation.AggregationBits.Len() && attestation.AggregationBits.BitAt(duty.ValidatorCommitteeIndex) {
+					a.AttestationsIncluded++
+					a.InclusionDelay = int(slot - duty.Slot)
+					break inclusion
+				}
+			}
+		}
+	}
+
+	// the sync aggregate of a block is signed during the slot before it, so the duty of a slot is judged by the block
+	// of the next one, the last slot of the epoch by the first block of the next epoch. A duty followed by a missed
+	// slot is not judged, as no other block may include its signature
+	firstSlot := phase0.Slot(in.epoch * in.slotsPerEpoch)
+	for _, duty := range in.syncDuties {
+		a, ok := activity[duty.ValidatorIndex]
+		if !ok {
+			continue
+		}
+		for slot := firstSlot; slot < firstSlot+phase0.Slot(in.slotsPerEpoch); slot++ {
+			block, ok := in.blocks[slot+1]
+			if !ok || block.syncBits == nil {
+				continue
+			}
+			a.SyncDuties++
+			for _, committeeIndex := range duty.ValidatorSyncCommitteeIndices {
+				if block.syncBits.BitAt(uint64(committeeIndex)) {
+					a.SyncParticipated++
+					break
+				}
+			}
+		}
+	}
+
+	for index, a := range activity {
+		a.Balance = uint64(in.balances[index])
+		if previous, ok := in.previousBalances[index]; ok {
+			a.Reward = int64(in.balances[index]) - int64(previous)
+		}
+	}
+	return result
+}
@@ -0,0 +1,120 @@
+package indexer
+
+import (
+	"indexer/pkg/models"
+	"strings"
+	"testing"
+
+	v1 "github.com/attestantio/go-eth2-client/api/v1"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/prysmaticlabs/go-bitfield"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_summarizeDuties(t *testing.T) {
+	aggregationBits := bitfield.NewBitlist(4)
+	aggregationBits.SetBitAt(1, true)
+	syncBits := bitfield.NewBitvector512()
+	syncBits.SetBitAt(7, true)
+
+	in := dutyInputs{
+		epoch:         1,
+		slotsPerEpoch: 4,
+		proposerDuties: []*v1.ProposerDuty{
+			{Slot: 4, ValidatorIndex: 10},
+			{Slot: 5, ValidatorIndex: 10},
+			{Slot: 6, ValidatorIndex: 99},
+		},
+		attesterDuties: []*v1.AttesterDuty{
+			{Slot: 5, ValidatorIndex: 10, CommitteeIndex: 2, ValidatorCommitteeIndex: 1},
+			{Slot: 5, ValidatorIndex: 20, CommitteeIndex: 2, ValidatorCommitteeIndex: 3},
+		},
+		syncDuties: []*v1.SyncCommitteeDuty{
+			{ValidatorIndex: 20, ValidatorSyncCommitteeIndices: []phase0.CommitteeIndex{7}},
+		},
+		blocks: map[phase0.Slot]dutyBlock{
+			4: {proposer: 10, syncBits: syncBits},
+			// slot 5 was missed
+			6: {proposer: 99, syncBits: bitfield.NewBitvector512()},
+			8: {proposer: 1, syncBits: syncBits, attestations: []*phase0.Attestation{
+				{AggregationBits: aggregationBits, Data: &phase0.AttestationData{Slot: 5, Index: 2}},
+			}},
+		},
+		balances:         map[phase0.ValidatorIndex]phase0.Gwei{10: 32_000_010_000, 20: 31_999_990_000},
+		previousBalances: map[phase0.ValidatorIndex]phase0.Gwei{10: 32_000_000_000, 20: 32_000_000_000},
+	}
+
+	got := summarizeDuties(in, []phase0.ValidatorIndex{10, 20})
+	assert.Equal(t, []models.ValidatorActivity{
+		{
+			ValidatorIndex:       10,
+			EpochNumber:          1,
+			ProposerDuties:       2,
+			Proposed:             1,
+			AttesterDuties:       1,
+			AttestationsIncluded: 1,
+			InclusionDelay:       3,
+			Balance:              32_000_010_000,
+			Reward:               10_000,
+		},
+		{
+			ValidatorIndex:   20,
+			EpochNumber:      1,
+			AttesterDuties:   1,
+			SyncDuties:       2,
+			SyncParticipated: 1,
+			Balance:          31_999_990_000,
+			Reward:           -10_000,
+		},
+	}, got)
+}
+
+func Test_summarizeDuties_sync(t *testing.T) {
+	participated := bitfield.NewBitvector512()
+	participated.SetBitAt(3, true)
+	in := dutyInputs{
+		epoch:         1,
+		slotsPerEpoch: 4,
+		syncDuties:    []*v1.SyncCommitteeDuty{{ValidatorIndex: 20, ValidatorSyncCommitteeIndices: []phase0.CommitteeIndex{3}}},
+		blocks: map[phase0.Slot]dutyBlock{
+			// signed during slot 3, of the previous epoch
+			4: {syncBits: bitfield.NewBitvector512()},
+			// slot 5 was missed, the signature of slot 4 could not be included
+			6: {syncBits: participated},
+			7: {syncBits: bitfield.NewBitvector512()},
+			// signed during slot 7, the last one of the epoch
+			8: {syncBits: participated},
+		},
+	}
+
+	got := summarizeDuties(in, []phase0.ValidatorIndex{20})
+	assert.Equal(t, []models.ValidatorActivity{
+		{ValidatorIndex: 20, EpochNumber: 1, SyncDuties: 3, SyncParticipated: 2},
+	}, got)
+}
+
+func TestParseValidator(t *testing.T) {
+	pubkey := "0x" + strings.Repeat("aB", 48)
+	tests := []struct {
+		name    string
+		id      string
+		want    string
+		wantErr bool
+	}{
+		{name: "should accept an index", id: " 42 ", want: "42"},
+		{name: "should accept & lower case a pubkey", id: pubkey, want: strings.ToLower(pubkey)},
+		{name: "should reject a pubkey without 0x prefix", id: pubkey[2:], wantErr: true},
+		{name: "should reject a short pubkey", id: "0xabcd", wantErr: true},
+		{name: "should reject garbage", id: "validator", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseValidator(tt.id)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseValidator() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
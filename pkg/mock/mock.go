@@ -17,14 +17,82 @@ func New() *Store {
 	return &Store{}
 }
 
-func (s *Store) Create(ctx context.Context, e models.Epoch) error {
-	return nil
+func (s *Store) Create(ctx context.Context, e models.Epoch, policy models.ConflictPolicy) (models.WriteResult, error) {
+	return models.WriteResult{}, nil
 }
 
 func (s *Store) Get(ctx context.Context) ([]models.Epoch, error) {
 	return []models.Epoch{}, nil
 }
 
+func (s *Store) GetEpoch(ctx context.Context, number uint64) (models.Epoch, error) {
+	return models.Epoch{}, store.ErrNotFound
+}
+
+func (s *Store) GetEpochs(ctx context.Context, query models.RangeQuery) (models.EpochPage, error) {
+	return models.EpochPage{Epochs: []models.Epoch{}}, nil
+}
+
+func (s *Store) GetSlot(ctx context.Context, number uint64) (models.Slot, error) {
+	return models.Slot{}, store.ErrNotFound
+}
+
+func (s *Store) GetSlots(ctx context.Context, query models.RangeQuery) (models.SlotPage, error) {
+	return models.SlotPage{Slots: []models.Slot{}}, nil
+}
+
+func (s *Store) GetBlock(ctx context.Context, root string) (models.Block, error) {
+	return models.Block{}, store.ErrNotFound
+}
+
+func (s *Store) GetBlockByNumber(ctx context.Context, number uint64) (models.Block, error) {
+	return models.Block{}, store.ErrNotFound
+}
+
+func (s *Store) GetEpochStats(ctx context.Context, limit uint64) ([]models.EpochStats, error) {
+	return []models.EpochStats{}, nil
+}
+
+func (s *Store) GetEpochStatsBetween(ctx context.Context, fromEpoch uint64, toEpoch uint64) ([]models.EpochStats, error) {
+	return []models.EpochStats{}, nil
+}
+
+func (s *Store) GetContractEvents(ctx context.Context, filter models.EventFilter) ([]models.ContractEvent, error) {
+	return []models.ContractEvent{}, nil
+}
+
+func (s *Store) GetBuilderShare(ctx context.Context, fromEpoch uint64, toEpoch uint64) ([]models.BuilderShare, error) {
+	return []models.BuilderShare{}, nil
+}
+
+func (s *Store) SaveForkSchedule(ctx context.Context, forks []models.Fork) error {
+	return nil
+}
+
+func (s *Store) GetForkSchedule(ctx context.Context) ([]models.Fork, error) {
+	return []models.Fork{}, nil
+}
+
+func (s *Store) WatchValidator(ctx context.Context, validator string) error {
+	return nil
+}
+
+func (s *Store) UnwatchValidator(ctx context.Context, validator string) error {
+	return nil
+}
+
+func (s *Store) GetWatchedValidators(ctx context.Context) ([]string, error) {
+	return []string{}, nil
+}
+
+func (s *Store) CreateValidatorActivity(ctx context.Context, activity []models.ValidatorActivity) error {
+	return nil
+}
+
+func (s *Store) GetValidatorHistory(ctx context.Context, validatorIndex uint64, limit uint64) ([]models.ValidatorActivity, error) {
+	return []models.ValidatorActivity{}, nil
+}
+
 func (s *Store) KeepOnlyTop5(ctx context.Context, epochNumber uint64) error {
 	return nil
 }
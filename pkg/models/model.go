@@ -1,7 +1,10 @@
 package models
 
 import (
+	"encoding/hex"
 	"encoding/json"
+	"fmt"
+	"strings"
 	"time"
 )
 
@@ -11,37 +14,184 @@ func (i Block) String() string {
 	return string(b)
 }
 
+// fmt.Stringer implementation of a Receipt
+func (i Receipt) String() string {
+	b, _ := json.Marshal(i)
+	return string(b)
+}
+
+// fmt.Stringer implementation of a ContractEvent
+func (i ContractEvent) String() string {
+	b, _ := json.Marshal(i)
+	return string(b)
+}
+
+// fmt.Stringer implementation of a ValidatorActivity
+func (i ValidatorActivity) String() string {
+	b, _ := json.Marshal(i)
+	return string(b)
+}
+
 // fmt.Stringer implementation of a Slot
 func (i Slot) String() string {
 	b, _ := json.Marshal(i)
 	return string(b)
 }
 
+// fmt.Stringer implementation of an EpochStats
+func (i EpochStats) String() string {
+	b, _ := json.Marshal(i)
+	return string(b)
+}
+
 // fmt.Stringer implementation of a Epoch
 func (i Epoch) String() string {
 	b, _ := json.Marshal(i)
 	return string(b)
 }
 
-// represents a block
+// decodes a 0x prefixed hex string, e.g. a root or a transaction hash, into the bytes it is stored as, an empty
+// string decodes to no bytes
+func DecodeHex(s string) ([]byte, error) {
+	if s == "" {
+		return []byte{}, nil
+	}
+	if !strings.HasPrefix(s, "0x") {
+		return nil, fmt.Errorf("%q is not 0x prefixed", s)
+	}
+	b, err := hex.DecodeString(s[2:])
+	if err != nil {
+		return nil, fmt.Errorf("%q is not hex, err: %v", s, err.Error())
+	}
+	return b, nil
+}
+
+// sets the times of the block to UTC, as they are stored
+func (i *Block) UTC() {
+	i.CreatedAt = i.CreatedAt.UTC()
+}
+
+// sets the times of the slot & its blocks to UTC, as they are stored
+func (i *Slot) UTC() {
+	i.StartTime, i.EndTime = i.StartTime.UTC(), i.EndTime.UTC()
+	i.Block.UTC()
+	for idx := range i.Candidates {
+		i.Candidates[idx].UTC()
+	}
+}
+
+// sets the times of the epoch, its slots & their blocks to UTC, as they are stored
+func (i *Epoch) UTC() {
+	i.StartTime, i.EndTime = i.StartTime.UTC(), i.EndTime.UTC()
+	for idx := range i.Slots {
+		i.Slots[idx].UTC()
+	}
+}
+
+// represents a block, CreatedAt is the execution payload timestamp (zero before the merge) & Builder is the label
+// of the external builder, "local" for locally built blocks & empty when not classified
 type Block struct {
-	BlockNumber      uint64    `json:"blockNumber" db:"block_number"`
-	BlockRoot        string    `json:"blockRoot" db:"block_root"`
-	StateRoot        string    `json:"stateRoot" db:"state_root"`
-	SlotNumber       uint64    `json:"slotNumber" db:"slot_number"`
-	GasLimit         uint64    `json:"gasLimit" db:"gas_limit"`
-	GasUsed          uint64    `json:"gasUsed" db:"gas_used"`
-	NoOfTransactions int       `json:"noOfTransactions" db:"no_of_transactions"`
-	CreatedAt        time.Time `json:"created_at" db:"created_at"`
+	BlockNumber      uint64          `json:"blockNumber" db:"block_number"`
+	BlockRoot        string          `json:"blockRoot" db:"block_root"`
+	ParentRoot       string          `json:"parentRoot" db:"parent_root"`
+	Canonical        bool            `json:"canonical" db:"canonical"`
+	StateRoot        string          `json:"stateRoot" db:"state_root"`
+	SlotNumber       uint64          `json:"slotNumber" db:"slot_number"`
+	GasLimit         uint64          `json:"gasLimit" db:"gas_limit"`
+	GasUsed          uint64          `json:"gasUsed" db:"gas_used"`
+	NoOfTransactions int             `json:"noOfTransactions" db:"no_of_transactions"`
+	CreatedAt        time.Time       `json:"created_at" db:"created_at"`
+	Version          string          `json:"version" db:"version"`
+	FeeRecipient     string          `json:"feeRecipient" db:"fee_recipient"`
+	ExtraData        string          `json:"extraData" db:"extra_data"`
+	Builder          string          `json:"builder" db:"builder"`
+	MEVBoost         bool            `json:"mevBoost" db:"mev_boost"`
+	Revision         int             `json:"revision,omitempty" db:"revision"`
+	Receipts         []Receipt       `json:"receipts,omitempty" db:"-"`
+	Events           []ContractEvent `json:"events,omitempty" db:"-"`
+	// hash of the execution payload, receipts & transactions are looked up by it rather than by the block number,
+	// which a reorg of the execution layer may point at another block, neither stored nor served
+	ExecutionBlockHash string `json:"-" db:"-"`
+}
+
+// represents the execution receipt of a transaction, only present when an execution client is configured
+type Receipt struct {
+	TransactionHash   string `json:"transactionHash" db:"transaction_hash"`
+	TransactionIndex  uint64 `json:"transactionIndex" db:"transaction_index"`
+	BlockNumber       uint64 `json:"blockNumber" db:"block_number"`
+	BlockRoot         string `json:"blockRoot" db:"block_root"`
+	Status            uint64 `json:"status" db:"status"`
+	GasUsed           uint64 `json:"gasUsed" db:"gas_used"`
+	EffectiveGasPrice uint64 `json:"effectiveGasPrice" db:"effective_gas_price"`
+	LogCount          int    `json:"logCount" db:"log_count"`
+	Logs              []Log  `json:"-" db:"-"`
 }
 
-// represents a slot
+// represents a log emitted by a transaction, kept in memory only to decode contract events
+type Log struct {
+	Address  string   `json:"address"`
+	Topics   []string `json:"topics"`
+	Data     string   `json:"data"`
+	LogIndex uint64   `json:"logIndex"`
+}
+
+// represents a decoded log of a watched contract
+type ContractEvent struct {
+	BlockNumber     uint64                 `json:"blockNumber" db:"block_number"`
+	BlockRoot       string                 `json:"blockRoot" db:"block_root"`
+	TransactionHash string                 `json:"transactionHash" db:"transaction_hash"`
+	LogIndex        uint64                 `json:"logIndex" db:"log_index"`
+	Address         string                 `json:"address" db:"address"`
+	Contract        string                 `json:"contract" db:"contract"`
+	Event           string                 `json:"event" db:"event"`
+	Signature       string                 `json:"signature" db:"signature"`
+	Args            map[string]interface{} `json:"args" db:"args"`
+}
+
+// narrows down contract events, zero values match everything
+type EventFilter struct {
+	Contract  string
+	Address   string
+	Event     string
+	FromBlock uint64
+	ToBlock   uint64
+	Limit     uint64
+}
+
+// narrows down epochs or slots by number & start time, zero values match everything, results are ordered by
+// number, newest first if Descending, at most Limit per page & Cursor continues after the page it was returned with
+type RangeQuery struct {
+	From       uint64
+	To         uint64
+	FromTime   time.Time
+	ToTime     time.Time
+	Descending bool
+	Limit      uint64
+	Cursor     string
+}
+
+// represents a page of epochs, Next is the cursor of the following page, empty on the last one
+type EpochPage struct {
+	Epochs []Epoch `json:"epochs"`
+	Next   string  `json:"next,omitempty"`
+}
+
+// represents a page of slots, Next is the cursor of the following page, empty on the last one
+type SlotPage struct {
+	Slots []Slot `json:"slots"`
+	Next  string `json:"next,omitempty"`
+}
+
+// represents a slot, times are derived from the genesis time rather than the block,
+// Block is the canonical block & Candidates the competing ones which were reorged out
 type Slot struct {
 	SlotNumber  uint64    `json:"slotNumber" db:"slot_number"`
 	StartTime   time.Time `json:"startTime" db:"start_time"`
 	EndTime     time.Time `json:"endTime" db:"end_time"`
 	EpochNumber uint64    `json:"epochNumber" db:"epoch_number"`
+	Revision    int       `json:"revision,omitempty" db:"revision"`
 	Block       Block     `json:"block"`
+	Candidates  []Block   `json:"candidates,omitempty" db:"-"`
 }
 
 // represents an epoch
@@ -49,5 +199,108 @@ type Epoch struct {
 	EpochNumber uint64    `json:"epochNumber" db:"epoch_number"`
 	StartTime   time.Time `json:"startTime" db:"start_time"`
 	EndTime     time.Time `json:"endTime" db:"end_time"`
+	Revision    int       `json:"revision,omitempty" db:"revision"`
 	Slots       []Slot    `json:"slots"`
+	// fork active during the epoch, set by the HTTP API
+	Fork string `json:"fork,omitempty" db:"-"`
+	// aggregates computed when the epoch is assembled
+	Stats *EpochStats `json:"stats,omitempty" db:"-"`
+}
+
+// decides what happens to rows of an epoch which are already stored, e.g. when an epoch is re-processed
+type ConflictPolicy string
+
+const (
+	// stored rows are kept as they are
+	ConflictSkip ConflictPolicy = "skip"
+	// stored rows are replaced
+	ConflictOverwrite ConflictPolicy = "overwrite"
+	// stored rows are replaced only if they differ, bumping their revision
+	ConflictVersion ConflictPolicy = "version"
+)
+
+// validates a conflict policy, empty means ConflictSkip
+func ParseConflictPolicy(policy string) (ConflictPolicy, error) {
+	switch ConflictPolicy(policy) {
+	case "":
+		return ConflictSkip, nil
+	case ConflictSkip, ConflictOverwrite, ConflictVersion:
+		return ConflictPolicy(policy), nil
+	}
+	return "", fmt.Errorf("unknown conflict policy %q, expected one of: skip, overwrite, version", policy)
+}
+
+// counts the epoch, slot & block rows written by a single write
+type WriteResult struct {
+	Inserted int `json:"inserted"`
+	Updated  int `json:"updated"`
+	Skipped  int `json:"skipped"`
+}
+
+// represents an entry of the fork schedule, Name is the spec.DataVersion of blocks from Epoch on
+type Fork struct {
+	Name            string `json:"name" db:"name"`
+	PreviousVersion string `json:"previousVersion" db:"previous_version"`
+	CurrentVersion  string `json:"currentVersion" db:"current_version"`
+	Epoch           uint64 `json:"epoch" db:"epoch"`
+}
+
+// represents aggregates of an epoch, block times are the seconds between consecutive blocks
+type EpochStats struct {
+	EpochNumber      uint64  `json:"epochNumber" db:"epoch_number"`
+	ProposedSlots    int     `json:"proposedSlots" db:"proposed_slots"`
+	MissedSlots      int     `json:"missedSlots" db:"missed_slots"`
+	NoOfTransactions int     `json:"noOfTransactions" db:"no_of_transactions"`
+	GasUsed          uint64  `json:"gasUsed" db:"gas_used"`
+	GasLimit         uint64  `json:"gasLimit" db:"gas_limit"`
+	AvgGasUsed       uint64  `json:"avgGasUsed" db:"avg_gas_used"`
+	GasUtilisation   float64 `json:"gasUtilisation" db:"gas_utilisation"`
+	MinBlockTime     float64 `json:"minBlockTime" db:"min_block_time"`
+	MaxBlockTime     float64 `json:"maxBlockTime" db:"max_block_time"`
+	AvgBlockTime     float64 `json:"avgBlockTime" db:"avg_block_time"`
+}
+
+// represents the share of blocks built by a builder, per epoch or over a range of epochs
+type BuilderShare struct {
+	EpochNumber uint64  `json:"epochNumber,omitempty" db:"epoch_number"`
+	Builder     string  `json:"builder" db:"builder"`
+	Blocks      int     `json:"blocks" db:"blocks"`
+	Share       float64 `json:"share" db:"share"`
+}
+
+// represents the duties of a watched validator during an epoch & how they were performed
+type ValidatorActivity struct {
+	ValidatorIndex       uint64 `json:"validatorIndex" db:"validator_index"`
+	EpochNumber          uint64 `json:"epochNumber" db:"epoch_number"`
+	ProposerDuties       int    `json:"proposerDuties" db:"proposer_duties"`
+	Proposed             int    `json:"proposed" db:"proposed"`
+	AttesterDuties       int    `json:"attesterDuties" db:"attester_duties"`
+	AttestationsIncluded int    `json:"attestationsIncluded" db:"attestations_included"`
+	// slots between the attestation duty & its first inclusion, 0 if not included
+	InclusionDelay   int `json:"inclusionDelay" db:"inclusion_delay"`
+	SyncDuties       int `json:"syncDuties" db:"sync_duties"`
+	SyncParticipated int `json:"syncParticipated" db:"sync_participated"`
+	// balance in gwei at the end of the epoch
+	Balance uint64 `json:"balance" db:"balance"`
+	// balance change in gwei since the end of the previous epoch, withdrawals & deposits included
+	Reward int64 `json:"reward" db:"reward"`
+}
+
+// represents a chain reorganisation reported by the beacon node
+type Reorg struct {
+	Slot         uint64 `json:"slot"`
+	Depth        uint64 `json:"depth"`
+	EpochNumber  uint64 `json:"epochNumber"`
+	OldHeadBlock string `json:"oldHeadBlock"`
+	NewHeadBlock string `json:"newHeadBlock"`
+}
+
+// announces an epoch written to the database, as notified by store.Store on commit, Result is set by single epoch
+// writes only
+type EpochNotification struct {
+	EpochNumber uint64       `json:"epochNumber"`
+	FirstSlot   uint64       `json:"firstSlot"`
+	LastSlot    uint64       `json:"lastSlot"`
+	HeadRoot    string       `json:"headRoot,omitempty"`
+	Result      *WriteResult `json:"result,omitempty"`
 }
@@ -0,0 +1,139 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"indexer/pkg/models"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// File appends epochs as NDJSON to files in a directory, starting a new file once the current one
+// reaches maxBytes or gets older than maxAge
+type File struct {
+	dir      string
+	maxBytes int64
+	maxAge   time.Duration
+	now      func() time.Time
+
+	mu       sync.Mutex
+	file     *os.File
+	size     int64
+	openedAt time.Time
+	seq      int
+}
+
+// File implements Sink
+var _ Sink = &File{}
+
+// Creates new file sink, zero maxBytes or maxAge disables the respective rotation
+func NewFile(dir string, maxBytes int64, maxAge time.Duration) (*File, error) {
+	err := os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return nil, fmt.Errorf("os.MkdirAll(%s) failed, err: %v", dir, err.Error())
+	}
+	return &File{dir: dir, maxBytes: maxBytes, maxAge: maxAge, now: time.Now}, nil
+}
+
+func (f *File) Name() string { return "file:" + f.dir }
+
+func (f *File) Write(_ context.Context, epoch models.Epoch) error {
+	line, err := json.Marshal(epoch)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file == nil || f.full(int64(len(line))) {
+		err = f.rotate()
+		if err != nil {
+			return err
+		}
+	}
+	n, err := f.file.Write(line)
+	f.size += int64(n)
+	if err != nil {
+		return err
+	}
+	return f.file.Sync()
+}
+
+func (f *File) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
+
+// a file always takes at least one epoch, however large
+func (f *File) full(next int64) bool {
+	if f.size == 0 {
+		return false
+	}
+	if f.maxBytes > 0 && f.size+next > f.maxBytes {
+		return true
+	}
+	return f.maxAge > 0 && f.now().Sub(f.openedAt) >= f.maxAge
+}
+
+func (f *File) rotate() error {
+	if f.file != nil {
+		err := f.file.Close()
+		if err != nil {
+			return err
+		}
+		f.file = nil
+	}
+	f.openedAt = f.now()
+	f.seq++
+	name := filepath.Join(f.dir, fmt.Sprintf("epochs-%s-%d.ndjson", f.openedAt.UTC().Format("20060102T150405"), f.seq))
+	file, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	f.file, f.size = file, 0
+	return nil
+}
+
+// Writer writes epochs as NDJSON to a stream, e.g. stdout
+type Writer struct {
+	name string
+	mu   sync.Mutex
+	w    io.Writer
+}
+
+// Writer implements Sink
+var _ Sink = &Writer{}
+
+func NewWriter(name string, w io.Writer) *Writer {
+	return &Writer{name: name, w: w}
+}
+
+func Stdout() *Writer {
+	return NewWriter("stdout", os.Stdout)
+}
+
+func (w *Writer) Name() string { return w.name }
+
+func (w *Writer) Write(_ context.Context, epoch models.Epoch) error {
+	line, err := json.Marshal(epoch)
+	if err != nil {
+		return err
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	_, err = w.w.Write(append(line, '\n'))
+	return err
+}
+
+func (w *Writer) Close() error { return nil }
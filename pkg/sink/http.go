@@ -0,0 +1,58 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"indexer/pkg/models"
+	"net/http"
+	"time"
+)
+
+// HTTP POSTs every epoch as JSON, any non 2xx response is a failed delivery, a permanent one for client errors
+// other than timeouts & rate limits
+type HTTP struct {
+	url        string
+	httpClient *http.Client
+}
+
+// HTTP implements Sink
+var _ Sink = &HTTP{}
+
+func NewHTTP(url string) *HTTP {
+	return &HTTP{
+		url:        url,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (h *HTTP) Name() string { return h.url }
+
+func (h *HTTP) Write(ctx context.Context, epoch models.Epoch) error {
+	body, err := json.Marshal(epoch)
+	if err != nil {
+		return fmt.Errorf("%w: epoch encoding failed, err: %v", ErrPermanent, err.Error())
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := h.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusRequestTimeout || resp.StatusCode == http.StatusTooManyRequests:
+		return fmt.Errorf("%s responded %s", h.url, resp.Status)
+	case resp.StatusCode >= 400 && resp.StatusCode <= 499:
+		return fmt.Errorf("%w: %s responded %s", ErrPermanent, h.url, resp.Status)
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		return fmt.Errorf("%s responded %s", h.url, resp.Status)
+	}
+	return nil
+}
+
+func (h *HTTP) Close() error { return nil }
@@ -0,0 +1,131 @@
+package sink
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"indexer/pkg/models"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NATS publishes every epoch as JSON to a subject of a NATS server, speaking the plain text client protocol,
+// a write succeeds once the server answered the PING following the PUB, i.e. processed the message
+type NATS struct {
+	addr    string
+	subject string
+	timeout time.Duration
+
+	mu     sync.Mutex
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// NATS implements Sink
+var _ Sink = &NATS{}
+
+// Creates new NATS sink, the connection is established on the first write & re-established after failures
+func NewNATS(addr, subject string) *NATS {
+	return &NATS{addr: addr, subject: subject, timeout: 10 * time.Second}
+}
+
+func (n *NATS) Name() string { return "nats://" + n.addr + "/" + n.subject }
+
+func (n *NATS) Write(ctx context.Context, epoch models.Epoch) error {
+	payload, err := json.Marshal(epoch)
+	if err != nil {
+		return err
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	err = n.publish(ctx, payload)
+	if err != nil && n.conn != nil {
+		n.conn.Close()
+		n.conn = nil
+	}
+	return err
+}
+
+func (n *NATS) Close() error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.conn == nil {
+		return nil
+	}
+	err := n.conn.Close()
+	n.conn = nil
+	return err
+}
+
+func (n *NATS) publish(ctx context.Context, payload []byte) error {
+	if n.conn == nil {
+		err := n.connect(ctx)
+		if err != nil {
+			return err
+		}
+	}
+	deadline := time.Now().Add(n.timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	n.conn.SetDeadline(deadline)
+
+	_, err := fmt.Fprintf(n.conn, "PUB %s %d\r\n%s\r\nPING\r\n", n.subject, len(payload), payload)
+	if err != nil {
+		return err
+	}
+	return n.awaitPong()
+}
+
+// dials the server, expects its INFO & introduces the client
+func (n *NATS) connect(ctx context.Context) error {
+	dialer := net.Dialer{Timeout: n.timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", n.addr)
+	if err != nil {
+		return err
+	}
+	conn.SetDeadline(time.Now().Add(n.timeout))
+	reader := bufio.NewReader(conn)
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	if !strings.HasPrefix(line, "INFO ") {
+		conn.Close()
+		return fmt.Errorf("unexpected greeting from %s: %q", n.addr, strings.TrimSpace(line))
+	}
+	_, err = fmt.Fprintf(conn, "CONNECT {\"verbose\":false,\"pedantic\":false,\"name\":\"indexer\"}\r\n")
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	n.conn, n.reader = conn, reader
+	return nil
+}
+
+// reads until the server answers our PING, errors reported meanwhile fail the write
+func (n *NATS) awaitPong() error {
+	for {
+		line, err := n.reader.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "PONG":
+			return nil
+		case line == "PING":
+			_, err = n.conn.Write([]byte("PONG\r\n"))
+			if err != nil {
+				return err
+			}
+		case strings.HasPrefix(line, "-ERR"):
+			return errors.New("nats server responded " + line)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package sink
+
+import (
+	"fmt"
+	"indexer/pkg/models"
+	"indexer/pkg/store"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Parse creates a sink & its delivery policy from a url like spec:
+//
+//	postgres://?conflict=overwrite                      the repository, conflict is one of skip, overwrite, version
+//	sqlite://, memory://                                the repository as well, the sink is named after the scheme
+//	stdout://                                           NDJSON on stdout
+//	file:///var/lib/indexer?max_bytes=1e8&max_age=24h   rotating NDJSON files
+//	https://example.com/epochs                          HTTP POST
+//	nats://localhost:4222/indexer.epochs                NATS subject
+//
+// every spec accepts guarantee (at-least-once, at-most-once), queue, attempts & backoff query parameters
+func Parse(spec string, repo store.Repository) (Sink, Policy, error) {
+	u, err := url.Parse(spec)
+	if err != nil {
+		return nil, Policy{}, fmt.Errorf("invalid sink %q, err: %v", spec, err.Error())
+	}
+	q := u.Query()
+	policy, err := parsePolicy(q)
+	if err != nil {
+		return nil, Policy{}, fmt.Errorf("invalid sink %q, err: %v", spec, err.Error())
+	}
+	u.RawQuery = q.Encode()
+
+	switch u.Scheme {
+	case "postgres", "sqlite", "memory":
+		if repo == nil {
+			return nil, Policy{}, fmt.Errorf("%s sink requires a repository", u.Scheme)
+		}
+		conflict, err := models.ParseConflictPolicy(q.Get("conflict"))
+		if err != nil {
+			return nil, Policy{}, fmt.Errorf("invalid sink %q, err: %v", spec, err.Error())
+		}
+		return NewRepository(u.Scheme, repo, conflict), policy, nil
+	case "stdout":
+		return Stdout(), policy, nil
+	case "file":
+		var maxBytes float64
+		if v := q.Get("max_bytes"); v != "" {
+			maxBytes, err = strconv.ParseFloat(v, 64)
+			if err != nil {
+				return nil, Policy{}, fmt.Errorf("invalid max_bytes of sink %q", spec)
+			}
+		}
+		var maxAge time.Duration
+		if v := q.Get("max_age"); v != "" {
+			maxAge, err = time.ParseDuration(v)
+			if err != nil {
+				return nil, Policy{}, fmt.Errorf("invalid max_age of sink %q", spec)
+			}
+		}
+		sink, err := NewFile(u.Path, int64(maxBytes), maxAge)
+		return sink, policy, err
+	case "http", "https":
+		return NewHTTP(u.String()), policy, nil
+	case "nats":
+		subject := strings.TrimPrefix(u.Path, "/")
+		if u.Host == "" || subject == "" {
+			return nil, Policy{}, fmt.Errorf("sink %q requires a host & a subject", spec)
+		}
+		return NewNATS(u.Host, subject), policy, nil
+	default:
+		return nil, Policy{}, fmt.Errorf("unknown sink %q, expected one of: postgres, sqlite, memory, stdout, file, http, https, nats", spec)
+	}
+}
+
+// reads & removes the policy parameters, the remaining ones belong to the sink
+func parsePolicy(q url.Values) (Policy, error) {
+	policy := DefaultPolicy
+	var err error
+	if v := q.Get("guarantee"); v != "" {
+		policy.Guarantee = Guarantee(v)
+		if policy.Guarantee != AtLeastOnce && policy.Guarantee != AtMostOnce {
+			return Policy{}, fmt.Errorf("unknown guarantee %q", v)
+		}
+	}
+	if v := q.Get("queue"); v != "" {
+		policy.QueueSize, err = strconv.Atoi(v)
+		if err != nil {
+			return Policy{}, fmt.Errorf("invalid queue %q", v)
+		}
+	}
+	if v := q.Get("attempts"); v != "" {
+		policy.MaxAttempts, err = strconv.Atoi(v)
+		if err != nil {
+			return Policy{}, fmt.Errorf("invalid attempts %q", v)
+		}
+	}
+	if v := q.Get("backoff"); v != "" {
+		policy.Backoff, err = time.ParseDuration(v)
+		if err != nil {
+			return Policy{}, fmt.Errorf("invalid backoff %q", v)
+		}
+	}
+	for _, key := range []string{"guarantee", "queue", "attempts", "backoff"} {
+		q.Del(key)
+	}
+	return policy, nil
+}
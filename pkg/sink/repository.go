@@ -0,0 +1,45 @@
+package sink
+
+import (
+	"context"
+	"indexer/pkg/models"
+	"indexer/pkg/store"
+	"log"
+)
+
+// Repository stores epochs in a store.Repository, keeping only the most recent ones, epochs stored before are
+// handled according to the conflict policy
+type Repository struct {
+	name   string
+	repo   store.Repository
+	policy models.ConflictPolicy
+}
+
+// Repository implements Sink
+var _ Sink = &Repository{}
+
+// name tells the kind of store, e.g. postgres or sqlite
+func NewRepository(name string, repo store.Repository, policy models.ConflictPolicy) *Repository {
+	return &Repository{name: name, repo: repo, policy: policy}
+}
+
+func (r *Repository) Name() string { return r.name }
+
+func (r *Repository) Write(ctx context.Context, epoch models.Epoch) error {
+	result, err := r.repo.Create(ctx, epoch, r.policy)
+	if err != nil {
+		return err
+	}
+	if result.Updated > 0 || result.Skipped > 0 {
+		log.Printf("epoch %d was stored before, %d rows inserted, %d updated, %d skipped\n",
+			epoch.EpochNumber, result.Inserted, result.Updated, result.Skipped)
+	}
+	// pruning failures are not retried, the next epoch prunes again
+	err = r.repo.KeepOnlyTop5(ctx, epoch.EpochNumber)
+	if err != nil {
+		log.Printf("repo.KeepOnlyTop5() failed, err: %v\n", err.Error())
+	}
+	return nil
+}
+
+func (r *Repository) Close() error { return nil }
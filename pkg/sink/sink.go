@@ -0,0 +1,177 @@
+package sink
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"indexer/pkg/models"
+	"log"
+	"sync"
+	"time"
+)
+
+// Sink receives every epoch produced by the indexer
+type Sink interface {
+	Name() string
+	Write(context.Context, models.Epoch) error
+	Close() error
+}
+
+type Guarantee string
+
+const (
+	// epochs are dropped when the sink falls behind or a write keeps failing
+	AtMostOnce Guarantee = "at-most-once"
+	// the indexer waits for the sink when it falls behind & failed writes are retried until they succeed
+	AtLeastOnce Guarantee = "at-least-once"
+)
+
+// Policy tells how epochs are delivered to a sink
+type Policy struct {
+	Guarantee Guarantee
+	// number of epochs buffered for the sink
+	QueueSize int
+	// number of attempts per epoch, 0 retries forever, only AtLeastOnce sinks retry & never on ErrPermanent
+	MaxAttempts int
+	// delay before the first retry, doubled for every further attempt up to a minute
+	Backoff time.Duration
+}
+
+var DefaultPolicy = Policy{
+	Guarantee:   AtLeastOnce,
+	QueueSize:   16,
+	MaxAttempts: 10,
+	Backoff:     time.Second,
+}
+
+// wrapped by sinks around failures which no retry can fix, e.g. a request the receiver rejects, the epoch is given
+// up on at once
+var ErrPermanent = errors.New("permanent failure")
+
+type worker struct {
+	sink   Sink
+	policy Policy
+	queue  chan models.Epoch
+}
+
+// Dispatcher delivers epochs to several sinks at once, each with its own queue & policy
+type Dispatcher struct {
+	workers []*worker
+	wg      sync.WaitGroup
+	// cancels in-flight writes & retries when draining takes too long
+	ctx    context.Context
+	cancel context.CancelFunc
+	mu     sync.Mutex
+	closed bool
+}
+
+func NewDispatcher() *Dispatcher {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Dispatcher{ctx: ctx, cancel: cancel}
+}
+
+// adds a sink, must be called before Start
+func (d *Dispatcher) Add(sink Sink, policy Policy) {
+	if policy.QueueSize <= 0 {
+		policy.QueueSize = DefaultPolicy.QueueSize
+	}
+	if policy.Backoff <= 0 {
+		policy.Backoff = DefaultPolicy.Backoff
+	}
+	d.workers = append(d.workers, &worker{
+		sink:   sink,
+		policy: policy,
+		queue:  make(chan models.Epoch, policy.QueueSize),
+	})
+}
+
+// starts delivering to every sink until Close is called
+func (d *Dispatcher) Start() {
+	for _, w := range d.workers {
+		d.wg.Add(1)
+		go func(w *worker) {
+			defer d.wg.Done()
+			for epoch := range w.queue {
+				w.deliver(d.ctx, epoch)
+			}
+		}(w)
+	}
+}
+
+// queues the epoch for every sink, blocks while an AtLeastOnce sink's queue is full or until ctx is done,
+// epochs dispatched after Close are ignored
+func (d *Dispatcher) Dispatch(ctx context.Context, epoch models.Epoch) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return
+	}
+	for _, w := range d.workers {
+		if w.policy.Guarantee == AtLeastOnce {
+			select {
+			case w.queue <- epoch:
+			case <-ctx.Done():
+			}
+			continue
+		}
+		select {
+		case w.queue <- epoch:
+		default:
+			log.Printf("sink %s is falling behind, epoch %d dropped\n", w.sink.Name(), epoch.EpochNumber)
+		}
+	}
+}
+
+// drains the queues & closes every sink, pending writes are abandoned once ctx is done,
+// a blocked Dispatch must be cancelled first
+func (d *Dispatcher) Close(ctx context.Context) error {
+	d.mu.Lock()
+	d.closed = true
+	for _, w := range d.workers {
+		close(w.queue)
+	}
+	d.mu.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		d.cancel()
+		<-drained
+	}
+	d.cancel()
+	var lastErr error
+	for _, w := range d.workers {
+		if err := w.sink.Close(); err != nil {
+			lastErr = fmt.Errorf("sink %s close failed, err: %v", w.sink.Name(), err.Error())
+		}
+	}
+	return lastErr
+}
+
+func (w *worker) deliver(ctx context.Context, epoch models.Epoch) {
+	backoff := w.policy.Backoff
+	for attempt := 1; ; attempt++ {
+		err := w.sink.Write(ctx, epoch)
+		if err == nil {
+			return
+		}
+		log.Printf("sink %s write of epoch %d failed (attempt %d), err: %v\n", w.sink.Name(), epoch.EpochNumber, attempt, err.Error())
+		if w.policy.Guarantee != AtLeastOnce || attempt == w.policy.MaxAttempts || errors.Is(err, ErrPermanent) {
+			log.Printf("sink %s gave up on epoch %d\n", w.sink.Name(), epoch.EpochNumber)
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > time.Minute {
+			backoff = time.Minute
+		}
+	}
+}
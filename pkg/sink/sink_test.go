@@ -0,0 +1,354 @@
+package sink
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"indexer/pkg/mock"
+	"indexer/pkg/models"
+	"indexer/pkg/store"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// records epochs written, failing the first failures writes, permanently when set, writes announce themselves on
+// entered & wait for release when set
+type recorder struct {
+	mu        sync.Mutex
+	epochs    []uint64
+	failures  int
+	permanent bool
+	entered   chan struct{}
+	release   chan struct{}
+}
+
+func (r *recorder) Name() string { return "recorder" }
+
+func (r *recorder) Write(_ context.Context, epoch models.Epoch) error {
+	if r.entered != nil {
+		r.entered <- struct{}{}
+		<-r.release
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.failures > 0 {
+		r.failures--
+		if r.permanent {
+			return fmt.Errorf("%w: rejected", ErrPermanent)
+		}
+		return errors.New("unavailable")
+	}
+	r.epochs = append(r.epochs, epoch.EpochNumber)
+	return nil
+}
+
+func (r *recorder) Close() error { return nil }
+
+func (r *recorder) written() []uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.epochs
+}
+
+func TestDispatcher(t *testing.T) {
+	ctx := context.Background()
+	retrying := &recorder{failures: 2}
+	giving := &recorder{failures: 2}
+	rejecting := &recorder{failures: 2, permanent: true}
+	blocked := &recorder{entered: make(chan struct{}, 4), release: make(chan struct{})}
+
+	d := NewDispatcher()
+	d.Add(retrying, Policy{Guarantee: AtLeastOnce, Backoff: time.Millisecond})
+	d.Add(giving, Policy{Guarantee: AtMostOnce, Backoff: time.Millisecond})
+	d.Add(rejecting, Policy{Guarantee: AtLeastOnce, Backoff: time.Millisecond})
+	d.Add(blocked, Policy{Guarantee: AtMostOnce, QueueSize: 1})
+	d.Start()
+	d.Dispatch(ctx, models.Epoch{EpochNumber: 1})
+	<-blocked.entered
+	for number := uint64(2); number <= 4; number++ {
+		d.Dispatch(ctx, models.Epoch{EpochNumber: number})
+	}
+	close(blocked.release)
+	assert.NoError(t, d.Close(ctx))
+
+	// retried until delivered
+	assert.Equal(t, []uint64{1, 2, 3, 4}, retrying.written())
+	// failed writes are not retried
+	assert.Equal(t, []uint64{3, 4}, giving.written())
+	// permanent failures are not retried either
+	assert.Equal(t, []uint64{3, 4}, rejecting.written())
+	// the first epoch is being written, the second is queued, the rest is dropped
+	assert.Equal(t, []uint64{1, 2}, blocked.written())
+}
+
+func TestFile(t *testing.T) {
+	dir := t.TempDir()
+	line, _ := json.Marshal(models.Epoch{EpochNumber: 10})
+	sink, err := NewFile(dir, int64(2*(len(line)+1)), 0)
+	assert.NoError(t, err)
+	for number := uint64(10); number < 15; number++ {
+		assert.NoError(t, sink.Write(context.Background(), models.Epoch{EpochNumber: number}))
+	}
+	assert.NoError(t, sink.Close())
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.ndjson"))
+	assert.NoError(t, err)
+	// rotated after every 2 epochs, names sort in order of creation
+	assert.Len(t, files, 3)
+	var got []uint64
+	for _, name := range files {
+		f, err := os.Open(name)
+		assert.NoError(t, err)
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			var epoch models.Epoch
+			assert.NoError(t, json.Unmarshal(scanner.Bytes(), &epoch))
+			got = append(got, epoch.EpochNumber)
+		}
+		f.Close()
+	}
+	assert.Equal(t, []uint64{10, 11, 12, 13, 14}, got)
+}
+
+func TestFileMaxAge(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	sink, err := NewFile(dir, 0, time.Hour)
+	assert.NoError(t, err)
+	sink.now = func() time.Time { return now }
+	for _, elapsed := range []time.Duration{0, 30 * time.Minute, time.Hour, 3 * time.Hour} {
+		now = now.Add(elapsed)
+		assert.NoError(t, sink.Write(context.Background(), models.Epoch{}))
+	}
+	assert.NoError(t, sink.Close())
+	files, _ := filepath.Glob(filepath.Join(dir, "*.ndjson"))
+	assert.Len(t, files, 3)
+}
+
+func TestWriter(t *testing.T) {
+	var buf bytes.Buffer
+	sink := NewWriter("buffer", &buf)
+	assert.NoError(t, sink.Write(context.Background(), models.Epoch{EpochNumber: 1}))
+	assert.NoError(t, sink.Write(context.Background(), models.Epoch{EpochNumber: 2}))
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Len(t, lines, 2)
+	assert.Contains(t, lines[1], `"epochNumber":2`)
+}
+
+func TestHTTP(t *testing.T) {
+	var mu sync.Mutex
+	var received []uint64
+	status := http.StatusServiceUnavailable
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		var epoch models.Epoch
+		if r.Method != http.MethodPost || json.NewDecoder(r.Body).Decode(&epoch) != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if status == http.StatusOK {
+			received = append(received, epoch.EpochNumber)
+		}
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	sink := NewHTTP(server.URL)
+	err := sink.Write(context.Background(), models.Epoch{EpochNumber: 1})
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, ErrPermanent)
+	mu.Lock()
+	status = http.StatusUnprocessableEntity
+	mu.Unlock()
+	assert.ErrorIs(t, sink.Write(context.Background(), models.Epoch{EpochNumber: 1}), ErrPermanent)
+	mu.Lock()
+	status = http.StatusOK
+	mu.Unlock()
+	assert.NoError(t, sink.Write(context.Background(), models.Epoch{EpochNumber: 1}))
+	assert.Equal(t, []uint64{1}, received)
+}
+
+// local stand-in for a NATS server, records published payloads per subject & answers PINGs,
+// rejecting publishes while reject is set
+type natsServer struct {
+	listener  net.Listener
+	mu        sync.Mutex
+	published map[string][]string
+	connects  int
+	reject    bool
+}
+
+func newNATSServer(t *testing.T) *natsServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	s := &natsServer{listener: listener, published: make(map[string][]string)}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	t.Cleanup(func() { listener.Close() })
+	return s
+}
+
+func (s *natsServer) serve(conn net.Conn) {
+	defer conn.Close()
+	fmt.Fprintf(conn, "INFO {\"server_id\":\"stand-in\",\"max_payload\":1048576}\r\n")
+	reader := bufio.NewReader(conn)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "CONNECT":
+			s.mu.Lock()
+			s.connects++
+			s.mu.Unlock()
+		case "PING":
+			fmt.Fprintf(conn, "PONG\r\n")
+		case "PUB":
+			size, _ := strconv.Atoi(fields[len(fields)-1])
+			payload := make([]byte, size+2)
+			if _, err := io.ReadFull(reader, payload); err != nil {
+				return
+			}
+			s.mu.Lock()
+			reject := s.reject
+			if !reject {
+				s.published[fields[1]] = append(s.published[fields[1]], string(payload[:size]))
+			}
+			s.mu.Unlock()
+			if reject {
+				// the server closes the connection after protocol errors
+				fmt.Fprintf(conn, "-ERR 'Permissions Violation for Publish to %s'\r\n", fields[1])
+				return
+			}
+		}
+	}
+}
+
+func (s *natsServer) rejecting(reject bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reject = reject
+}
+
+func TestNATS(t *testing.T) {
+	server := newNATSServer(t)
+	sink := NewNATS(server.listener.Addr().String(), "indexer.epochs")
+	defer sink.Close()
+
+	assert.NoError(t, sink.Write(context.Background(), models.Epoch{EpochNumber: 1}))
+	server.rejecting(true)
+	assert.Error(t, sink.Write(context.Background(), models.Epoch{EpochNumber: 2}))
+	server.rejecting(false)
+	// reconnects after the failure
+	assert.NoError(t, sink.Write(context.Background(), models.Epoch{EpochNumber: 3}))
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	assert.Equal(t, 2, server.connects)
+	if assert.Len(t, server.published["indexer.epochs"], 2) {
+		var epoch models.Epoch
+		assert.NoError(t, json.Unmarshal([]byte(server.published["indexer.epochs"][1]), &epoch))
+		assert.Equal(t, uint64(3), epoch.EpochNumber)
+	}
+}
+
+func TestNATSUnreachable(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	addr := listener.Addr().String()
+	listener.Close()
+	sink := NewNATS(addr, "indexer.epochs")
+	assert.Error(t, sink.Write(context.Background(), models.Epoch{}))
+}
+
+func TestParse(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name       string
+		spec       string
+		wantName   string
+		wantPolicy Policy
+		repo       store.Repository
+		wantErr    bool
+	}{
+		{name: "stdout", spec: "stdout://", wantName: "stdout", wantPolicy: DefaultPolicy},
+		{
+			name:       "file with rotation & policy",
+			spec:       "file://" + dir + "?max_bytes=1e6&max_age=1h&guarantee=at-most-once&queue=4",
+			wantName:   "file:" + dir,
+			wantPolicy: Policy{Guarantee: AtMostOnce, QueueSize: 4, MaxAttempts: 10, Backoff: time.Second},
+		},
+		{
+			name:       "http keeps its own query",
+			spec:       "https://example.com/epochs?token=abc&attempts=5&backoff=2s",
+			wantName:   "https://example.com/epochs?token=abc",
+			wantPolicy: Policy{Guarantee: AtLeastOnce, QueueSize: 16, MaxAttempts: 5, Backoff: 2 * time.Second},
+		},
+		{name: "nats", spec: "nats://localhost:4222/indexer.epochs", wantName: "nats://localhost:4222/indexer.epochs", wantPolicy: DefaultPolicy},
+		{name: "nats without subject", spec: "nats://localhost:4222", wantErr: true},
+		{name: "postgres", spec: "postgres://?conflict=version", wantName: "postgres", wantPolicy: DefaultPolicy, repo: mock.New()},
+		{name: "postgres without repository", spec: "postgres://", wantErr: true},
+		{name: "sqlite", spec: "sqlite://?conflict=overwrite", wantName: "sqlite", wantPolicy: DefaultPolicy, repo: mock.New()},
+		{name: "memory", spec: "memory://", wantName: "memory", wantPolicy: DefaultPolicy, repo: mock.New()},
+		{name: "unknown conflict policy", spec: "postgres://?conflict=merge", repo: mock.New(), wantErr: true},
+		{name: "unknown guarantee", spec: "stdout://?guarantee=exactly-once", wantErr: true},
+		{name: "unknown kind", spec: "kafka://localhost:9092/epochs", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sink, policy, err := Parse(tt.spec, tt.repo)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantName, sink.Name())
+			assert.Equal(t, tt.wantPolicy, policy)
+		})
+	}
+}
+
+// records the conflict policies epochs are written with
+type conflictRecorder struct {
+	*mock.Store
+	policies []models.ConflictPolicy
+}
+
+func (c *conflictRecorder) Create(ctx context.Context, e models.Epoch, policy models.ConflictPolicy) (models.WriteResult, error) {
+	c.policies = append(c.policies, policy)
+	return models.WriteResult{Skipped: 1}, nil
+}
+
+func TestRepository(t *testing.T) {
+	repo := &conflictRecorder{Store: mock.New()}
+	sink := NewRepository("postgres", repo, models.ConflictOverwrite)
+	assert.NoError(t, sink.Write(context.Background(), models.Epoch{EpochNumber: 10}))
+	assert.Equal(t, []models.ConflictPolicy{models.ConflictOverwrite}, repo.policies)
+}
@@ -0,0 +1,104 @@
+package store_test
+
+import (
+	"context"
+	"fmt"
+	"indexer/pkg/models"
+	"indexer/pkg/store"
+	"indexer/pkg/store/storetest"
+	"sort"
+	"testing"
+	"time"
+)
+
+// stores epochs from 0 on
+func seed(tb testing.TB, s *store.Store, epochs int) {
+	tb.Helper()
+	_, err := s.CreateBatch(context.Background(), storetest.Epochs(0, epochs), models.ConflictSkip)
+	if err != nil {
+		tb.Fatalf("s.CreateBatch() failed, err: %v", err.Error())
+	}
+}
+
+func BenchmarkGet(b *testing.B) {
+	s := store.NewTestStore(b)
+	ctx := context.Background()
+	for _, epochs := range []int{5, 50, 200} {
+		seed(b, s, epochs)
+		b.Run(fmt.Sprintf("sql join/%d epochs", epochs), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				got, err := s.Get(ctx)
+				if err != nil || len(got) != epochs {
+					b.Fatalf("s.Get() returned %d epochs, err: %v", len(got), err)
+				}
+			}
+		})
+		b.Run(fmt.Sprintf("in memory join/%d epochs", epochs), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				got, err := store.GetInMemory(ctx, s)
+				if err != nil || len(got) != epochs {
+					b.Fatalf("getInMemory() returned %d epochs, err: %v", len(got), err)
+				}
+			}
+		})
+	}
+}
+
+// the latest page of epochs, as read by the API, assembled by postgres & by joining every table in memory, as
+// epochs were read before
+func BenchmarkGetEpochs(b *testing.B) {
+	s := store.NewTestStore(b)
+	ctx := context.Background()
+	seed(b, s, 200)
+	b.Run("sql join", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			page, err := s.GetEpochs(ctx, models.RangeQuery{Descending: true, Limit: 5})
+			if err != nil || len(page.Epochs) != 5 {
+				b.Fatalf("s.GetEpochs() returned %d epochs, err: %v", len(page.Epochs), err)
+			}
+		}
+	})
+	b.Run("in memory join", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			got, err := store.GetInMemory(ctx, s)
+			if err != nil || len(got) != 200 {
+				b.Fatalf("getInMemory() returned %d epochs, err: %v", len(got), err)
+			}
+			// the latest 5
+			sort.Slice(got, func(i, j int) bool { return got[i].EpochNumber > got[j].EpochNumber })
+			got = got[:5]
+		}
+	})
+}
+
+// throughput of backfills, epochs are written in batches of 100 by CreateBatch & one by one by Create
+func BenchmarkBackfill(b *testing.B) {
+	s := store.NewTestStore(b)
+	ctx := context.Background()
+	const batchSize = 100
+	next := 0
+	b.Run("copy", func(b *testing.B) {
+		start := time.Now()
+		for i := 0; i < b.N; i++ {
+			_, err := s.CreateBatch(ctx, storetest.Epochs(next, batchSize), models.ConflictSkip)
+			if err != nil {
+				b.Fatalf("s.CreateBatch() failed, err: %v", err.Error())
+			}
+			next += batchSize
+		}
+		b.ReportMetric(float64(b.N*batchSize)/time.Since(start).Seconds(), "epochs/s")
+	})
+	b.Run("insert", func(b *testing.B) {
+		start := time.Now()
+		for i := 0; i < b.N; i++ {
+			for _, epoch := range storetest.Epochs(next, batchSize) {
+				_, err := s.Create(ctx, epoch, models.ConflictSkip)
+				if err != nil {
+					b.Fatalf("s.Create() failed, err: %v", err.Error())
+				}
+			}
+			next += batchSize
+		}
+		b.ReportMetric(float64(b.N*batchSize)/time.Since(start).Seconds(), "epochs/s")
+	})
+}
@@ -0,0 +1,164 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"indexer/pkg/models"
+	"strings"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v4"
+)
+
+// tables written by CreateBatch in the order they are merged, parents before children, revisioned tables get
+// their revision bumped under models.ConflictVersion
+var bulkTables = []struct {
+	name       string
+	columns    []string
+	revisioned bool
+	counted    bool
+}{
+	{"epochs", []string{"epoch_number", "start_time", "end_time", "revision"}, true, true},
+	{"epoch_stats", []string{"epoch_number", "proposed_slots", "missed_slots", "no_of_transactions", "gas_used", "gas_limit",
+		"avg_gas_used", "gas_utilisation", "min_block_time", "max_block_time", "avg_block_time"}, false, false},
+	{"slots", []string{"slot_number", "start_time", "end_time", "epoch_number", "revision"}, true, true},
+	{"blocks", []string{"block_number", "block_root", "parent_root", "canonical", "state_root", "slot_number", "gas_limit",
+		"gas_used", "no_of_transactions", "created_at", "version", "fee_recipient", "extra_data", "builder", "mev_boost",
+		"revision"}, true, true},
+	{"receipts", []string{"block_root", "transaction_hash", "transaction_index", "block_number", "status", "gas_used",
+		"effective_gas_price", "log_count"}, false, false},
+	{"contract_events", []string{"block_root", "block_number", "transaction_hash", "log_index", "address", "contract",
+		"event", "signature", "args"}, false, false},
+}
+
+// writes many epochs in a single transaction, meant for backfills: rows are copied into temporary staging tables
+// using the COPY protocol & then merged into the tables under policy, as Create does. Rows repeated within the
+// batch are merged once & counted as skipped
+func (s *Store) CreateBatch(ctx context.Context, epochs []models.Epoch, policy models.ConflictPolicy) (models.WriteResult, error) {
+	result := models.WriteResult{}
+	policy, err := models.ParseConflictPolicy(string(policy))
+	if err != nil {
+		return result, err
+	}
+	if len(epochs) == 0 {
+		return result, nil
+	}
+	rows := stage(epochs)
+	roots, err := bytesOf(epochs)
+	if err != nil {
+		return result, err
+	}
+	from, to, hasSlots := slotRange(epochs)
+	if hasSlots {
+		err = s.partitions.Ensure(ctx, from, to)
+		if err != nil {
+			return result, err
+		}
+	}
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return result, err
+	}
+	success := false
+	defer func() {
+		if success {
+			tx.Commit(ctx)
+
+		} else {
+			tx.Rollback(ctx)
+		}
+	}()
+
+	for _, table := range bulkTables {
+		if len(rows[table.name]) == 0 {
+			continue
+		}
+		// roots & hashes are staged as bytes
+		for _, row := range rows[table.name] {
+			for idx, column := range table.columns {
+				if hexColumns[column] {
+					row[idx] = roots[row[idx].(string)]
+				}
+			}
+		}
+		staging := "staging_" + table.name
+		_, err = tx.Exec(ctx, fmt.Sprintf("CREATE TEMP TABLE %s (LIKE %s INCLUDING DEFAULTS) ON COMMIT DROP", staging, table.name))
+		if err != nil {
+			return result, fmt.Errorf("%s staging table creation failed, err: %v", table.name, err.Error())
+		}
+		_, err = tx.CopyFrom(ctx, pgx.Identifier{staging}, table.columns, pgx.CopyFromRows(rows[table.name]))
+		if err != nil {
+			return result, fmt.Errorf("%s copy failed, err: %v", table.name, err.Error())
+		}
+
+		target := strings.Join(upsertColumns[table.name].target, ", ")
+		bldr := s.builder.Insert(table.name).Columns(table.columns...).Select(
+			squirrel.Select(table.columns...).Options("DISTINCT ON (" + target + ")").From(staging).OrderBy(target))
+		written, err := upsert(ctx, tx, table.name, bldr, len(rows[table.name]), policy, table.revisioned)
+		if err != nil {
+			return result, err
+		}
+		if table.counted {
+			result = sum(result, written)
+		}
+	}
+
+	// reorgs may reach back into epochs stored earlier & span the whole batch
+	if head, ok := headOf(epochs); ok {
+		err = s.canonicalize(ctx, tx, head, from)
+		if err != nil {
+			return result, err
+		}
+	}
+
+	// one notification per epoch, the counts of a batch do not break down by epoch
+	if result.Inserted+result.Updated > 0 {
+		notifications := make([]models.EpochNotification, 0, len(epochs))
+		for _, e := range epochs {
+			notifications = append(notifications, notificationOf(e, nil))
+		}
+		err = notify(ctx, tx, notifications)
+		if err != nil {
+			return result, err
+		}
+	}
+
+	success = true
+	return result, nil
+}
+
+// flattens epochs into rows per table, in the column order of bulkTables
+func stage(epochs []models.Epoch) map[string][][]interface{} {
+	rows := make(map[string][][]interface{})
+	for _, e := range epochs {
+		rows["epochs"] = append(rows["epochs"], []interface{}{e.EpochNumber, e.StartTime, e.EndTime, revisionOf(e.Revision)})
+		if st := e.Stats; st != nil {
+			rows["epoch_stats"] = append(rows["epoch_stats"], []interface{}{e.EpochNumber, st.ProposedSlots, st.MissedSlots,
+				st.NoOfTransactions, st.GasUsed, st.GasLimit, st.AvgGasUsed, st.GasUtilisation, st.MinBlockTime, st.MaxBlockTime,
+				st.AvgBlockTime})
+		}
+		for _, slot := range e.Slots {
+			rows["slots"] = append(rows["slots"], []interface{}{slot.SlotNumber, slot.StartTime, slot.EndTime, slot.EpochNumber,
+				revisionOf(slot.Revision)})
+			blocks := slot.Candidates
+			if slot.Block.BlockRoot != "" {
+				blocks = append([]models.Block{slot.Block}, blocks...)
+			}
+			for _, b := range blocks {
+				rows["blocks"] = append(rows["blocks"], []interface{}{b.BlockNumber, b.BlockRoot, b.ParentRoot, b.Canonical,
+					b.StateRoot, b.SlotNumber, b.GasLimit, b.GasUsed, b.NoOfTransactions, b.CreatedAt, b.Version, b.FeeRecipient,
+					b.ExtraData, b.Builder, b.MEVBoost, revisionOf(b.Revision)})
+				for _, r := range b.Receipts {
+					rows["receipts"] = append(rows["receipts"], []interface{}{b.BlockRoot, r.TransactionHash,
+						r.TransactionIndex, r.BlockNumber, r.Status, r.GasUsed, r.EffectiveGasPrice, r.LogCount})
+				}
+				for _, ev := range b.Events {
+					rows["contract_events"] = append(rows["contract_events"], []interface{}{b.BlockRoot, ev.BlockNumber,
+						ev.TransactionHash, ev.LogIndex, ev.Address, ev.Contract, ev.Event, ev.Signature, ev.Args})
+				}
+			}
+		}
+	}
+	return rows
+}
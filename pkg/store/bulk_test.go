@@ -0,0 +1,51 @@
+package store
+
+import (
+	"indexer/pkg/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_stage(t *testing.T) {
+	epochs := []models.Epoch{
+		{
+			EpochNumber: 1,
+			Stats:       &models.EpochStats{EpochNumber: 1, ProposedSlots: 1, MissedSlots: 1},
+			Slots: []models.Slot{
+				{
+					SlotNumber: 32, EpochNumber: 1,
+					Block: models.Block{
+						BlockRoot: "0xb", SlotNumber: 32, Canonical: true,
+						Receipts: []models.Receipt{{TransactionHash: "0x1"}, {TransactionHash: "0x2", TransactionIndex: 1}},
+						Events:   []models.ContractEvent{{TransactionHash: "0x2", LogIndex: 4}},
+					},
+					Candidates: []models.Block{{BlockRoot: "0xa", SlotNumber: 32}},
+				},
+				// missed
+				{SlotNumber: 33, EpochNumber: 1},
+			},
+		},
+		{EpochNumber: 2, Slots: []models.Slot{{SlotNumber: 64, EpochNumber: 2}}},
+	}
+	rows := stage(epochs)
+	assert.Len(t, rows["epochs"], 2)
+	assert.Len(t, rows["epoch_stats"], 1)
+	assert.Len(t, rows["slots"], 3)
+	if assert.Len(t, rows["blocks"], 2) {
+		// the canonical block first, then its competitors
+		assert.Equal(t, "0xb", rows["blocks"][0][1])
+		assert.Equal(t, "0xa", rows["blocks"][1][1])
+	}
+	if assert.Len(t, rows["receipts"], 2) {
+		assert.Equal(t, "0xb", rows["receipts"][0][0])
+	}
+	assert.Len(t, rows["contract_events"], 1)
+
+	// every row matches the columns it is copied into
+	for _, table := range bulkTables {
+		for _, row := range rows[table.name] {
+			assert.Len(t, row, len(table.columns), table.name)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package store_test
+
+import (
+	"indexer/pkg/db"
+	"indexer/pkg/store"
+	"indexer/pkg/store/storetest"
+	"path/filepath"
+	"testing"
+)
+
+func TestStore(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) store.Repository {
+		return store.NewTestStore(t)
+	})
+}
+
+func TestSQLite(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) store.Repository {
+		path := filepath.Join(t.TempDir(), "indexer.db")
+		err := db.MigrateSQLite(path)
+		if err != nil {
+			t.Fatalf("db.MigrateSQLite() failed, err: %v", err.Error())
+		}
+		conn, err := db.OpenSQLite(path)
+		if err != nil {
+			t.Fatalf("db.OpenSQLite() failed, err: %v", err.Error())
+		}
+		t.Cleanup(func() { conn.Close() })
+		return store.NewSQLite(conn)
+	})
+}
+
+func TestMemory(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) store.Repository {
+		return store.NewMemory()
+	})
+}
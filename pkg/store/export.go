@@ -0,0 +1,344 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"indexer/pkg/models"
+	"io"
+	"os"
+	"time"
+)
+
+const (
+	// identifies an export, as the format of its header
+	ExportFormat = "indexer-epochs"
+	// version of the export format written by Export, Import reads it & every earlier one
+	ExportVersion = 1
+	// number of epochs read per page by Export & written per batch by Import
+	exportPageSize = 64
+)
+
+var ErrInvalidExport = errors.New("invalid export")
+
+// first line of an export, the range is the one it was exported with, zero values meaning unbounded
+type ExportHeader struct {
+	Format     string    `json:"format"`
+	Version    int       `json:"version"`
+	From       uint64    `json:"from"`
+	To         uint64    `json:"to"`
+	ExportedAt time.Time `json:"exportedAt"`
+}
+
+// last line of an export, SHA256 is the hex encoded checksum of the epoch lines in between, newlines included
+type ExportTrailer struct {
+	Epochs int    `json:"epochs"`
+	SHA256 string `json:"sha256"`
+}
+
+// implemented by repositories able to write many epochs at once, e.g. Store
+type batchCreator interface {
+	CreateBatch(context.Context, []models.Epoch, models.ConflictPolicy) (models.WriteResult, error)
+}
+
+// Store implements batchCreator
+var _ batchCreator = &Store{}
+
+// writes the epochs of repo within the epoch range of query to w as NDJSON: a header line, one models.Epoch per
+// line in ascending order, with its stats, slots, blocks, receipts & contract events, & a trailer line
+func Export(ctx context.Context, repo Repository, w io.Writer, query models.RangeQuery) (ExportTrailer, error) {
+	trailer := ExportTrailer{}
+	out := bufio.NewWriter(w)
+	err := writeLine(out, ExportHeader{ExportFormat, ExportVersion, query.From, query.To, time.Now().UTC()})
+	if err != nil {
+		return trailer, err
+	}
+	checksum := sha256.New()
+	query.Descending, query.Limit, query.Cursor = false, exportPageSize, ""
+	for {
+		page, err := repo.GetEpochs(ctx, query)
+		if err != nil {
+			return trailer, fmt.Errorf("repo.GetEpochs() failed, err: %v", err.Error())
+		}
+		err = attachEvents(ctx, repo, page.Epochs)
+		if err != nil {
+			return trailer, err
+		}
+		statsOf, err := statsByEpoch(ctx, repo, page.Epochs)
+		if err != nil {
+			return trailer, err
+		}
+		for _, e := range page.Epochs {
+			if st, ok := statsOf[e.EpochNumber]; ok {
+				e.Stats = &st
+			}
+			err = writeLine(io.MultiWriter(out, checksum), e)
+			if err != nil {
+				return trailer, err
+			}
+			trailer.Epochs++
+		}
+		if page.Next == "" {
+			break
+		}
+		query.Cursor = page.Next
+	}
+
+	trailer.SHA256 = hex.EncodeToString(checksum.Sum(nil))
+	err = writeLine(out, trailer)
+	if err != nil {
+		return trailer, err
+	}
+	return trailer, out.Flush()
+}
+
+// returns the stats of the epochs by epoch number, read from the first to the last of epochs
+func statsByEpoch(ctx context.Context, repo Repository, epochs []models.Epoch) (map[uint64]models.EpochStats, error) {
+	statsOf := make(map[uint64]models.EpochStats, len(epochs))
+	if len(epochs) == 0 {
+		return statsOf, nil
+	}
+	stats, err := repo.GetEpochStatsBetween(ctx, epochs[0].EpochNumber, epochs[len(epochs)-1].EpochNumber)
+	if err != nil {
+		return nil, fmt.Errorf("repo.GetEpochStatsBetween() failed, err: %v", err.Error())
+	}
+	for _, st := range stats {
+		statsOf[st.EpochNumber] = st
+	}
+	return statsOf, nil
+}
+
+// sets the contract events of the blocks of epochs, which are not part of the epochs read
+func attachEvents(ctx context.Context, repo Repository, epochs []models.Epoch) error {
+	var filter models.EventFilter
+	var blocks []*models.Block
+	for _, e := range epochs {
+		for idx := range e.Slots {
+			slot := &e.Slots[idx]
+			blocks = append(blocks, &slot.Block)
+			for c := range slot.Candidates {
+				blocks = append(blocks, &slot.Candidates[c])
+			}
+		}
+	}
+	for _, b := range blocks {
+		if n := b.BlockNumber; n > 0 {
+			if filter.FromBlock == 0 || n < filter.FromBlock {
+				filter.FromBlock = n
+			}
+			if n > filter.ToBlock {
+				filter.ToBlock = n
+			}
+		}
+	}
+	// pre-merge blocks have no execution payload to emit events
+	if filter.ToBlock == 0 {
+		return nil
+	}
+	events, err := repo.GetContractEvents(ctx, filter)
+	if err != nil {
+		return fmt.Errorf("repo.GetContractEvents() failed, err: %v", err.Error())
+	}
+	eventsOf := make(map[string][]models.ContractEvent)
+	for _, ev := range events {
+		eventsOf[ev.BlockRoot] = append(eventsOf[ev.BlockRoot], ev)
+	}
+	for _, b := range blocks {
+		b.Events = eventsOf[b.BlockRoot]
+	}
+	return nil
+}
+
+func writeLine(w io.Writer, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("export line encoding failed, err: %v", err.Error())
+	}
+	_, err = w.Write(append(b, '\n'))
+	if err != nil {
+		return fmt.Errorf("export write failed, err: %v", err.Error())
+	}
+	return nil
+}
+
+// writes the epochs of an export written by Export within the epoch range of query to repo under policy. The whole
+// export is checked against its trailer before anything is written, so that a truncated or altered export fails
+// with ErrInvalidExport & leaves repo alone. Readers which can't seek, e.g. stdin, are spooled to a temporary file
+// to be read twice
+func Import(ctx context.Context, repo Repository, in io.Reader, query models.RangeQuery, policy models.ConflictPolicy) (models.WriteResult, error) {
+	result := models.WriteResult{}
+	policy, err := models.ParseConflictPolicy(string(policy))
+	if err != nil {
+		return result, err
+	}
+	r, ok := in.(io.ReadSeeker)
+	if ok {
+		// files of pipes implement io.ReadSeeker but fail to seek
+		_, err = r.Seek(0, io.SeekCurrent)
+		ok = err == nil
+	}
+	if !ok {
+		spool, err := os.CreateTemp("", "indexer-import-*.ndjson")
+		if err != nil {
+			return result, fmt.Errorf("export spool creation failed, err: %v", err.Error())
+		}
+		defer func() {
+			spool.Close()
+			os.Remove(spool.Name())
+		}()
+		_, err = io.Copy(spool, in)
+		if err != nil {
+			return result, fmt.Errorf("export spooling failed, err: %v", err.Error())
+		}
+		_, err = spool.Seek(0, io.SeekStart)
+		if err != nil {
+			return result, fmt.Errorf("export seek failed, err: %v", err.Error())
+		}
+		r = spool
+	}
+	_, err = CheckExport(r)
+	if err != nil {
+		return result, err
+	}
+	_, err = r.Seek(0, io.SeekStart)
+	if err != nil {
+		return result, fmt.Errorf("export seek failed, err: %v", err.Error())
+	}
+
+	// repositories with a bulk path are written a page of epochs per transaction, one epoch at a time otherwise
+	bulk, isBulk := repo.(batchCreator)
+	var batch []models.Epoch
+	write := func() error {
+		var written models.WriteResult
+		var err error
+		if isBulk {
+			written, err = bulk.CreateBatch(ctx, batch, policy)
+		} else {
+			for _, e := range batch {
+				var one models.WriteResult
+				one, err = repo.Create(ctx, e, policy)
+				if err != nil {
+					break
+				}
+				written = sum(written, one)
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("epochs %d-%d could not be imported, err: %v", batch[0].EpochNumber, batch[len(batch)-1].EpochNumber, err.Error())
+		}
+		result = sum(result, written)
+		batch = batch[:0]
+		return nil
+	}
+	_, _, err = scanExport(r, func(line []byte) error {
+		var e models.Epoch
+		err := json.Unmarshal(line, &e)
+		if err != nil {
+			return fmt.Errorf("%w: epoch decoding failed, err: %v", ErrInvalidExport, err.Error())
+		}
+		if (query.From > 0 && e.EpochNumber < query.From) || (query.To > 0 && e.EpochNumber > query.To) {
+			return nil
+		}
+		batch = append(batch, e)
+		if len(batch) == exportPageSize {
+			return write()
+		}
+		return nil
+	})
+	if err != nil {
+		return result, err
+	}
+	if len(batch) > 0 {
+		err = write()
+	}
+	return result, err
+}
+
+// reads a whole export & checks its header, its epoch lines & the trailer they must match, without writing anything
+func CheckExport(r io.Reader) (ExportTrailer, error) {
+	_, trailer, err := scanExport(r, func(line []byte) error {
+		var e models.Epoch
+		err := json.Unmarshal(line, &e)
+		if err != nil {
+			return fmt.Errorf("%w: epoch decoding failed, err: %v", ErrInvalidExport, err.Error())
+		}
+		return nil
+	})
+	return trailer, err
+}
+
+// reads an export line by line & hands every epoch line to each, the last line is the trailer, which the count &
+// checksum of the epoch lines are checked against once all of them were read
+func scanExport(r io.Reader, each func([]byte) error) (ExportHeader, ExportTrailer, error) {
+	var header ExportHeader
+	var trailer ExportTrailer
+	in := bufio.NewReader(r)
+	line, err := readLine(in)
+	if errors.Is(err, io.EOF) {
+		return header, trailer, fmt.Errorf("%w: the export is empty", ErrInvalidExport)
+	}
+	if err != nil {
+		return header, trailer, err
+	}
+	err = json.Unmarshal(line, &header)
+	if err != nil || header.Format != ExportFormat {
+		return header, trailer, fmt.Errorf("%w: no export header", ErrInvalidExport)
+	}
+	if header.Version < 1 || header.Version > ExportVersion {
+		return header, trailer, fmt.Errorf("%w: version %d, expected at most %d", ErrInvalidExport, header.Version, ExportVersion)
+	}
+
+	// a line is only known to be an epoch once another one follows it
+	checksum := sha256.New()
+	epochs := 0
+	var pending []byte
+	for {
+		line, err = readLine(in)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return header, trailer, err
+		}
+		if pending != nil {
+			checksum.Write(pending)
+			checksum.Write([]byte{'\n'})
+			epochs++
+			err = each(pending)
+			if err != nil {
+				return header, trailer, err
+			}
+		}
+		pending = line
+	}
+	if pending == nil || json.Unmarshal(pending, &trailer) != nil || trailer.SHA256 == "" {
+		return header, trailer, fmt.Errorf("%w: no trailer, the export is truncated", ErrInvalidExport)
+	}
+	if trailer.Epochs != epochs {
+		return header, trailer, fmt.Errorf("%w: %d epochs, expected %d", ErrInvalidExport, epochs, trailer.Epochs)
+	}
+	if got := hex.EncodeToString(checksum.Sum(nil)); got != trailer.SHA256 {
+		return header, trailer, fmt.Errorf("%w: checksum %s, expected %s", ErrInvalidExport, got, trailer.SHA256)
+	}
+	return header, trailer, nil
+}
+
+// the next line without its newline, io.EOF once every line was read
+func readLine(in *bufio.Reader) ([]byte, error) {
+	line, err := in.ReadBytes('\n')
+	if errors.Is(err, io.EOF) && len(line) > 0 {
+		err = nil
+	}
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("export read failed, err: %v", err.Error())
+	}
+	return bytes.TrimSuffix(line, []byte{'\n'}), nil
+}
@@ -0,0 +1,177 @@
+package store_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"indexer/pkg/models"
+	"indexer/pkg/store"
+	"indexer/pkg/store/storetest"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// an export of epochs 0-2, split into lines without their newlines
+func exportLines(t *testing.T) [][]byte {
+	ctx := context.Background()
+	source := store.NewMemory()
+	epochs := storetest.Epochs(0, 3)
+	epochs[1].Slots[1].Block.Revision = 3
+	for _, e := range epochs {
+		_, err := source.Create(ctx, e, models.ConflictSkip)
+		assert.NoError(t, err)
+	}
+	var exported bytes.Buffer
+	_, err := store.Export(ctx, source, &exported, models.RangeQuery{})
+	assert.NoError(t, err)
+	return bytes.Split(bytes.TrimSuffix(exported.Bytes(), []byte{'\n'}), []byte{'\n'})
+}
+
+func TestExport(t *testing.T) {
+	lines := exportLines(t)
+	if !assert.Len(t, lines, 5) {
+		return
+	}
+	var header store.ExportHeader
+	assert.NoError(t, json.Unmarshal(lines[0], &header))
+	assert.Equal(t, store.ExportFormat, header.Format)
+	assert.Equal(t, store.ExportVersion, header.Version)
+
+	var epoch models.Epoch
+	assert.NoError(t, json.Unmarshal(lines[2], &epoch))
+	assert.Equal(t, uint64(1), epoch.EpochNumber)
+	assert.Equal(t, 3, epoch.Slots[1].Block.Revision, "inserted rows keep their revision")
+
+	var trailer store.ExportTrailer
+	assert.NoError(t, json.Unmarshal(lines[4], &trailer))
+	checked, err := store.CheckExport(bytes.NewReader(append(bytes.Join(lines, []byte{'\n'}), '\n')))
+	assert.NoError(t, err)
+	assert.Equal(t, trailer, checked)
+	assert.Equal(t, 3, checked.Epochs)
+}
+
+func TestExport_range(t *testing.T) {
+	ctx := context.Background()
+	source := store.NewMemory()
+	for _, e := range storetest.Epochs(0, 3) {
+		e.Stats = &models.EpochStats{EpochNumber: e.EpochNumber, ProposedSlots: 4}
+		_, err := source.Create(ctx, e, models.ConflictSkip)
+		assert.NoError(t, err)
+	}
+	var exported bytes.Buffer
+	_, err := store.Export(ctx, source, &exported, models.RangeQuery{From: 1, To: 1})
+	assert.NoError(t, err)
+	lines := bytes.Split(bytes.TrimSuffix(exported.Bytes(), []byte{'\n'}), []byte{'\n'})
+	if !assert.Len(t, lines, 3) {
+		return
+	}
+	var epoch models.Epoch
+	assert.NoError(t, json.Unmarshal(lines[1], &epoch))
+	assert.Equal(t, uint64(1), epoch.EpochNumber)
+	if assert.NotNil(t, epoch.Stats) {
+		assert.Equal(t, uint64(1), epoch.Stats.EpochNumber)
+	}
+}
+
+// reads r without being an io.Seeker, as a pipe
+type pipe struct{ io.Reader }
+
+func TestImport_pipe(t *testing.T) {
+	ctx := context.Background()
+	export := append(bytes.Join(exportLines(t), []byte{'\n'}), '\n')
+	repo := store.NewMemory()
+	result, err := store.Import(ctx, repo, pipe{bytes.NewReader(export)}, models.RangeQuery{}, models.ConflictSkip)
+	assert.NoError(t, err)
+	assert.Equal(t, models.WriteResult{Inserted: 3 * 9}, result)
+
+	_, err = store.Import(ctx, repo, pipe{bytes.NewReader(export[:len(export)/2])}, models.RangeQuery{}, models.ConflictSkip)
+	assert.ErrorIs(t, err, store.ErrInvalidExport)
+}
+
+func TestImport(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name   string
+		edit   func(lines [][]byte) [][]byte
+		query  models.RangeQuery
+		want   []uint64
+		result models.WriteResult
+		err    error
+	}{
+		{
+			name:   "a whole export",
+			edit:   func(lines [][]byte) [][]byte { return lines },
+			want:   []uint64{0, 1, 2},
+			result: models.WriteResult{Inserted: 3 * 9},
+		},
+		{
+			name:   "a range of an export",
+			edit:   func(lines [][]byte) [][]byte { return lines },
+			query:  models.RangeQuery{From: 1, To: 1},
+			want:   []uint64{1},
+			result: models.WriteResult{Inserted: 9},
+		},
+		{
+			name: "an empty export",
+			edit: func(lines [][]byte) [][]byte { return nil },
+			err:  store.ErrInvalidExport,
+		},
+		{
+			name: "no header",
+			edit: func(lines [][]byte) [][]byte { return lines[1:] },
+			err:  store.ErrInvalidExport,
+		},
+		{
+			name: "a newer version",
+			edit: func(lines [][]byte) [][]byte {
+				lines[0] = bytes.Replace(lines[0], []byte(`"version":1`), []byte(`"version":2`), 1)
+				return lines
+			},
+			err: store.ErrInvalidExport,
+		},
+		{
+			name: "a truncated export",
+			edit: func(lines [][]byte) [][]byte { return lines[:3] },
+			err:  store.ErrInvalidExport,
+		},
+		{
+			name: "a missing epoch",
+			edit: func(lines [][]byte) [][]byte { return append(lines[:2], lines[3:]...) },
+			err:  store.ErrInvalidExport,
+		},
+		{
+			name: "an altered epoch",
+			edit: func(lines [][]byte) [][]byte {
+				lines[2] = bytes.Replace(lines[2], []byte(`"gasUsed":15000000`), []byte(`"gasUsed":15000001`), 1)
+				return lines
+			},
+			err: store.ErrInvalidExport,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var export []byte
+			for _, line := range tt.edit(exportLines(t)) {
+				export = append(append(export, line...), '\n')
+			}
+			repo := store.NewMemory()
+			result, err := store.Import(ctx, repo, bytes.NewReader(export), tt.query, models.ConflictSkip)
+			assert.ErrorIs(t, err, tt.err)
+			assert.Equal(t, tt.result, result)
+
+			// nothing is written from an invalid export
+			page, err := repo.GetEpochs(ctx, models.RangeQuery{})
+			assert.NoError(t, err)
+			numbers := []uint64{}
+			for _, e := range page.Epochs {
+				numbers = append(numbers, e.EpochNumber)
+			}
+			if tt.want == nil {
+				tt.want = []uint64{}
+			}
+			assert.Equal(t, tt.want, numbers)
+		})
+	}
+}
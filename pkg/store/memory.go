@@ -0,0 +1,618 @@
+package store
+
+import (
+	"context"
+	"indexer/pkg/models"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Memory implements Repository in memory, for tests & demo mode, it behaves like Store down to conflict policies,
+// reorgs & retention, nothing survives a restart though. Lookups scan the rows, which suits the few epochs kept
+// under retention
+type Memory struct {
+	mu       sync.RWMutex
+	epochs   map[uint64]models.Epoch
+	stats    map[uint64]models.EpochStats
+	slots    map[uint64]models.Slot
+	blocks   map[string]models.Block
+	receipts map[string]map[uint64]models.Receipt
+	events   map[string]map[uint64]models.ContractEvent
+	forks    map[string]models.Fork
+	watched  []string
+	activity map[[2]uint64]models.ValidatorActivity
+}
+
+// Memory implements Repository
+var _ Repository = &Memory{}
+
+func NewMemory() *Memory {
+	return &Memory{
+		epochs:   make(map[uint64]models.Epoch),
+		stats:    make(map[uint64]models.EpochStats),
+		slots:    make(map[uint64]models.Slot),
+		blocks:   make(map[string]models.Block),
+		receipts: make(map[string]map[uint64]models.Receipt),
+		events:   make(map[string]map[uint64]models.ContractEvent),
+		forks:    make(map[string]models.Fork),
+		activity: make(map[[2]uint64]models.ValidatorActivity),
+	}
+}
+
+// writes an epoch like Store.Create does
+func (m *Memory) Create(ctx context.Context, e models.Epoch, policy models.ConflictPolicy) (models.WriteResult, error) {
+	result := models.WriteResult{}
+	policy, err := models.ParseConflictPolicy(string(policy))
+	if err != nil {
+		return result, err
+	}
+	// roots are kept as text, they are checked as Store decodes them so that both refuse the same epochs
+	_, err = bytesOf([]models.Epoch{e})
+	if err != nil {
+		return result, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	epoch := models.Epoch{EpochNumber: e.EpochNumber, StartTime: utc(e.StartTime), EndTime: utc(e.EndTime), Revision: revisionOf(e.Revision)}
+	stored, ok := m.epochs[epoch.EpochNumber]
+	if ok {
+		epoch.Revision = stored.Revision
+	}
+	written := merge(ok, reflect.DeepEqual(epoch, stored), policy)
+	if written != skipped {
+		epoch.Revision = revise(epoch.Revision, written, policy)
+		m.epochs[epoch.EpochNumber] = epoch
+	}
+	result = count(result, written)
+
+	if e.Stats != nil {
+		stats := *e.Stats
+		stats.EpochNumber = e.EpochNumber
+		stored, ok := m.stats[stats.EpochNumber]
+		if merge(ok, stats == stored, policy) != skipped {
+			m.stats[stats.EpochNumber] = stats
+		}
+	}
+
+	for _, s := range e.Slots {
+		slot := models.Slot{SlotNumber: s.SlotNumber, StartTime: utc(s.StartTime), EndTime: utc(s.EndTime), EpochNumber: s.EpochNumber, Revision: revisionOf(s.Revision)}
+		stored, ok := m.slots[slot.SlotNumber]
+		if ok {
+			slot.Revision = stored.Revision
+		}
+		written := merge(ok, reflect.DeepEqual(slot, stored), policy)
+		if written != skipped {
+			slot.Revision = revise(slot.Revision, written, policy)
+			m.slots[slot.SlotNumber] = slot
+		}
+		result = count(result, written)
+
+		blocks := s.Candidates
+		if s.Block.BlockRoot != "" {
+			blocks = append([]models.Block{s.Block}, blocks...)
+		}
+		for _, b := range blocks {
+			b.CreatedAt, b.Receipts, b.Events, b.Revision = utc(b.CreatedAt), nil, nil, revisionOf(b.Revision)
+			stored, ok := m.blocks[b.BlockRoot]
+			// canonical is left to canonicalize
+			if ok {
+				b.Canonical, b.Revision = stored.Canonical, stored.Revision
+			}
+			written := merge(ok, reflect.DeepEqual(b, stored), policy)
+			if written != skipped {
+				b.Revision = revise(b.Revision, written, policy)
+				m.blocks[b.BlockRoot] = b
+			}
+			result = count(result, written)
+		}
+
+		for _, b := range blocks {
+			root := b.BlockRoot
+			for _, r := range b.Receipts {
+				r.BlockRoot, r.Logs = root, nil
+				if m.receipts[root] == nil {
+					m.receipts[root] = make(map[uint64]models.Receipt)
+				}
+				stored, ok := m.receipts[root][r.TransactionIndex]
+				if merge(ok, reflect.DeepEqual(r, stored), policy) != skipped {
+					m.receipts[root][r.TransactionIndex] = r
+				}
+			}
+			for _, ev := range b.Events {
+				ev.BlockRoot = root
+				if m.events[root] == nil {
+					m.events[root] = make(map[uint64]models.ContractEvent)
+				}
+				stored, ok := m.events[root][ev.LogIndex]
+				if merge(ok, reflect.DeepEqual(ev, stored), policy) != skipped {
+					m.events[root][ev.LogIndex] = ev
+				}
+			}
+		}
+	}
+
+	// reorgs may reach back into epochs stored earlier
+	if head, ok := headOf([]models.Epoch{e}); ok {
+		m.canonicalize(head)
+	}
+	return result, nil
+}
+
+// outcomes of writing a single row
+const (
+	inserted = iota
+	updated
+	skipped
+)
+
+// decides what becomes of a row under policy, given whether it is stored already & unchanged
+func merge(exists, unchanged bool, policy models.ConflictPolicy) int {
+	switch {
+	case !exists:
+		return inserted
+	case policy == models.ConflictSkip, policy == models.ConflictVersion && unchanged:
+		return skipped
+	}
+	return updated
+}
+
+// the revision of a revisioned row once written, bumped by every models.ConflictVersion update
+func revise(revision int, written int, policy models.ConflictPolicy) int {
+	if written == updated && policy == models.ConflictVersion {
+		return revision + 1
+	}
+	return revision
+}
+
+func count(result models.WriteResult, written int) models.WriteResult {
+	switch written {
+	case inserted:
+		result.Inserted++
+	case updated:
+		result.Updated++
+	default:
+		result.Skipped++
+	}
+	return result
+}
+
+// times are kept in UTC without monotonic clock readings, as they are read back from a database
+func utc(t time.Time) time.Time {
+	return t.Round(0).UTC()
+}
+
+// follows parent roots from head like Store.canonicalize
+func (m *Memory) canonicalize(head models.Block) {
+	for _, b := range m.blocks {
+		if b.Canonical && b.SlotNumber > head.SlotNumber {
+			return
+		}
+	}
+	m.follow(head)
+}
+
+// follows parent roots from head as Create does, even when a newer canonical block is stored, see Store.Recanonicalize
+func (m *Memory) Recanonicalize(ctx context.Context, head models.Block) error {
+	_, err := models.DecodeHex(head.BlockRoot)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.follow(head)
+	return nil
+}
+
+// marks blocks as no longer canonical, see Store.Demote
+func (m *Memory) Demote(ctx context.Context, roots []string) error {
+	for _, root := range roots {
+		_, err := models.DecodeHex(root)
+		if err != nil {
+			return err
+		}
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, root := range roots {
+		if b, ok := m.blocks[root]; ok {
+			b.Canonical = false
+			m.blocks[root] = b
+		}
+	}
+	return nil
+}
+
+func (m *Memory) follow(head models.Block) {
+	floor := floorOf(head.SlotNumber)
+	chain := make(map[string]bool)
+	lowest := head.SlotNumber
+	for b, ok := m.blocks[head.BlockRoot]; ok; b, ok = m.blocks[b.ParentRoot] {
+		if chain[b.BlockRoot] || (b.BlockRoot != head.BlockRoot && b.SlotNumber < floor) {
+			break
+		}
+		chain[b.BlockRoot] = true
+		if b.SlotNumber < lowest {
+			lowest = b.SlotNumber
+		}
+	}
+	if len(chain) == 0 {
+		return
+	}
+	for root, b := range m.blocks {
+		if b.SlotNumber >= lowest && b.SlotNumber <= head.SlotNumber {
+			b.Canonical = chain[root]
+			m.blocks[root] = b
+		}
+	}
+}
+
+// looks up every stored epoch with its slots & blocks, oldest first
+func (m *Memory) Get(ctx context.Context) ([]models.Epoch, error) {
+	page, err := m.GetEpochs(ctx, models.RangeQuery{})
+	if err != nil {
+		return nil, err
+	}
+	return page.Epochs, nil
+}
+
+func (m *Memory) GetEpoch(ctx context.Context, number uint64) (models.Epoch, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	epoch, ok := m.epochs[number]
+	if !ok {
+		return models.Epoch{}, ErrNotFound
+	}
+	return m.assembleEpoch(epoch), nil
+}
+
+func (m *Memory) GetEpochs(ctx context.Context, query models.RangeQuery) (models.EpochPage, error) {
+	page := models.EpochPage{Epochs: []models.Epoch{}}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	numbers := make([]uint64, 0, len(m.epochs))
+	for number := range m.epochs {
+		numbers = append(numbers, number)
+	}
+	numbers, next, err := paginate(numbers, query, func(number uint64) time.Time { return m.epochs[number].StartTime })
+	if err != nil {
+		return page, err
+	}
+	for _, number := range numbers {
+		page.Epochs = append(page.Epochs, m.assembleEpoch(m.epochs[number]))
+	}
+	page.Next = next
+	return page, nil
+}
+
+func (m *Memory) GetSlot(ctx context.Context, number uint64) (models.Slot, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	slot, ok := m.slots[number]
+	if !ok {
+		return models.Slot{}, ErrNotFound
+	}
+	return m.assembleSlot(slot), nil
+}
+
+func (m *Memory) GetSlots(ctx context.Context, query models.RangeQuery) (models.SlotPage, error) {
+	page := models.SlotPage{Slots: []models.Slot{}}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	numbers := make([]uint64, 0, len(m.slots))
+	for number := range m.slots {
+		numbers = append(numbers, number)
+	}
+	numbers, next, err := paginate(numbers, query, func(number uint64) time.Time { return m.slots[number].StartTime })
+	if err != nil {
+		return page, err
+	}
+	for _, number := range numbers {
+		page.Slots = append(page.Slots, m.assembleSlot(m.slots[number]))
+	}
+	page.Next = next
+	return page, nil
+}
+
+// narrows down numbers to the query's range & page like inRange does, along with the cursor of the next page
+func paginate(numbers []uint64, query models.RangeQuery, startTime func(uint64) time.Time) ([]uint64, string, error) {
+	var after uint64
+	if query.Cursor != "" {
+		var err error
+		after, err = decodeCursor(query.Cursor)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+	matching := numbers[:0]
+	for _, number := range numbers {
+		start := startTime(number)
+		switch {
+		case query.From > 0 && number < query.From,
+			query.To > 0 && number > query.To,
+			!query.FromTime.IsZero() && start.Before(query.FromTime),
+			!query.ToTime.IsZero() && start.After(query.ToTime),
+			query.Cursor != "" && !query.Descending && number <= after,
+			query.Cursor != "" && query.Descending && number >= after:
+			continue
+		}
+		matching = append(matching, number)
+	}
+	sort.Slice(matching, func(i, j int) bool {
+		if query.Descending {
+			return matching[i] > matching[j]
+		}
+		return matching[i] < matching[j]
+	})
+	if query.Limit > 0 && uint64(len(matching)) > query.Limit {
+		matching = matching[:query.Limit]
+		return matching, encodeCursor(matching[len(matching)-1]), nil
+	}
+	return matching, "", nil
+}
+
+func (m *Memory) assembleEpoch(epoch models.Epoch) models.Epoch {
+	epoch.Slots = []models.Slot{}
+	for _, slot := range m.slots {
+		if slot.EpochNumber == epoch.EpochNumber {
+			epoch.Slots = append(epoch.Slots, m.assembleSlot(slot))
+		}
+	}
+	sort.Slice(epoch.Slots, func(i, j int) bool { return epoch.Slots[i].SlotNumber < epoch.Slots[j].SlotNumber })
+	return epoch
+}
+
+// the slot with its canonical block & the competing ones ordered by root
+func (m *Memory) assembleSlot(slot models.Slot) models.Slot {
+	for _, b := range m.blocks {
+		if b.SlotNumber != slot.SlotNumber {
+			continue
+		}
+		if b.Canonical {
+			slot.Block = m.assembleBlock(b)
+		} else {
+			slot.Candidates = append(slot.Candidates, m.assembleBlock(b))
+		}
+	}
+	sort.Slice(slot.Candidates, func(i, j int) bool { return slot.Candidates[i].BlockRoot < slot.Candidates[j].BlockRoot })
+	return slot
+}
+
+// the block with its receipts ordered by transaction index
+func (m *Memory) assembleBlock(block models.Block) models.Block {
+	for _, r := range m.receipts[block.BlockRoot] {
+		block.Receipts = append(block.Receipts, r)
+	}
+	sort.Slice(block.Receipts, func(i, j int) bool { return block.Receipts[i].TransactionIndex < block.Receipts[j].TransactionIndex })
+	return block
+}
+
+func (m *Memory) GetBlock(ctx context.Context, root string) (models.Block, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	block, ok := m.blocks[root]
+	if !ok {
+		return models.Block{}, ErrNotFound
+	}
+	return m.assembleBlock(block), nil
+}
+
+func (m *Memory) GetBlockByNumber(ctx context.Context, number uint64) (models.Block, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var found *models.Block
+	for _, b := range m.blocks {
+		if b.BlockNumber == number && b.Canonical && (found == nil || b.SlotNumber < found.SlotNumber) {
+			b := b
+			found = &b
+		}
+	}
+	if found == nil {
+		return models.Block{}, ErrNotFound
+	}
+	return m.assembleBlock(*found), nil
+}
+
+func (m *Memory) GetEpochStats(ctx context.Context, limit uint64) ([]models.EpochStats, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	stats := make([]models.EpochStats, 0, len(m.stats))
+	for _, st := range m.stats {
+		stats = append(stats, st)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].EpochNumber > stats[j].EpochNumber })
+	if limit > 0 && uint64(len(stats)) > limit {
+		stats = stats[:limit]
+	}
+	return stats, nil
+}
+
+func (m *Memory) GetEpochStatsBetween(ctx context.Context, fromEpoch uint64, toEpoch uint64) ([]models.EpochStats, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	stats := []models.EpochStats{}
+	for _, st := range m.stats {
+		if st.EpochNumber >= fromEpoch && st.EpochNumber <= toEpoch {
+			stats = append(stats, st)
+		}
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].EpochNumber < stats[j].EpochNumber })
+	return stats, nil
+}
+
+func (m *Memory) GetContractEvents(ctx context.Context, filter models.EventFilter) ([]models.ContractEvent, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	events := []models.ContractEvent{}
+	for _, byLog := range m.events {
+		for _, ev := range byLog {
+			switch {
+			case filter.Contract != "" && ev.Contract != filter.Contract,
+				filter.Address != "" && ev.Address != strings.ToLower(filter.Address),
+				filter.Event != "" && ev.Event != filter.Event,
+				filter.FromBlock > 0 && ev.BlockNumber < filter.FromBlock,
+				filter.ToBlock > 0 && ev.BlockNumber > filter.ToBlock:
+				continue
+			}
+			events = append(events, ev)
+		}
+	}
+	sort.Slice(events, func(i, j int) bool {
+		if events[i].BlockNumber != events[j].BlockNumber {
+			return events[i].BlockNumber < events[j].BlockNumber
+		}
+		return events[i].LogIndex < events[j].LogIndex
+	})
+	if filter.Limit > 0 && uint64(len(events)) > filter.Limit {
+		events = events[:filter.Limit]
+	}
+	return events, nil
+}
+
+func (m *Memory) GetBuilderShare(ctx context.Context, fromEpoch uint64, toEpoch uint64) ([]models.BuilderShare, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	blocks := make(map[uint64]map[string]int)
+	for _, b := range m.blocks {
+		slot, ok := m.slots[b.SlotNumber]
+		if !ok || !b.Canonical || b.Builder == "" ||
+			(fromEpoch > 0 && slot.EpochNumber < fromEpoch) || (toEpoch > 0 && slot.EpochNumber > toEpoch) {
+			continue
+		}
+		if blocks[slot.EpochNumber] == nil {
+			blocks[slot.EpochNumber] = make(map[string]int)
+		}
+		blocks[slot.EpochNumber][b.Builder]++
+	}
+	shares := []models.BuilderShare{}
+	for epoch, byBuilder := range blocks {
+		var total int
+		for _, n := range byBuilder {
+			total += n
+		}
+		for builder, n := range byBuilder {
+			shares = append(shares, models.BuilderShare{EpochNumber: epoch, Builder: builder, Blocks: n, Share: float64(n) / float64(total)})
+		}
+	}
+	sort.Slice(shares, func(i, j int) bool {
+		if shares[i].EpochNumber != shares[j].EpochNumber {
+			return shares[i].EpochNumber > shares[j].EpochNumber
+		}
+		if shares[i].Blocks != shares[j].Blocks {
+			return shares[i].Blocks > shares[j].Blocks
+		}
+		return shares[i].Builder < shares[j].Builder
+	})
+	return shares, nil
+}
+
+func (m *Memory) SaveForkSchedule(ctx context.Context, forks []models.Fork) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, f := range forks {
+		m.forks[f.Name] = f
+	}
+	return nil
+}
+
+func (m *Memory) GetForkSchedule(ctx context.Context) ([]models.Fork, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	forks := make([]models.Fork, 0, len(m.forks))
+	for _, f := range m.forks {
+		forks = append(forks, f)
+	}
+	sort.Slice(forks, func(i, j int) bool {
+		if forks[i].Epoch != forks[j].Epoch {
+			return forks[i].Epoch < forks[j].Epoch
+		}
+		return forks[i].CurrentVersion < forks[j].CurrentVersion
+	})
+	return forks, nil
+}
+
+func (m *Memory) WatchValidator(ctx context.Context, validator string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, v := range m.watched {
+		if v == validator {
+			return nil
+		}
+	}
+	m.watched = append(m.watched, validator)
+	return nil
+}
+
+func (m *Memory) UnwatchValidator(ctx context.Context, validator string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for idx, v := range m.watched {
+		if v == validator {
+			m.watched = append(m.watched[:idx:idx], m.watched[idx+1:]...)
+			return nil
+		}
+	}
+	return nil
+}
+
+func (m *Memory) GetWatchedValidators(ctx context.Context) ([]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return append([]string{}, m.watched...), nil
+}
+
+func (m *Memory) CreateValidatorActivity(ctx context.Context, activity []models.ValidatorActivity) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, a := range activity {
+		m.activity[[2]uint64{a.ValidatorIndex, a.EpochNumber}] = a
+	}
+	return nil
+}
+
+func (m *Memory) GetValidatorHistory(ctx context.Context, validatorIndex uint64, limit uint64) ([]models.ValidatorActivity, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	activity := []models.ValidatorActivity{}
+	for key, a := range m.activity {
+		if key[0] == validatorIndex {
+			activity = append(activity, a)
+		}
+	}
+	sort.Slice(activity, func(i, j int) bool { return activity[i].EpochNumber > activity[j].EpochNumber })
+	if limit > 0 && uint64(len(activity)) > limit {
+		activity = activity[:limit]
+	}
+	return activity, nil
+}
+
+// drops epochs like Store.KeepOnlyTop5 does, along with their stats, slots, blocks, receipts & contract events
+func (m *Memory) KeepOnlyTop5(ctx context.Context, epochNumber uint64) error {
+	if epochNumber < 5 {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for number := range m.epochs {
+		if number <= epochNumber-5 {
+			delete(m.epochs, number)
+			delete(m.stats, number)
+		}
+	}
+	for number, slot := range m.slots {
+		if _, ok := m.epochs[slot.EpochNumber]; !ok {
+			delete(m.slots, number)
+		}
+	}
+	for root, b := range m.blocks {
+		if _, ok := m.slots[b.SlotNumber]; !ok {
+			delete(m.blocks, root)
+			delete(m.receipts, root)
+			delete(m.events, root)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"indexer/pkg/models"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+const (
+	// channel the epochs written by Create & CreateBatch are notified on
+	EpochsChannel = "epochs"
+	// notifications buffered per subscriber, notifications to a subscriber lagging further behind are dropped
+	subscriberBuffer = 64
+	// wait before listening again once the listening connection failed
+	relistenDelay = time.Second
+)
+
+// notifies the epochs written within tx, postgres delivers them once tx commits & drops them on rollback
+func notify(ctx context.Context, tx pgx.Tx, notifications []models.EpochNotification) error {
+	for _, n := range notifications {
+		payload, err := json.Marshal(n)
+		if err != nil {
+			return fmt.Errorf("notification encoding failed, err: %v", err.Error())
+		}
+		_, err = tx.Exec(ctx, "SELECT pg_notify($1, $2)", EpochsChannel, string(payload))
+		if err != nil {
+			return fmt.Errorf("epochs notify failed, err: %v", err.Error())
+		}
+	}
+	return nil
+}
+
+// the compact notification of a written epoch, well below the 8000 bytes postgres allows per payload
+func notificationOf(e models.Epoch, result *models.WriteResult) models.EpochNotification {
+	n := models.EpochNotification{EpochNumber: e.EpochNumber, Result: result}
+	n.FirstSlot, n.LastSlot, _ = slotRange([]models.Epoch{e})
+	if head, ok := headOf([]models.Epoch{e}); ok {
+		n.HeadRoot = head.BlockRoot
+	}
+	return n
+}
+
+// streams the epochs notified by any process writing to the database, all subscriptions share a single
+// connection which listens for as long as anyone subscribes
+type Subscriber struct {
+	pool        *pgxpool.Pool
+	mu          sync.Mutex
+	subscribers map[chan models.EpochNotification]struct{}
+	// stops the listening connection
+	stop context.CancelFunc
+}
+
+func NewSubscriber(pool *pgxpool.Pool) *Subscriber {
+	return &Subscriber{pool: pool, subscribers: make(map[chan models.EpochNotification]struct{})}
+}
+
+// streams the epochs notified from now on until ctx is done, the stream is closed then, epochs notified while
+// the connection is re-established are missed
+func (s *Subscriber) Subscribe(ctx context.Context) <-chan models.EpochNotification {
+	stream := s.add()
+	go func() {
+		<-ctx.Done()
+		s.remove(stream)
+	}()
+	return stream
+}
+
+func (s *Subscriber) add() chan models.EpochNotification {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stream := make(chan models.EpochNotification, subscriberBuffer)
+	s.subscribers[stream] = struct{}{}
+	if s.stop == nil {
+		var listeningCtx context.Context
+		listeningCtx, s.stop = context.WithCancel(context.Background())
+		go s.run(listeningCtx)
+	}
+	return stream
+}
+
+func (s *Subscriber) remove(stream chan models.EpochNotification) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.subscribers, stream)
+	close(stream)
+	if len(s.subscribers) == 0 && s.stop != nil {
+		s.stop()
+		s.stop = nil
+	}
+}
+
+// hands n to every subscriber without waiting on any of them
+func (s *Subscriber) publish(n models.EpochNotification) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for stream := range s.subscribers {
+		select {
+		case stream <- n:
+		default:
+			log.Printf("subscriber lagging behind, notification of epoch %d dropped\n", n.EpochNumber)
+		}
+	}
+}
+
+// listens until ctx is done, re-establishing the connection whenever it fails
+func (s *Subscriber) run(ctx context.Context) {
+	for ctx.Err() == nil {
+		err := s.listen(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("subscriber.listen() failed, err: %v\n", err.Error())
+			select {
+			case <-time.After(relistenDelay):
+			case <-ctx.Done():
+			}
+		}
+	}
+}
+
+func (s *Subscriber) listen(ctx context.Context) error {
+	pooled, err := s.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	// the connection keeps listening, so it is taken out of the pool rather than handed back
+	conn := pooled.Hijack()
+	defer conn.Close(context.Background())
+
+	_, err = conn.Exec(ctx, "LISTEN "+EpochsChannel)
+	if err != nil {
+		return fmt.Errorf("epochs listen failed, err: %v", err.Error())
+	}
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		var n models.EpochNotification
+		err = json.Unmarshal([]byte(notification.Payload), &n)
+		if err != nil {
+			log.Printf("notification decoding failed, err: %v\n", err.Error())
+			continue
+		}
+		s.publish(n)
+	}
+}
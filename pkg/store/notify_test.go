@@ -0,0 +1,91 @@
+package store
+
+import (
+	"context"
+	"indexer/pkg/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_notificationOf(t *testing.T) {
+	epoch := models.Epoch{
+		EpochNumber: 1,
+		Slots: []models.Slot{
+			{SlotNumber: 32, EpochNumber: 1, Block: models.Block{BlockRoot: "0xa", SlotNumber: 32, Canonical: true}},
+			{SlotNumber: 33, EpochNumber: 1, Block: models.Block{BlockRoot: "0xb", SlotNumber: 33, Canonical: true}},
+			{SlotNumber: 34, EpochNumber: 1},
+		},
+	}
+	result := &models.WriteResult{Inserted: 3}
+	tests := []struct {
+		name   string
+		epoch  models.Epoch
+		result *models.WriteResult
+		want   models.EpochNotification
+	}{
+		{
+			name:   "the head is the last canonical block",
+			epoch:  epoch,
+			result: result,
+			want:   models.EpochNotification{EpochNumber: 1, FirstSlot: 32, LastSlot: 34, HeadRoot: "0xb", Result: result},
+		},
+		{
+			name:  "an epoch without slots has no range",
+			epoch: models.Epoch{EpochNumber: 2},
+			want:  models.EpochNotification{EpochNumber: 2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, notificationOf(tt.epoch, tt.result))
+		})
+	}
+}
+
+func TestSubscriber_publish(t *testing.T) {
+	s := NewSubscriber(nil)
+	// registered by hand, so that nothing listens
+	lagging := make(chan models.EpochNotification)
+	ready := make(chan models.EpochNotification, 1)
+	s.subscribers[lagging] = struct{}{}
+	s.subscribers[ready] = struct{}{}
+
+	s.publish(models.EpochNotification{EpochNumber: 1})
+	assert.Equal(t, models.EpochNotification{EpochNumber: 1}, <-ready)
+	assert.Len(t, lagging, 0)
+}
+
+func TestSubscriber_Subscribe(t *testing.T) {
+	s := testStore(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream := NewSubscriber(s.pool).Subscribe(ctx)
+	// gives the connection time to listen
+	time.Sleep(500 * time.Millisecond)
+
+	epoch := models.Epoch{EpochNumber: 1, Slots: []models.Slot{{SlotNumber: 32, EpochNumber: 1,
+		Block: models.Block{BlockRoot: "0x0a", ParentRoot: "0x00", SlotNumber: 32, Canonical: true}}}}
+	result, err := s.Create(ctx, epoch, models.ConflictSkip)
+	assert.NoError(t, err)
+	// nothing is written the second time, nor notified
+	_, err = s.Create(ctx, epoch, models.ConflictSkip)
+	assert.NoError(t, err)
+
+	select {
+	case n := <-stream:
+		assert.Equal(t, models.EpochNotification{EpochNumber: 1, FirstSlot: 32, LastSlot: 32, HeadRoot: "0x0a", Result: &result}, n)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no notification received")
+	}
+	select {
+	case n := <-stream:
+		t.Fatalf("unexpected notification of epoch %d", n.EpochNumber)
+	case <-time.After(500 * time.Millisecond):
+	}
+
+	cancel()
+	_, open := <-stream
+	assert.False(t, open, "stream must be closed once ctx is done")
+}
@@ -0,0 +1,243 @@
+package store
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"indexer/pkg/models"
+	"strconv"
+	"strings"
+
+	"github.com/Masterminds/squirrel"
+)
+
+var (
+	// returned by lookups of a single epoch, slot or block which is not stored
+	ErrNotFound = errors.New("not found")
+	// returned for cursors which were not handed out with a page
+	ErrInvalidCursor = errors.New("invalid cursor")
+)
+
+// looks up an epoch with its slots & blocks, ErrNotFound if it is not stored
+func (s *Store) GetEpoch(ctx context.Context, number uint64) (models.Epoch, error) {
+	page, err := s.GetEpochs(ctx, models.RangeQuery{From: number, To: number, Limit: 1})
+	if err != nil {
+		return models.Epoch{}, err
+	}
+	if len(page.Epochs) == 0 || page.Epochs[0].EpochNumber != number {
+		return models.Epoch{}, ErrNotFound
+	}
+	return page.Epochs[0], nil
+}
+
+// looks up a page of epochs with their slots & blocks, the latest N epochs are the first page of a Descending
+// query limited to N
+func (s *Store) GetEpochs(ctx context.Context, query models.RangeQuery) (models.EpochPage, error) {
+	page := models.EpochPage{Epochs: []models.Epoch{}}
+	bldr, err := inRange(s.builder.Select(epochJSON).From("epochs"), "epoch_number", query)
+	if err != nil {
+		return page, err
+	}
+	err = s.selectJSON(ctx, "epochs", bldr, func(raw []byte) error {
+		epoch := models.Epoch{}
+		err := json.Unmarshal(raw, &epoch)
+		epoch.UTC()
+		page.Epochs = append(page.Epochs, epoch)
+		return err
+	})
+	if err != nil {
+		return page, err
+	}
+	if query.Limit > 0 && uint64(len(page.Epochs)) > query.Limit {
+		page.Epochs = page.Epochs[:query.Limit]
+		page.Next = encodeCursor(page.Epochs[len(page.Epochs)-1].EpochNumber)
+	}
+	return page, nil
+}
+
+// looks up a slot with its blocks, ErrNotFound if it is not stored
+func (s *Store) GetSlot(ctx context.Context, number uint64) (models.Slot, error) {
+	page, err := s.GetSlots(ctx, models.RangeQuery{From: number, To: number, Limit: 1})
+	if err != nil {
+		return models.Slot{}, err
+	}
+	if len(page.Slots) == 0 || page.Slots[0].SlotNumber != number {
+		return models.Slot{}, ErrNotFound
+	}
+	return page.Slots[0], nil
+}
+
+// looks up a page of slots with their blocks
+func (s *Store) GetSlots(ctx context.Context, query models.RangeQuery) (models.SlotPage, error) {
+	page := models.SlotPage{Slots: []models.Slot{}}
+	bldr, err := inRange(s.builder.Select(slotJSON).From("slots"), "slot_number", query)
+	if err != nil {
+		return page, err
+	}
+	err = s.selectJSON(ctx, "slots", bldr, func(raw []byte) error {
+		slot := models.Slot{}
+		err := json.Unmarshal(raw, &slot)
+		slot.UTC()
+		page.Slots = append(page.Slots, slot)
+		return err
+	})
+	if err != nil {
+		return page, err
+	}
+	if query.Limit > 0 && uint64(len(page.Slots)) > query.Limit {
+		page.Slots = page.Slots[:query.Limit]
+		page.Next = encodeCursor(page.Slots[len(page.Slots)-1].SlotNumber)
+	}
+	return page, nil
+}
+
+// looks up a block by its root, whether canonical or not, ErrNotFound if it is not stored
+func (s *Store) GetBlock(ctx context.Context, root string) (models.Block, error) {
+	b, err := models.DecodeHex(root)
+	if err != nil {
+		// never stored
+		return models.Block{}, ErrNotFound
+	}
+	return s.selectBlock(ctx, squirrel.Eq{"block_root": b})
+}
+
+// looks up the canonical block of an execution block number, ErrNotFound if it is not stored
+func (s *Store) GetBlockByNumber(ctx context.Context, number uint64) (models.Block, error) {
+	return s.selectBlock(ctx, squirrel.Eq{"block_number": number, "canonical": true})
+}
+
+func (s *Store) selectBlock(ctx context.Context, where squirrel.Sqlizer) (models.Block, error) {
+	bldr := s.builder.Select(blockJSON).From("blocks").Where(where).OrderBy("slot_number").Limit(1)
+	var blocks []models.Block
+	err := s.selectJSON(ctx, "blocks", bldr, func(raw []byte) error {
+		block := models.Block{}
+		err := json.Unmarshal(raw, &block)
+		block.UTC()
+		blocks = append(blocks, block)
+		return err
+	})
+	if err != nil {
+		return models.Block{}, err
+	}
+	if len(blocks) == 0 {
+		return models.Block{}, ErrNotFound
+	}
+	return blocks[0], nil
+}
+
+// epochs, slots & blocks are assembled by postgres, every row is a single JSON document shaped like the models,
+// so that reading N epochs takes a single round trip & no joining in memory. Roots & hashes are rendered by hex_of,
+// times with their offset, as time.Time expects. {b} & {s} stand for the aliases of the blocks & slots tables,
+// {block} & {slot} for the nested documents
+const (
+	receiptTmpl = `json_build_object(
+		'transactionHash', hex_of(r.transaction_hash), 'transactionIndex', r.transaction_index, 'blockNumber', r.block_number,
+		'blockRoot', hex_of(r.block_root), 'status', r.status, 'gasUsed', r.gas_used, 'effectiveGasPrice', r.effective_gas_price,
+		'logCount', r.log_count)`
+	blockTmpl = `json_build_object(
+		'blockNumber', {b}.block_number, 'blockRoot', hex_of({b}.block_root), 'parentRoot', hex_of({b}.parent_root),
+		'canonical', {b}.canonical, 'stateRoot', hex_of({b}.state_root), 'slotNumber', {b}.slot_number,
+		'gasLimit', {b}.gas_limit, 'gasUsed', {b}.gas_used, 'noOfTransactions', {b}.no_of_transactions,
+		'created_at', {b}.created_at, 'version', {b}.version, 'feeRecipient', {b}.fee_recipient,
+		'extraData', {b}.extra_data, 'builder', {b}.builder, 'mevBoost', {b}.mev_boost, 'revision', {b}.revision,
+		'receipts', (SELECT json_agg(` + receiptTmpl + ` ORDER BY r.transaction_index) FROM receipts r WHERE r.block_root = {b}.block_root))`
+	slotTmpl = `json_build_object(
+		'slotNumber', {s}.slot_number, 'startTime', {s}.start_time, 'endTime', {s}.end_time,
+		'epochNumber', {s}.epoch_number, 'revision', {s}.revision,
+		'block', (SELECT {block} FROM blocks b WHERE b.slot_number = {s}.slot_number AND b.canonical LIMIT 1),
+		'candidates', (SELECT json_agg({block} ORDER BY b.block_root) FROM blocks b WHERE b.slot_number = {s}.slot_number AND NOT b.canonical))`
+	epochTmpl = `json_build_object(
+		'epochNumber', epochs.epoch_number, 'startTime', epochs.start_time, 'endTime', epochs.end_time, 'revision', epochs.revision,
+		'slots', COALESCE((SELECT json_agg({slot} ORDER BY s.slot_number) FROM slots s WHERE s.epoch_number = epochs.epoch_number), '[]'))`
+)
+
+var (
+	blockJSON = strings.ReplaceAll(blockTmpl, "{b}", "blocks")
+	slotJSON  = strings.NewReplacer("{s}", "slots", "{block}", strings.ReplaceAll(blockTmpl, "{b}", "b")).Replace(slotTmpl)
+	epochJSON = strings.ReplaceAll(epochTmpl, "{slot}",
+		strings.NewReplacer("{s}", "s", "{block}", strings.ReplaceAll(blockTmpl, "{b}", "b")).Replace(slotTmpl))
+)
+
+// runs a select of JSON documents & hands every one of them to each, in order
+func (s *Store) selectJSON(ctx context.Context, table string, bldr squirrel.SelectBuilder, each func([]byte) error) error {
+	qry, args, err := bldr.ToSql()
+	if err != nil {
+		return fmt.Errorf("%s select query prep failed, err: %v", table, err.Error())
+	}
+	rows, err := s.pool.Query(ctx, qry, args...)
+	if err != nil {
+		return fmt.Errorf("%s select query failed, err: %v", table, err.Error())
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var raw []byte
+		err = rows.Scan(&raw)
+		if err != nil {
+			return fmt.Errorf("%s select query scan failed, err: %v", table, err.Error())
+		}
+		err = each(raw)
+		if err != nil {
+			return fmt.Errorf("%s select query decoding failed, err: %v", table, err.Error())
+		}
+	}
+	if rows.Err() != nil {
+		return fmt.Errorf("%s select query failed, err: %v", table, rows.Err().Error())
+	}
+	return nil
+}
+
+// narrows down a select of epochs or slots to the query's range & page, one row more than the limit is selected
+// to tell whether another page follows
+func inRange(bldr squirrel.SelectBuilder, column string, query models.RangeQuery) (squirrel.SelectBuilder, error) {
+	if query.From > 0 {
+		bldr = bldr.Where(squirrel.GtOrEq{column: query.From})
+	}
+	if query.To > 0 {
+		bldr = bldr.Where(squirrel.LtOrEq{column: query.To})
+	}
+	if !query.FromTime.IsZero() {
+		bldr = bldr.Where(squirrel.GtOrEq{"start_time": query.FromTime})
+	}
+	if !query.ToTime.IsZero() {
+		bldr = bldr.Where(squirrel.LtOrEq{"start_time": query.ToTime})
+	}
+	if query.Cursor != "" {
+		after, err := decodeCursor(query.Cursor)
+		if err != nil {
+			return bldr, err
+		}
+		if query.Descending {
+			bldr = bldr.Where(squirrel.Lt{column: after})
+		} else {
+			bldr = bldr.Where(squirrel.Gt{column: after})
+		}
+	}
+	if query.Descending {
+		bldr = bldr.OrderBy(column + " DESC")
+	} else {
+		bldr = bldr.OrderBy(column)
+	}
+	if query.Limit > 0 {
+		bldr = bldr.Limit(query.Limit + 1)
+	}
+	return bldr, nil
+}
+
+// cursors are opaque to clients, they encode the number of the last epoch or slot of a page
+func encodeCursor(last uint64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatUint(last, 10)))
+}
+
+func decodeCursor(cursor string) (uint64, error) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, ErrInvalidCursor
+	}
+	last, err := strconv.ParseUint(string(b), 10, 64)
+	if err != nil {
+		return 0, ErrInvalidCursor
+	}
+	return last, nil
+}
@@ -0,0 +1,68 @@
+package store
+
+import (
+	"indexer/pkg/models"
+	"testing"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_cursor(t *testing.T) {
+	last, err := decodeCursor(encodeCursor(270000))
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(270000), last)
+
+	_, err = decodeCursor("not a cursor")
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+	_, err = decodeCursor(encodeCursor(1) + "x")
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+}
+
+func Test_inRange(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    models.RangeQuery
+		wantSql  string
+		wantArgs []interface{}
+		wantErr  bool
+	}{
+		{
+			name:    "everything",
+			query:   models.RangeQuery{},
+			wantSql: "SELECT * FROM epochs ORDER BY epoch_number",
+		},
+		{
+			name:     "range, ascending",
+			query:    models.RangeQuery{From: 10, To: 20, Limit: 5},
+			wantSql:  "SELECT * FROM epochs WHERE epoch_number >= $1 AND epoch_number <= $2 ORDER BY epoch_number LIMIT 6",
+			wantArgs: []interface{}{uint64(10), uint64(20)},
+		},
+		{
+			name:     "next page of the latest epochs",
+			query:    models.RangeQuery{Descending: true, Limit: 5, Cursor: encodeCursor(100)},
+			wantSql:  "SELECT * FROM epochs WHERE epoch_number < $1 ORDER BY epoch_number DESC LIMIT 6",
+			wantArgs: []interface{}{uint64(100)},
+		},
+		{
+			name:    "invalid cursor",
+			query:   models.RangeQuery{Cursor: "%"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bldr := squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar).Select("*").From("epochs")
+			bldr, err := inRange(bldr, "epoch_number", tt.query)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			qry, args, err := bldr.ToSql()
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantSql, qry)
+			assert.Equal(t, tt.wantArgs, args)
+		})
+	}
+}
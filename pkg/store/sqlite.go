@@ -0,0 +1,657 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"indexer/pkg/models"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/georgysavva/scany/sqlscan"
+)
+
+// SQLite implements Repository on an embedded SQLite database, for local development & small deployments, its
+// schema is migrated by db.MigrateSQLite
+type SQLite struct {
+	db      *sql.DB
+	builder squirrel.StatementBuilderType
+}
+
+// SQLite implements Repository
+var _ Repository = &SQLite{}
+
+func NewSQLite(db *sql.DB) *SQLite {
+	return &SQLite{
+		db:      db,
+		builder: squirrel.StatementBuilder.PlaceholderFormat(squirrel.Question),
+	}
+}
+
+// writes an epoch like Store.Create does
+func (s *SQLite) Create(ctx context.Context, e models.Epoch, policy models.ConflictPolicy) (models.WriteResult, error) {
+	result := models.WriteResult{}
+	policy, err := models.ParseConflictPolicy(string(policy))
+	if err != nil {
+		return result, err
+	}
+	// roots are stored as text, they are checked as Store decodes them so that both refuse the same epochs
+	_, err = bytesOf([]models.Epoch{e})
+	if err != nil {
+		return result, err
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return result, err
+	}
+	success := false
+	defer func() {
+		if success {
+			tx.Commit()
+
+		} else {
+			tx.Rollback()
+		}
+	}()
+
+	rows := stage([]models.Epoch{e})
+	for _, table := range bulkTables {
+		written, err := s.upsert(ctx, tx, table.name, table.columns, rows[table.name], policy, table.revisioned)
+		if err != nil {
+			return result, err
+		}
+		if table.counted {
+			result = sum(result, written)
+		}
+	}
+
+	// reorgs may reach back into epochs stored earlier
+	if head, ok := headOf([]models.Epoch{e}); ok {
+		var newer bool
+		err = tx.QueryRowContext(ctx, toSQLite(newerCanonicalQuery), head.SlotNumber).Scan(&newer)
+		if err != nil {
+			return result, fmt.Errorf("blocks head query failed, err: %v", err.Error())
+		}
+		if !newer {
+			_, err = tx.ExecContext(ctx, toSQLite(canonicalizeQuery), head.BlockRoot, floorOf(head.SlotNumber), head.SlotNumber)
+			if err != nil {
+				return result, fmt.Errorf("blocks canonical update query failed, err: %v", err.Error())
+			}
+		}
+	}
+
+	success = true
+	return result, nil
+}
+
+// follows parent roots from head as Create does, even when a newer canonical block is stored, see Store.Recanonicalize
+func (s *SQLite) Recanonicalize(ctx context.Context, head models.Block) error {
+	_, err := models.DecodeHex(head.BlockRoot)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, toSQLite(canonicalizeQuery), head.BlockRoot, floorOf(head.SlotNumber), head.SlotNumber)
+	if err != nil {
+		return fmt.Errorf("blocks canonical update query failed, err: %v", err.Error())
+	}
+	return nil
+}
+
+// marks blocks as no longer canonical, see Store.Demote
+func (s *SQLite) Demote(ctx context.Context, roots []string) error {
+	for _, root := range roots {
+		_, err := models.DecodeHex(root)
+		if err != nil {
+			return err
+		}
+	}
+	qry, args, err := s.builder.Update("blocks").Set("canonical", false).Where(squirrel.Eq{"block_root": roots}).ToSql()
+	if err != nil {
+		return fmt.Errorf("blocks canonical update query prep failed, err: %v", err.Error())
+	}
+	_, err = s.db.ExecContext(ctx, qry, args...)
+	if err != nil {
+		return fmt.Errorf("blocks canonical update query failed, err: %v", err.Error())
+	}
+	return nil
+}
+
+// writes rows one at a time, unlike postgres SQLite cannot tell inserted from updated rows of a single statement
+func (s *SQLite) upsert(ctx context.Context, tx *sql.Tx, table string, columns []string, rows [][]interface{}, policy models.ConflictPolicy, revisioned bool) (models.WriteResult, error) {
+	result := models.WriteResult{}
+	target := upsertColumns[table].target
+	update := upsertColumns[table].update
+	for _, row := range rows {
+		values := make(map[string]interface{}, len(columns))
+		for idx, column := range columns {
+			values[column] = toSQLiteValue(row[idx])
+			row[idx] = values[column]
+		}
+		qry, args, err := s.builder.Insert(table).Columns(columns...).Values(row...).
+			Suffix(fmt.Sprintf("ON CONFLICT (%s) DO NOTHING", strings.Join(target, ", "))).ToSql()
+		if err != nil {
+			return result, fmt.Errorf("%s insert query prep failed, err: %v", table, err.Error())
+		}
+		res, err := tx.ExecContext(ctx, qry, args...)
+		if err != nil {
+			return result, fmt.Errorf("%s insert query failed, err: %v", table, err.Error())
+		}
+		if n, _ := res.RowsAffected(); n == 1 {
+			result.Inserted++
+			continue
+		}
+		if policy == models.ConflictSkip {
+			result.Skipped++
+			continue
+		}
+
+		bldr := s.builder.Update(table)
+		for _, column := range target {
+			bldr = bldr.Where(squirrel.Eq{column: values[column]})
+		}
+		changed := squirrel.Or{}
+		for _, column := range update {
+			bldr = bldr.Set(column, values[column])
+			changed = append(changed, squirrel.Expr(column+" IS NOT ?", values[column]))
+		}
+		if policy == models.ConflictVersion {
+			bldr = bldr.Where(changed)
+			if revisioned {
+				bldr = bldr.Set("revision", squirrel.Expr("revision + 1"))
+			}
+		}
+		qry, args, err = bldr.ToSql()
+		if err != nil {
+			return result, fmt.Errorf("%s update query prep failed, err: %v", table, err.Error())
+		}
+		res, err = tx.ExecContext(ctx, qry, args...)
+		if err != nil {
+			return result, fmt.Errorf("%s update query failed, err: %v", table, err.Error())
+		}
+		if n, _ := res.RowsAffected(); n == 1 {
+			result.Updated++
+		} else {
+			result.Skipped++
+		}
+	}
+	return result, nil
+}
+
+// SQLite has no JSON column type, contract event arguments are stored as JSON text. Timestamps are stored as text
+// & compared as such, so they are all kept in UTC
+func toSQLiteValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		b, _ := json.Marshal(v)
+		return string(b)
+	case time.Time:
+		return v.UTC()
+	}
+	return v
+}
+
+// SQLite numbers parameters as ?N rather than $N
+func toSQLite(qry string) string {
+	return strings.ReplaceAll(qry, "$", "?")
+}
+
+func (s *SQLite) Get(ctx context.Context) ([]models.Epoch, error) {
+	page, err := s.GetEpochs(ctx, models.RangeQuery{})
+	if err != nil {
+		return nil, err
+	}
+	return page.Epochs, nil
+}
+
+func (s *SQLite) GetEpoch(ctx context.Context, number uint64) (models.Epoch, error) {
+	page, err := s.GetEpochs(ctx, models.RangeQuery{From: number, To: number, Limit: 1})
+	if err != nil {
+		return models.Epoch{}, err
+	}
+	if len(page.Epochs) == 0 || page.Epochs[0].EpochNumber != number {
+		return models.Epoch{}, ErrNotFound
+	}
+	return page.Epochs[0], nil
+}
+
+func (s *SQLite) GetEpochs(ctx context.Context, query models.RangeQuery) (models.EpochPage, error) {
+	page := models.EpochPage{Epochs: []models.Epoch{}}
+	query.FromTime, query.ToTime = query.FromTime.UTC(), query.ToTime.UTC()
+	bldr, err := inRange(s.builder.Select("*").From("epochs"), "epoch_number", query)
+	if err != nil {
+		return page, err
+	}
+	qry, args, err := bldr.ToSql()
+	if err != nil {
+		return page, fmt.Errorf("epochs select query prep failed, err: %v", err.Error())
+	}
+	err = sqlscan.Select(ctx, s.db, &page.Epochs, qry, args...)
+	if err != nil {
+		return page, fmt.Errorf("epochs select query failed, err: %v", err.Error())
+	}
+	if query.Limit > 0 && uint64(len(page.Epochs)) > query.Limit {
+		page.Epochs = page.Epochs[:query.Limit]
+		page.Next = encodeCursor(page.Epochs[len(page.Epochs)-1].EpochNumber)
+	}
+	if len(page.Epochs) == 0 {
+		return page, nil
+	}
+
+	numbers := make([]uint64, 0, len(page.Epochs))
+	for _, epoch := range page.Epochs {
+		numbers = append(numbers, epoch.EpochNumber)
+	}
+	slots, err := s.selectSlots(ctx, squirrel.Eq{"epoch_number": numbers}, false)
+	if err != nil {
+		return page, err
+	}
+	slotsByEpoch := make(map[uint64][]models.Slot)
+	for _, slot := range slots {
+		slotsByEpoch[slot.EpochNumber] = append(slotsByEpoch[slot.EpochNumber], slot)
+	}
+	for idx := range page.Epochs {
+		page.Epochs[idx].Slots = slotsByEpoch[page.Epochs[idx].EpochNumber]
+	}
+	return page, nil
+}
+
+func (s *SQLite) GetSlot(ctx context.Context, number uint64) (models.Slot, error) {
+	slots, err := s.selectSlots(ctx, squirrel.Eq{"slot_number": number}, false)
+	if err != nil {
+		return models.Slot{}, err
+	}
+	if len(slots) == 0 {
+		return models.Slot{}, ErrNotFound
+	}
+	return slots[0], nil
+}
+
+func (s *SQLite) GetSlots(ctx context.Context, query models.RangeQuery) (models.SlotPage, error) {
+	page := models.SlotPage{Slots: []models.Slot{}}
+	query.FromTime, query.ToTime = query.FromTime.UTC(), query.ToTime.UTC()
+	bldr, err := inRange(s.builder.Select("slot_number").From("slots"), "slot_number", query)
+	if err != nil {
+		return page, err
+	}
+	qry, args, err := bldr.ToSql()
+	if err != nil {
+		return page, fmt.Errorf("slots select query prep failed, err: %v", err.Error())
+	}
+	var numbers []uint64
+	err = sqlscan.Select(ctx, s.db, &numbers, qry, args...)
+	if err != nil {
+		return page, fmt.Errorf("slots select query failed, err: %v", err.Error())
+	}
+	if query.Limit > 0 && uint64(len(numbers)) > query.Limit {
+		numbers = numbers[:query.Limit]
+		page.Next = encodeCursor(numbers[len(numbers)-1])
+	}
+	if len(numbers) == 0 {
+		return page, nil
+	}
+	page.Slots, err = s.selectSlots(ctx, squirrel.Eq{"slot_number": numbers}, query.Descending)
+	return page, err
+}
+
+func (s *SQLite) GetBlock(ctx context.Context, root string) (models.Block, error) {
+	return s.selectBlock(ctx, squirrel.Eq{"block_root": root})
+}
+
+func (s *SQLite) GetBlockByNumber(ctx context.Context, number uint64) (models.Block, error) {
+	return s.selectBlock(ctx, squirrel.Eq{"block_number": number, "canonical": true})
+}
+
+func (s *SQLite) selectBlock(ctx context.Context, where squirrel.Sqlizer) (models.Block, error) {
+	qry, args, err := s.builder.Select("*").From("blocks").Where(where).OrderBy("slot_number").Limit(1).ToSql()
+	if err != nil {
+		return models.Block{}, fmt.Errorf("blocks select query prep failed, err: %v", err.Error())
+	}
+	var blocks []models.Block
+	err = sqlscan.Select(ctx, s.db, &blocks, qry, args...)
+	if err != nil {
+		return models.Block{}, fmt.Errorf("blocks select query failed, err: %v", err.Error())
+	}
+	if len(blocks) == 0 {
+		return models.Block{}, ErrNotFound
+	}
+	receipts, err := s.selectReceipts(ctx, []string{blocks[0].BlockRoot})
+	if err != nil {
+		return models.Block{}, err
+	}
+	blocks[0].Receipts = receipts[blocks[0].BlockRoot]
+	return blocks[0], nil
+}
+
+// selects the matching slots ordered by number, along with their blocks & receipts
+func (s *SQLite) selectSlots(ctx context.Context, where squirrel.Sqlizer, descending bool) ([]models.Slot, error) {
+	order := "slot_number"
+	if descending {
+		order += " DESC"
+	}
+	qry, args, err := s.builder.Select("*").From("slots").Where(where).OrderBy(order).ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("slots select query prep failed, err: %v", err.Error())
+	}
+	var slots []models.Slot
+	err = sqlscan.Select(ctx, s.db, &slots, qry, args...)
+	if err != nil {
+		return nil, fmt.Errorf("slots select query failed, err: %v", err.Error())
+	}
+	if len(slots) == 0 {
+		return slots, nil
+	}
+
+	numbers := make([]uint64, 0, len(slots))
+	for _, slot := range slots {
+		numbers = append(numbers, slot.SlotNumber)
+	}
+	qry, args, err = s.builder.Select("*").From("blocks").Where(squirrel.Eq{"slot_number": numbers}).
+		OrderBy("slot_number", "block_root").ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("blocks select query prep failed, err: %v", err.Error())
+	}
+	var blocks []models.Block
+	err = sqlscan.Select(ctx, s.db, &blocks, qry, args...)
+	if err != nil {
+		return nil, fmt.Errorf("blocks select query failed, err: %v", err.Error())
+	}
+	roots := make([]string, 0, len(blocks))
+	for _, block := range blocks {
+		roots = append(roots, block.BlockRoot)
+	}
+	receipts, err := s.selectReceipts(ctx, roots)
+	if err != nil {
+		return nil, err
+	}
+
+	idxBySlot := make(map[uint64]int, len(slots))
+	for idx, slot := range slots {
+		idxBySlot[slot.SlotNumber] = idx
+	}
+	for _, block := range blocks {
+		idx := idxBySlot[block.SlotNumber]
+		block.Receipts = receipts[block.BlockRoot]
+		if block.Canonical {
+			slots[idx].Block = block
+		} else {
+			slots[idx].Candidates = append(slots[idx].Candidates, block)
+		}
+	}
+	return slots, nil
+}
+
+// selects the receipts of the given blocks, keyed by block root
+func (s *SQLite) selectReceipts(ctx context.Context, roots []string) (map[string][]models.Receipt, error) {
+	receiptsByBlock := make(map[string][]models.Receipt)
+	if len(roots) == 0 {
+		return receiptsByBlock, nil
+	}
+	qry, args, err := s.builder.Select("*").From("receipts").Where(squirrel.Eq{"block_root": roots}).
+		OrderBy("block_root", "transaction_index").ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("receipts select query prep failed, err: %v", err.Error())
+	}
+	var receipts []models.Receipt
+	err = sqlscan.Select(ctx, s.db, &receipts, qry, args...)
+	if err != nil {
+		return nil, fmt.Errorf("receipts select query failed, err: %v", err.Error())
+	}
+	for _, receipt := range receipts {
+		receiptsByBlock[receipt.BlockRoot] = append(receiptsByBlock[receipt.BlockRoot], receipt)
+	}
+	return receiptsByBlock, nil
+}
+
+func (s *SQLite) GetEpochStats(ctx context.Context, limit uint64) ([]models.EpochStats, error) {
+	bldr := s.builder.Select("*").From("epoch_stats").OrderBy("epoch_number DESC")
+	if limit > 0 {
+		bldr = bldr.Limit(limit)
+	}
+	qry, args, err := bldr.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("epoch_stats select query prep failed, err: %v", err.Error())
+	}
+	stats := []models.EpochStats{}
+	err = sqlscan.Select(ctx, s.db, &stats, qry, args...)
+	if err != nil {
+		return nil, fmt.Errorf("epoch_stats select query failed, err: %v", err.Error())
+	}
+	return stats, nil
+}
+
+func (s *SQLite) GetEpochStatsBetween(ctx context.Context, fromEpoch uint64, toEpoch uint64) ([]models.EpochStats, error) {
+	qry, args, err := s.builder.Select("*").From("epoch_stats").
+		Where(squirrel.GtOrEq{"epoch_number": fromEpoch}).
+		Where(squirrel.LtOrEq{"epoch_number": toEpoch}).
+		OrderBy("epoch_number").
+		ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("epoch_stats select query prep failed, err: %v", err.Error())
+	}
+	stats := []models.EpochStats{}
+	err = sqlscan.Select(ctx, s.db, &stats, qry, args...)
+	if err != nil {
+		return nil, fmt.Errorf("epoch_stats select query failed, err: %v", err.Error())
+	}
+	return stats, nil
+}
+
+func (s *SQLite) GetContractEvents(ctx context.Context, filter models.EventFilter) ([]models.ContractEvent, error) {
+	bldr := s.builder.Select("block_number", "block_root", "transaction_hash", "log_index", "address", "contract", "event",
+		"signature", "args").From("contract_events").OrderBy("block_number", "log_index")
+	if filter.Contract != "" {
+		bldr = bldr.Where(squirrel.Eq{"contract": filter.Contract})
+	}
+	if filter.Address != "" {
+		bldr = bldr.Where(squirrel.Eq{"address": strings.ToLower(filter.Address)})
+	}
+	if filter.Event != "" {
+		bldr = bldr.Where(squirrel.Eq{"event": filter.Event})
+	}
+	if filter.FromBlock > 0 {
+		bldr = bldr.Where(squirrel.GtOrEq{"block_number": filter.FromBlock})
+	}
+	if filter.ToBlock > 0 {
+		bldr = bldr.Where(squirrel.LtOrEq{"block_number": filter.ToBlock})
+	}
+	if filter.Limit > 0 {
+		bldr = bldr.Limit(filter.Limit)
+	}
+	qry, args, err := bldr.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("contract_events select query prep failed, err: %v", err.Error())
+	}
+	rows, err := s.db.QueryContext(ctx, qry, args...)
+	if err != nil {
+		return nil, fmt.Errorf("contract_events select query failed, err: %v", err.Error())
+	}
+	defer rows.Close()
+	events := []models.ContractEvent{}
+	for rows.Next() {
+		var ev models.ContractEvent
+		var eventArgs string
+		err = rows.Scan(&ev.BlockNumber, &ev.BlockRoot, &ev.TransactionHash, &ev.LogIndex, &ev.Address, &ev.Contract,
+			&ev.Event, &ev.Signature, &eventArgs)
+		if err != nil {
+			return nil, fmt.Errorf("contract_events select query scan failed, err: %v", err.Error())
+		}
+		err = json.Unmarshal([]byte(eventArgs), &ev.Args)
+		if err != nil {
+			return nil, fmt.Errorf("contract_events args decoding failed, err: %v", err.Error())
+		}
+		events = append(events, ev)
+	}
+	if rows.Err() != nil {
+		return nil, fmt.Errorf("contract_events select query failed, err: %v", rows.Err().Error())
+	}
+	return events, nil
+}
+
+func (s *SQLite) GetBuilderShare(ctx context.Context, fromEpoch uint64, toEpoch uint64) ([]models.BuilderShare, error) {
+	bldr := s.builder.Select("slots.epoch_number", "blocks.builder", "COUNT(*) AS blocks",
+		"CAST(COUNT(*) AS REAL) / SUM(COUNT(*)) OVER (PARTITION BY slots.epoch_number) AS share").
+		From("blocks").
+		Join("slots ON slots.slot_number = blocks.slot_number").
+		Where(squirrel.Eq{"blocks.canonical": true}).
+		Where(squirrel.NotEq{"blocks.builder": ""}).
+		GroupBy("slots.epoch_number", "blocks.builder").
+		OrderBy("slots.epoch_number DESC", "blocks DESC", "blocks.builder")
+	if fromEpoch > 0 {
+		bldr = bldr.Where(squirrel.GtOrEq{"slots.epoch_number": fromEpoch})
+	}
+	if toEpoch > 0 {
+		bldr = bldr.Where(squirrel.LtOrEq{"slots.epoch_number": toEpoch})
+	}
+	qry, args, err := bldr.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("builder share select query prep failed, err: %v", err.Error())
+	}
+	shares := []models.BuilderShare{}
+	err = sqlscan.Select(ctx, s.db, &shares, qry, args...)
+	if err != nil {
+		return nil, fmt.Errorf("builder share select query failed, err: %v", err.Error())
+	}
+	return shares, nil
+}
+
+func (s *SQLite) SaveForkSchedule(ctx context.Context, forks []models.Fork) error {
+	if len(forks) == 0 {
+		return nil
+	}
+	bldr := s.builder.Insert("fork_schedule").Columns("name", "previous_version", "current_version", "epoch")
+	for _, f := range forks {
+		bldr = bldr.Values(f.Name, f.PreviousVersion, f.CurrentVersion, f.Epoch)
+	}
+	qry, args, err := bldr.Suffix(`ON CONFLICT (name) DO UPDATE SET
+		previous_version = excluded.previous_version, current_version = excluded.current_version, epoch = excluded.epoch`).
+		ToSql()
+	if err != nil {
+		return fmt.Errorf("fork_schedule insert query prep failed, err: %v", err.Error())
+	}
+	_, err = s.db.ExecContext(ctx, qry, args...)
+	if err != nil {
+		return fmt.Errorf("fork_schedule insert query failed, err: %v", err.Error())
+	}
+	return nil
+}
+
+func (s *SQLite) GetForkSchedule(ctx context.Context) ([]models.Fork, error) {
+	qry, args, err := s.builder.Select("*").From("fork_schedule").OrderBy("epoch", "current_version").ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("fork_schedule select query prep failed, err: %v", err.Error())
+	}
+	forks := []models.Fork{}
+	err = sqlscan.Select(ctx, s.db, &forks, qry, args...)
+	if err != nil {
+		return nil, fmt.Errorf("fork_schedule select query failed, err: %v", err.Error())
+	}
+	return forks, nil
+}
+
+func (s *SQLite) WatchValidator(ctx context.Context, validator string) error {
+	qry, args, err := s.builder.Insert("watched_validators").
+		Columns("validator", "created_at").
+		Values(validator, time.Now().UTC()).
+		Suffix("ON CONFLICT (validator) DO NOTHING").
+		ToSql()
+	if err != nil {
+		return fmt.Errorf("watched_validators insert query prep failed, err: %v", err.Error())
+	}
+	_, err = s.db.ExecContext(ctx, qry, args...)
+	if err != nil {
+		return fmt.Errorf("watched_validators insert query failed, err: %v", err.Error())
+	}
+	return nil
+}
+
+func (s *SQLite) UnwatchValidator(ctx context.Context, validator string) error {
+	qry, args, err := s.builder.Delete("watched_validators").
+		Where(squirrel.Eq{"validator": validator}).
+		ToSql()
+	if err != nil {
+		return fmt.Errorf("watched_validators delete query prep failed, err: %v", err.Error())
+	}
+	_, err = s.db.ExecContext(ctx, qry, args...)
+	if err != nil {
+		return fmt.Errorf("watched_validators delete query failed, err: %v", err.Error())
+	}
+	return nil
+}
+
+func (s *SQLite) GetWatchedValidators(ctx context.Context) ([]string, error) {
+	qry, args, err := s.builder.Select("validator").From("watched_validators").OrderBy("created_at", "rowid").ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("watched_validators select query prep failed, err: %v", err.Error())
+	}
+	validators := []string{}
+	err = sqlscan.Select(ctx, s.db, &validators, qry, args...)
+	if err != nil {
+		return nil, fmt.Errorf("watched_validators select query failed, err: %v", err.Error())
+	}
+	return validators, nil
+}
+
+func (s *SQLite) CreateValidatorActivity(ctx context.Context, activity []models.ValidatorActivity) error {
+	if len(activity) == 0 {
+		return nil
+	}
+	bldr := s.builder.Insert("validator_activity").
+		Columns("validator_index", "epoch_number", "proposer_duties", "proposed", "attester_duties", "attestations_included",
+			"inclusion_delay", "sync_duties", "sync_participated", "balance", "reward")
+	for _, a := range activity {
+		bldr = bldr.Values(a.ValidatorIndex, a.EpochNumber, a.ProposerDuties, a.Proposed, a.AttesterDuties, a.AttestationsIncluded,
+			a.InclusionDelay, a.SyncDuties, a.SyncParticipated, a.Balance, a.Reward)
+	}
+	qry, args, err := bldr.Suffix(`ON CONFLICT (validator_index, epoch_number) DO UPDATE SET
+		proposer_duties = excluded.proposer_duties, proposed = excluded.proposed,
+		attester_duties = excluded.attester_duties, attestations_included = excluded.attestations_included,
+		inclusion_delay = excluded.inclusion_delay, sync_duties = excluded.sync_duties,
+		sync_participated = excluded.sync_participated, balance = excluded.balance, reward = excluded.reward`).
+		ToSql()
+	if err != nil {
+		return fmt.Errorf("validator_activity insert query prep failed, err: %v", err.Error())
+	}
+	_, err = s.db.ExecContext(ctx, qry, args...)
+	if err != nil {
+		return fmt.Errorf("validator_activity insert query failed, err: %v", err.Error())
+	}
+	return nil
+}
+
+func (s *SQLite) GetValidatorHistory(ctx context.Context, validatorIndex uint64, limit uint64) ([]models.ValidatorActivity, error) {
+	bldr := s.builder.Select("*").From("validator_activity").
+		Where(squirrel.Eq{"validator_index": validatorIndex}).
+		OrderBy("epoch_number DESC")
+	if limit > 0 {
+		bldr = bldr.Limit(limit)
+	}
+	qry, args, err := bldr.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("validator_activity select query prep failed, err: %v", err.Error())
+	}
+	activity := []models.ValidatorActivity{}
+	err = sqlscan.Select(ctx, s.db, &activity, qry, args...)
+	if err != nil {
+		return nil, fmt.Errorf("validator_activity select query failed, err: %v", err.Error())
+	}
+	return activity, nil
+}
+
+func (s *SQLite) KeepOnlyTop5(ctx context.Context, epochNumber uint64) error {
+	if epochNumber < 5 {
+		return nil
+	}
+	qry, args, err := s.builder.Delete("epochs").
+		Where(squirrel.LtOrEq{"epoch_number": epochNumber - 5}).
+		ToSql()
+	if err != nil {
+		return fmt.Errorf("epochs delete query prep failed, err: %v", err.Error())
+	}
+	_, err = s.db.ExecContext(ctx, qry, args...)
+	if err != nil {
+		return fmt.Errorf("epochs delete query failed, err: %v", err.Error())
+	}
+	return nil
+}
@@ -3,22 +3,46 @@ package store
 import (
 	"context"
 	"fmt"
+	"indexer/pkg/db"
 	"indexer/pkg/models"
+	"strings"
 
 	"github.com/Masterminds/squirrel"
 	"github.com/georgysavva/scany/pgxscan"
+	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
 )
 
 type Repository interface {
-	Create(context.Context, models.Epoch) error
+	Create(context.Context, models.Epoch, models.ConflictPolicy) (models.WriteResult, error)
 	Get(context.Context) ([]models.Epoch, error)
+	GetEpoch(context.Context, uint64) (models.Epoch, error)
+	GetEpochs(context.Context, models.RangeQuery) (models.EpochPage, error)
+	GetSlot(context.Context, uint64) (models.Slot, error)
+	GetSlots(context.Context, models.RangeQuery) (models.SlotPage, error)
+	GetBlock(context.Context, string) (models.Block, error)
+	GetBlockByNumber(context.Context, uint64) (models.Block, error)
+	GetEpochStats(context.Context, uint64) ([]models.EpochStats, error)
+	GetEpochStatsBetween(context.Context, uint64, uint64) ([]models.EpochStats, error)
+	GetContractEvents(context.Context, models.EventFilter) ([]models.ContractEvent, error)
+	GetBuilderShare(context.Context, uint64, uint64) ([]models.BuilderShare, error)
+	SaveForkSchedule(context.Context, []models.Fork) error
+	GetForkSchedule(context.Context) ([]models.Fork, error)
+	WatchValidator(context.Context, string) error
+	UnwatchValidator(context.Context, string) error
+	GetWatchedValidators(context.Context) ([]string, error)
+	CreateValidatorActivity(context.Context, []models.ValidatorActivity) error
+	GetValidatorHistory(context.Context, uint64, uint64) ([]models.ValidatorActivity, error)
 	KeepOnlyTop5(context.Context, uint64) error
 }
 
+// number of slots a reorg is followed back, see Store.canonicalize
+const reorgWindow = 64
+
 type Store struct {
-	pool    *pgxpool.Pool
-	builder squirrel.StatementBuilderType
+	pool       *pgxpool.Pool
+	builder    squirrel.StatementBuilderType
+	partitions *db.Partitions
 }
 
 // Store implements Repository
@@ -26,15 +50,34 @@ var _ Repository = &Store{}
 
 func New(pool *pgxpool.Pool) *Store {
 	return &Store{
-		pool:    pool,
-		builder: squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar),
+		pool:       pool,
+		builder:    squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar),
+		partitions: db.NewPartitions(pool),
 	}
 }
 
-func (s *Store) Create(ctx context.Context, e models.Epoch) error {
+// writes an epoch with its stats, slots, blocks, receipts & contract events in a single transaction, rows which are
+// already stored are handled according to policy, the result counts the epoch, slot & block rows, the epoch is
+// notified on EpochsChannel on commit. Inserted rows keep the revision they carry, e.g. when imported, 1 otherwise
+func (s *Store) Create(ctx context.Context, e models.Epoch, policy models.ConflictPolicy) (models.WriteResult, error) {
+	result := models.WriteResult{}
+	policy, err := models.ParseConflictPolicy(string(policy))
+	if err != nil {
+		return result, err
+	}
+	roots, err := bytesOf([]models.Epoch{e})
+	if err != nil {
+		return result, err
+	}
+	if from, to, ok := slotRange([]models.Epoch{e}); ok {
+		err = s.partitions.Ensure(ctx, from, to)
+		if err != nil {
+			return result, err
+		}
+	}
 	tx, err := s.pool.Begin(ctx)
 	if err != nil {
-		return err
+		return result, err
 	}
 	success := false
 	defer func() {
@@ -47,106 +90,625 @@ func (s *Store) Create(ctx context.Context, e models.Epoch) error {
 	}()
 
 	// insert epoch
-	qry, args, err := s.builder.Insert("epochs").
-		Columns("epoch_number", "start_time", "end_time").
-		Values(e.EpochNumber, e.StartTime, e.EndTime).ToSql()
+	written, err := upsert(ctx, tx, "epochs", s.builder.Insert("epochs").
+		Columns("epoch_number", "start_time", "end_time", "revision").
+		Values(e.EpochNumber, e.StartTime, e.EndTime, revisionOf(e.Revision)), 1, policy, true)
 	if err != nil {
-		return fmt.Errorf("epochs insert query prep failed, err: %v", err.Error())
+		return result, err
 	}
-	_, err = tx.Exec(ctx, qry, args...)
-	if err != nil {
-		return fmt.Errorf("epochs insert query failed, err: %v", err.Error())
+	result = sum(result, written)
+
+	// insert epoch aggregates
+	if e.Stats != nil {
+		st := e.Stats
+		_, err = upsert(ctx, tx, "epoch_stats", s.builder.Insert("epoch_stats").
+			Columns("epoch_number", "proposed_slots", "missed_slots", "no_of_transactions", "gas_used", "gas_limit",
+				"avg_gas_used", "gas_utilisation", "min_block_time", "max_block_time", "avg_block_time").
+			Values(e.EpochNumber, st.ProposedSlots, st.MissedSlots, st.NoOfTransactions, st.GasUsed, st.GasLimit,
+				st.AvgGasUsed, st.GasUtilisation, st.MinBlockTime, st.MaxBlockTime, st.AvgBlockTime), 1, policy, false)
+		if err != nil {
+			return result, err
+		}
 	}
 
-	// insert slots & blocks
+	// insert slots & blocks, the canonical one & its competitors
 	slotsBldr := s.builder.Insert("slots").
-		Columns("slot_number", "start_time", "end_time", "epoch_number")
+		Columns("slot_number", "start_time", "end_time", "epoch_number", "revision")
 	blocksBldr := s.builder.Insert("blocks").
-		Columns("block_number", "block_root", "state_root", "slot_number", "gas_limit", "gas_used", "no_of_transactions", "created_at")
-	for _, s := range e.Slots {
-		slotsBldr = slotsBldr.Values(s.SlotNumber, s.StartTime, s.EndTime, s.EpochNumber)
-		blocksBldr = blocksBldr.Values(s.Block.BlockNumber, s.Block.BlockRoot, s.Block.StateRoot,
-			s.Block.SlotNumber, s.Block.GasLimit, s.Block.GasUsed, s.Block.NoOfTransactions, s.Block.CreatedAt)
+		Columns("block_number", "block_root", "parent_root", "canonical", "state_root", "slot_number", "gas_limit", "gas_used",
+			"no_of_transactions", "created_at", "version", "fee_recipient", "extra_data", "builder", "mev_boost", "revision")
+	var blocks []models.Block
+	for _, slot := range e.Slots {
+		slotsBldr = slotsBldr.Values(slot.SlotNumber, slot.StartTime, slot.EndTime, slot.EpochNumber, revisionOf(slot.Revision))
+		if slot.Block.BlockRoot != "" {
+			blocks = append(blocks, slot.Block)
+		}
+		blocks = append(blocks, slot.Candidates...)
 	}
-	qry, args, err = slotsBldr.ToSql()
+	for _, b := range blocks {
+		blocksBldr = blocksBldr.Values(b.BlockNumber, roots[b.BlockRoot], roots[b.ParentRoot], b.Canonical, roots[b.StateRoot], b.SlotNumber, b.GasLimit, b.GasUsed,
+			b.NoOfTransactions, b.CreatedAt, b.Version, b.FeeRecipient, b.ExtraData, b.Builder, b.MEVBoost, revisionOf(b.Revision))
+	}
+	if len(e.Slots) > 0 {
+		written, err = upsert(ctx, tx, "slots", slotsBldr, len(e.Slots), policy, true)
+		if err != nil {
+			return result, err
+		}
+		result = sum(result, written)
+	}
+	if len(blocks) > 0 {
+		written, err = upsert(ctx, tx, "blocks", blocksBldr, len(blocks), policy, true)
+		if err != nil {
+			return result, err
+		}
+		result = sum(result, written)
+	}
+
+	// insert receipts & decoded contract events of every block, one statement per block to stay clear of the bind
+	// parameter limit
+	for _, b := range blocks {
+		if len(b.Receipts) == 0 {
+			continue
+		}
+		receiptsBldr := s.builder.Insert("receipts").
+			Columns("block_root", "transaction_hash", "transaction_index", "block_number", "status", "gas_used", "effective_gas_price", "log_count")
+		for _, r := range b.Receipts {
+			receiptsBldr = receiptsBldr.Values(roots[b.BlockRoot], roots[r.TransactionHash], r.TransactionIndex, r.BlockNumber, r.Status, r.GasUsed, r.EffectiveGasPrice, r.LogCount)
+		}
+		_, err = upsert(ctx, tx, "receipts", receiptsBldr, len(b.Receipts), policy, false)
+		if err != nil {
+			return result, err
+		}
+	}
+
+	for _, b := range blocks {
+		if len(b.Events) == 0 {
+			continue
+		}
+		eventsBldr := s.builder.Insert("contract_events").
+			Columns("block_root", "block_number", "transaction_hash", "log_index", "address", "contract", "event", "signature", "args")
+		for _, ev := range b.Events {
+			eventsBldr = eventsBldr.Values(roots[b.BlockRoot], ev.BlockNumber, roots[ev.TransactionHash], ev.LogIndex, ev.Address, ev.Contract, ev.Event, ev.Signature, ev.Args)
+		}
+		_, err = upsert(ctx, tx, "contract_events", eventsBldr, len(b.Events), policy, false)
+		if err != nil {
+			return result, err
+		}
+	}
+
+	// reorgs may reach back into epochs stored earlier
+	if head, ok := headOf([]models.Epoch{e}); ok {
+		err = s.canonicalize(ctx, tx, head, head.SlotNumber)
+		if err != nil {
+			return result, err
+		}
+	}
+
+	// subscribers hear of the epoch once it commits, unless nothing was written
+	if result.Inserted+result.Updated > 0 {
+		written := result
+		err = notify(ctx, tx, []models.EpochNotification{notificationOf(e, &written)})
+		if err != nil {
+			return result, err
+		}
+	}
+
+	success = true
+	return result, nil
+}
+
+// the revision a row is inserted with, revisions are left out of updates
+func revisionOf(revision int) int {
+	if revision > 0 {
+		return revision
+	}
+	return 1
+}
+
+// conflict targets & the columns replaced on conflict of every table written by Create, canonical is left to
+// Store.canonicalize
+var upsertColumns = map[string]struct{ target, update []string }{
+	"epochs": {[]string{"epoch_number"}, []string{"start_time", "end_time"}},
+	"epoch_stats": {[]string{"epoch_number"}, []string{"proposed_slots", "missed_slots", "no_of_transactions", "gas_used",
+		"gas_limit", "avg_gas_used", "gas_utilisation", "min_block_time", "max_block_time", "avg_block_time"}},
+	"slots": {[]string{"slot_number"}, []string{"start_time", "end_time", "epoch_number"}},
+	"blocks": {[]string{"block_root", "slot_number"}, []string{"block_number", "parent_root", "state_root", "gas_limit",
+		"gas_used", "no_of_transactions", "created_at", "version", "fee_recipient", "extra_data", "builder", "mev_boost"}},
+	"receipts":        {[]string{"block_root", "transaction_index"}, []string{"transaction_hash", "block_number", "status", "gas_used", "effective_gas_price", "log_count"}},
+	"contract_events": {[]string{"block_root", "log_index"}, []string{"block_number", "transaction_hash", "address", "contract", "event", "signature", "args"}},
+}
+
+// runs an insert of rows rows under policy & counts the rows inserted, updated & skipped, the revision of
+// revisioned tables is bumped by every ConflictVersion update
+func upsert(ctx context.Context, tx pgx.Tx, table string, bldr squirrel.InsertBuilder, rows int, policy models.ConflictPolicy, revisioned bool) (models.WriteResult, error) {
+	result := models.WriteResult{}
+	columns := upsertColumns[table]
+	conflict := fmt.Sprintf("ON CONFLICT (%s) DO NOTHING", strings.Join(columns.target, ", "))
+	if policy != models.ConflictSkip {
+		set := make([]string, 0, len(columns.update)+1)
+		stored := make([]string, 0, len(columns.update))
+		excluded := make([]string, 0, len(columns.update))
+		for _, column := range columns.update {
+			set = append(set, fmt.Sprintf("%s = EXCLUDED.%s", column, column))
+			stored = append(stored, table+"."+column)
+			excluded = append(excluded, "EXCLUDED."+column)
+		}
+		if policy == models.ConflictVersion && revisioned {
+			set = append(set, fmt.Sprintf("revision = %s.revision + 1", table))
+		}
+		conflict = fmt.Sprintf("ON CONFLICT (%s) DO UPDATE SET %s", strings.Join(columns.target, ", "), strings.Join(set, ", "))
+		if policy == models.ConflictVersion {
+			conflict += fmt.Sprintf(" WHERE (%s) IS DISTINCT FROM (%s)", strings.Join(stored, ", "), strings.Join(excluded, ", "))
+		}
+	}
+	// xmax is 0 for freshly inserted rows only, rows left alone are not returned at all
+	qry, args, err := bldr.Suffix(conflict + " RETURNING (xmax = 0) AS inserted").ToSql()
 	if err != nil {
-		return fmt.Errorf("slots insert query prep failed, err: %v", err.Error())
+		return result, fmt.Errorf("%s insert query prep failed, err: %v", table, err.Error())
 	}
-	_, err = tx.Exec(ctx, qry, args...)
+	var inserted []bool
+	err = pgxscan.Select(ctx, tx, &inserted, qry, args...)
 	if err != nil {
-		return fmt.Errorf("slots insert query failed, err: %v", err.Error())
+		return result, fmt.Errorf("%s insert query failed, err: %v", table, err.Error())
 	}
+	for _, ok := range inserted {
+		if ok {
+			result.Inserted++
+		} else {
+			result.Updated++
+		}
+	}
+	result.Skipped = rows - len(inserted)
+	return result, nil
+}
+
+func sum(a, b models.WriteResult) models.WriteResult {
+	return models.WriteResult{Inserted: a.Inserted + b.Inserted, Updated: a.Updated + b.Updated, Skipped: a.Skipped + b.Skipped}
+}
 
-	qry, args, err = blocksBldr.ToSql()
+// the lowest & highest slot of epochs, if any
+func slotRange(epochs []models.Epoch) (uint64, uint64, bool) {
+	var from, to uint64
+	ok := false
+	for _, e := range epochs {
+		for _, slot := range e.Slots {
+			if !ok || slot.SlotNumber < from {
+				from = slot.SlotNumber
+			}
+			if !ok || slot.SlotNumber > to {
+				to = slot.SlotNumber
+			}
+			ok = true
+		}
+	}
+	return from, to, ok
+}
+
+// the canonical block of the highest slot, if any
+func headOf(epochs []models.Epoch) (models.Block, bool) {
+	for idxEpoch := len(epochs) - 1; idxEpoch >= 0; idxEpoch-- {
+		slots := epochs[idxEpoch].Slots
+		for idx := len(slots) - 1; idx >= 0; idx-- {
+			if head := slots[idx].Block; head.Canonical && head.BlockRoot != "" {
+				return head, true
+			}
+		}
+	}
+	return models.Block{}, false
+}
+
+// columns holding roots & hashes, which Store writes as BYTEA & reads through hex_of, see migration 000012
+var hexColumns = map[string]bool{"block_root": true, "parent_root": true, "state_root": true, "transaction_hash": true}
+
+// decodes every root & hash of epochs, keyed by their hex string, so that a malformed one fails the write before
+// anything is written
+func bytesOf(epochs []models.Epoch) (map[string][]byte, error) {
+	roots := map[string][]byte{"": {}}
+	add := func(hexes ...string) error {
+		for _, h := range hexes {
+			if _, ok := roots[h]; ok {
+				continue
+			}
+			b, err := models.DecodeHex(h)
+			if err != nil {
+				return fmt.Errorf("invalid root or hash, err: %v", err.Error())
+			}
+			roots[h] = b
+		}
+		return nil
+	}
+	for _, e := range epochs {
+		for _, slot := range e.Slots {
+			for _, b := range append([]models.Block{slot.Block}, slot.Candidates...) {
+				err := add(b.BlockRoot, b.ParentRoot, b.StateRoot)
+				if err != nil {
+					return nil, err
+				}
+				for _, r := range b.Receipts {
+					err = add(r.TransactionHash)
+					if err != nil {
+						return nil, err
+					}
+				}
+				for _, ev := range b.Events {
+					err = add(ev.TransactionHash)
+					if err != nil {
+						return nil, err
+					}
+				}
+			}
+		}
+	}
+	return roots, nil
+}
+
+// follows parent roots from head back down to reorgWindow slots before slot from, blocks on that chain become
+// canonical & the others within the slots it spans are demoted, nothing changes when a newer canonical block is
+// already stored
+func (s *Store) canonicalize(ctx context.Context, tx pgx.Tx, head models.Block, from uint64) error {
+	var newer bool
+	err := tx.QueryRow(ctx, newerCanonicalQuery, head.SlotNumber).Scan(&newer)
 	if err != nil {
-		return fmt.Errorf("blocks insert query prep failed, err: %v", err.Error())
+		return fmt.Errorf("blocks head query failed, err: %v", err.Error())
 	}
-	_, err = tx.Exec(ctx, qry, args...)
+	if newer {
+		return nil
+	}
+	root, err := models.DecodeHex(head.BlockRoot)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, canonicalizeQuery, root, floorOf(from), head.SlotNumber)
 	if err != nil {
-		return fmt.Errorf("blocks insert query failed, err: %v", err.Error())
+		return fmt.Errorf("blocks canonical update query failed, err: %v", err.Error())
 	}
+	return nil
+}
 
-	success = true
+// follows parent roots from head as Create does, even when a newer canonical block is stored, meant for repairs
+// from a trusted source such as the beacon node
+func (s *Store) Recanonicalize(ctx context.Context, head models.Block) error {
+	root, err := models.DecodeHex(head.BlockRoot)
+	if err != nil {
+		return err
+	}
+	_, err = s.pool.Exec(ctx, canonicalizeQuery, root, floorOf(head.SlotNumber), head.SlotNumber)
+	if err != nil {
+		return fmt.Errorf("blocks canonical update query failed, err: %v", err.Error())
+	}
 	return nil
 }
 
+// marks blocks as no longer canonical, whatever their slot, meant for repairs of blocks a trusted source such as the
+// beacon node doesn't know
+func (s *Store) Demote(ctx context.Context, roots []string) error {
+	keys := make([][]byte, 0, len(roots))
+	for _, root := range roots {
+		b, err := models.DecodeHex(root)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, b)
+	}
+	qry, args, err := s.builder.Update("blocks").Set("canonical", false).Where(squirrel.Eq{"block_root": keys}).ToSql()
+	if err != nil {
+		return fmt.Errorf("blocks canonical update query prep failed, err: %v", err.Error())
+	}
+	_, err = s.pool.Exec(ctx, qry, args...)
+	if err != nil {
+		return fmt.Errorf("blocks canonical update query failed, err: %v", err.Error())
+	}
+	return nil
+}
+
+// queries of canonicalize shared by the SQL backends, the first takes the head's slot, the second its root, the
+// floor & its slot
+const (
+	newerCanonicalQuery = "SELECT EXISTS(SELECT 1 FROM blocks WHERE canonical AND slot_number > $1)"
+	canonicalizeQuery   = `WITH RECURSIVE chain AS (
+			SELECT block_root, parent_root, slot_number FROM blocks WHERE block_root = $1
+			UNION ALL
+			SELECT b.block_root, b.parent_root, b.slot_number FROM blocks b
+			JOIN chain c ON b.block_root = c.parent_root
+			WHERE b.slot_number >= $2
+		)
+		UPDATE blocks SET canonical = blocks.block_root IN (SELECT block_root FROM chain)
+		WHERE blocks.slot_number >= (SELECT MIN(slot_number) FROM chain) AND blocks.slot_number <= $3`
+)
+
+// the lowest slot a reorg of slots from on is followed back to
+func floorOf(from uint64) uint64 {
+	if from > reorgWindow {
+		return from - reorgWindow
+	}
+	return 0
+}
+
+// looks up every stored epoch with its slots & blocks, oldest first
 func (s *Store) Get(ctx context.Context) ([]models.Epoch, error) {
-	qry, args, err := s.builder.Select("*").From("epochs").ToSql()
+	page, err := s.GetEpochs(ctx, models.RangeQuery{})
 	if err != nil {
-		return nil, fmt.Errorf("epochs select query prep failed, err: %v", err.Error())
+		return nil, err
 	}
-	var epochs []models.Epoch
-	err = pgxscan.Select(ctx, s.pool, &epochs, qry, args...)
+	return page.Epochs, nil
+}
+
+// returns the aggregates of stored epochs, most recent epoch first
+func (s *Store) GetEpochStats(ctx context.Context, limit uint64) ([]models.EpochStats, error) {
+	bldr := s.builder.Select("*").From("epoch_stats").OrderBy("epoch_number DESC")
+	if limit > 0 {
+		bldr = bldr.Limit(limit)
+	}
+	qry, args, err := bldr.ToSql()
 	if err != nil {
-		return nil, fmt.Errorf("epochs select query failed, err: %v", err.Error())
+		return nil, fmt.Errorf("epoch_stats select query prep failed, err: %v", err.Error())
 	}
+	stats := []models.EpochStats{}
+	err = pgxscan.Select(ctx, s.pool, &stats, qry, args...)
+	if err != nil {
+		return nil, fmt.Errorf("epoch_stats select query failed, err: %v", err.Error())
+	}
+	return stats, nil
+}
 
-	qry, args, err = s.builder.Select("*").From("slots").ToSql()
+// returns the stats of the epochs from fromEpoch to toEpoch, oldest first
+func (s *Store) GetEpochStatsBetween(ctx context.Context, fromEpoch uint64, toEpoch uint64) ([]models.EpochStats, error) {
+	qry, args, err := s.builder.Select("*").From("epoch_stats").
+		Where(squirrel.GtOrEq{"epoch_number": fromEpoch}).
+		Where(squirrel.LtOrEq{"epoch_number": toEpoch}).
+		OrderBy("epoch_number").
+		ToSql()
 	if err != nil {
-		return nil, fmt.Errorf("slots select query prep failed, err: %v", err.Error())
+		return nil, fmt.Errorf("epoch_stats select query prep failed, err: %v", err.Error())
 	}
-	var slots []models.Slot
-	err = pgxscan.Select(ctx, s.pool, &slots, qry, args...)
+	stats := []models.EpochStats{}
+	err = pgxscan.Select(ctx, s.pool, &stats, qry, args...)
 	if err != nil {
-		return nil, fmt.Errorf("slots select query failed, err: %v", err.Error())
+		return nil, fmt.Errorf("epoch_stats select query failed, err: %v", err.Error())
 	}
+	return stats, nil
+}
 
-	qry, args, err = s.builder.Select("*").From("blocks").ToSql()
+func (s *Store) GetContractEvents(ctx context.Context, filter models.EventFilter) ([]models.ContractEvent, error) {
+	bldr := s.builder.Select("block_number", "hex_of(block_root) AS block_root", "hex_of(transaction_hash) AS transaction_hash",
+		"log_index", "address", "contract", "event", "signature", "args").
+		From("contract_events").OrderBy("block_number", "log_index")
+	if filter.Contract != "" {
+		bldr = bldr.Where(squirrel.Eq{"contract": filter.Contract})
+	}
+	if filter.Address != "" {
+		bldr = bldr.Where(squirrel.Eq{"address": strings.ToLower(filter.Address)})
+	}
+	if filter.Event != "" {
+		bldr = bldr.Where(squirrel.Eq{"event": filter.Event})
+	}
+	if filter.FromBlock > 0 {
+		bldr = bldr.Where(squirrel.GtOrEq{"block_number": filter.FromBlock})
+	}
+	if filter.ToBlock > 0 {
+		bldr = bldr.Where(squirrel.LtOrEq{"block_number": filter.ToBlock})
+	}
+	if filter.Limit > 0 {
+		bldr = bldr.Limit(filter.Limit)
+	}
+	qry, args, err := bldr.ToSql()
 	if err != nil {
-		return nil, fmt.Errorf("blocks select query prep failed, err: %v", err.Error())
+		return nil, fmt.Errorf("contract_events select query prep failed, err: %v", err.Error())
 	}
-	var blocks []models.Block
-	err = pgxscan.Select(ctx, s.pool, &blocks, qry, args...)
+	events := []models.ContractEvent{}
+	err = pgxscan.Select(ctx, s.pool, &events, qry, args...)
 	if err != nil {
-		return nil, fmt.Errorf("blocks select query failed, err: %v", err.Error())
+		return nil, fmt.Errorf("contract_events select query failed, err: %v", err.Error())
 	}
+	return events, nil
+}
 
-	for idxEpoch := range epochs {
-		for idxSlot, slot := range slots {
-			if slot.EpochNumber == epochs[idxEpoch].EpochNumber {
-				for _, block := range blocks {
-					if slot.SlotNumber == block.SlotNumber {
-						slots[idxSlot].Block = block
-					}
-				}
-				epochs[idxEpoch].Slots = append(epochs[idxEpoch].Slots, slots[idxSlot])
-			}
-		}
+// returns the share of classified blocks per builder for every epoch within the range, 0 leaves a bound open,
+// most recent epoch & largest share first
+func (s *Store) GetBuilderShare(ctx context.Context, fromEpoch uint64, toEpoch uint64) ([]models.BuilderShare, error) {
+	bldr := s.builder.Select("slots.epoch_number", "blocks.builder", "COUNT(*) AS blocks",
+		"COUNT(*)::DOUBLE PRECISION / SUM(COUNT(*)) OVER (PARTITION BY slots.epoch_number) AS share").
+		From("blocks").
+		Join("slots ON slots.slot_number = blocks.slot_number").
+		Where(squirrel.Eq{"blocks.canonical": true}).
+		Where(squirrel.NotEq{"blocks.builder": ""}).
+		GroupBy("slots.epoch_number", "blocks.builder").
+		OrderBy("slots.epoch_number DESC", "blocks DESC", "blocks.builder")
+	if fromEpoch > 0 {
+		bldr = bldr.Where(squirrel.GtOrEq{"slots.epoch_number": fromEpoch})
+	}
+	if toEpoch > 0 {
+		bldr = bldr.Where(squirrel.LtOrEq{"slots.epoch_number": toEpoch})
+	}
+	qry, args, err := bldr.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("builder share select query prep failed, err: %v", err.Error())
+	}
+	shares := []models.BuilderShare{}
+	err = pgxscan.Select(ctx, s.pool, &shares, qry, args...)
+	if err != nil {
+		return nil, fmt.Errorf("builder share select query failed, err: %v", err.Error())
+	}
+	return shares, nil
+}
+
+// stores the fork schedule, forks already known are updated
+func (s *Store) SaveForkSchedule(ctx context.Context, forks []models.Fork) error {
+	if len(forks) == 0 {
+		return nil
+	}
+	bldr := s.builder.Insert("fork_schedule").Columns("name", "previous_version", "current_version", "epoch")
+	for _, f := range forks {
+		bldr = bldr.Values(f.Name, f.PreviousVersion, f.CurrentVersion, f.Epoch)
+	}
+	qry, args, err := bldr.Suffix(`ON CONFLICT (name) DO UPDATE SET
+		previous_version = EXCLUDED.previous_version, current_version = EXCLUDED.current_version, epoch = EXCLUDED.epoch`).
+		ToSql()
+	if err != nil {
+		return fmt.Errorf("fork_schedule insert query prep failed, err: %v", err.Error())
+	}
+	_, err = s.pool.Exec(ctx, qry, args...)
+	if err != nil {
+		return fmt.Errorf("fork_schedule insert query failed, err: %v", err.Error())
+	}
+	return nil
+}
+
+// returns the fork schedule, earliest fork first, fork versions order forks activated at the same epoch
+func (s *Store) GetForkSchedule(ctx context.Context) ([]models.Fork, error) {
+	qry, args, err := s.builder.Select("*").From("fork_schedule").OrderBy("epoch", "current_version").ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("fork_schedule select query prep failed, err: %v", err.Error())
+	}
+	forks := []models.Fork{}
+	err = pgxscan.Select(ctx, s.pool, &forks, qry, args...)
+	if err != nil {
+		return nil, fmt.Errorf("fork_schedule select query failed, err: %v", err.Error())
+	}
+	return forks, nil
+}
+
+// adds a validator index or pubkey to the watchlist, watching it twice is a no-op
+func (s *Store) WatchValidator(ctx context.Context, validator string) error {
+	qry, args, err := s.builder.Insert("watched_validators").
+		Columns("validator").
+		Values(validator).
+		Suffix("ON CONFLICT (validator) DO NOTHING").
+		ToSql()
+	if err != nil {
+		return fmt.Errorf("watched_validators insert query prep failed, err: %v", err.Error())
+	}
+	_, err = s.pool.Exec(ctx, qry, args...)
+	if err != nil {
+		return fmt.Errorf("watched_validators insert query failed, err: %v", err.Error())
+	}
+	return nil
+}
+
+func (s *Store) UnwatchValidator(ctx context.Context, validator string) error {
+	qry, args, err := s.builder.Delete("watched_validators").
+		Where(squirrel.Eq{"validator": validator}).
+		ToSql()
+	if err != nil {
+		return fmt.Errorf("watched_validators delete query prep failed, err: %v", err.Error())
+	}
+	_, err = s.pool.Exec(ctx, qry, args...)
+	if err != nil {
+		return fmt.Errorf("watched_validators delete query failed, err: %v", err.Error())
+	}
+	return nil
+}
+
+func (s *Store) GetWatchedValidators(ctx context.Context) ([]string, error) {
+	qry, args, err := s.builder.Select("validator").From("watched_validators").OrderBy("created_at").ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("watched_validators select query prep failed, err: %v", err.Error())
 	}
+	validators := []string{}
+	err = pgxscan.Select(ctx, s.pool, &validators, qry, args...)
+	if err != nil {
+		return nil, fmt.Errorf("watched_validators select query failed, err: %v", err.Error())
+	}
+	return validators, nil
+}
 
-	return epochs, nil
+// stores the activity of watched validators, re-tracking an epoch overwrites its previous activity
+func (s *Store) CreateValidatorActivity(ctx context.Context, activity []models.ValidatorActivity) error {
+	if len(activity) == 0 {
+		return nil
+	}
+	bldr := s.builder.Insert("validator_activity").
+		Columns("validator_index", "epoch_number", "proposer_duties", "proposed", "attester_duties", "attestations_included",
+			"inclusion_delay", "sync_duties", "sync_participated", "balance", "reward")
+	for _, a := range activity {
+		bldr = bldr.Values(a.ValidatorIndex, a.EpochNumber, a.ProposerDuties, a.Proposed, a.AttesterDuties, a.AttestationsIncluded,
+			a.InclusionDelay, a.SyncDuties, a.SyncParticipated, a.Balance, a.Reward)
+	}
+	qry, args, err := bldr.Suffix(`ON CONFLICT (validator_index, epoch_number) DO UPDATE SET
+		proposer_duties = EXCLUDED.proposer_duties, proposed = EXCLUDED.proposed,
+		attester_duties = EXCLUDED.attester_duties, attestations_included = EXCLUDED.attestations_included,
+		inclusion_delay = EXCLUDED.inclusion_delay, sync_duties = EXCLUDED.sync_duties,
+		sync_participated = EXCLUDED.sync_participated, balance = EXCLUDED.balance, reward = EXCLUDED.reward`).
+		ToSql()
+	if err != nil {
+		return fmt.Errorf("validator_activity insert query prep failed, err: %v", err.Error())
+	}
+	_, err = s.pool.Exec(ctx, qry, args...)
+	if err != nil {
+		return fmt.Errorf("validator_activity insert query failed, err: %v", err.Error())
+	}
+	return nil
+}
+
+// returns the activity of a validator, most recent epoch first
+func (s *Store) GetValidatorHistory(ctx context.Context, validatorIndex uint64, limit uint64) ([]models.ValidatorActivity, error) {
+	bldr := s.builder.Select("*").From("validator_activity").
+		Where(squirrel.Eq{"validator_index": validatorIndex}).
+		OrderBy("epoch_number DESC")
+	if limit > 0 {
+		bldr = bldr.Limit(limit)
+	}
+	qry, args, err := bldr.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("validator_activity select query prep failed, err: %v", err.Error())
+	}
+	activity := []models.ValidatorActivity{}
+	err = pgxscan.Select(ctx, s.pool, &activity, qry, args...)
+	if err != nil {
+		return nil, fmt.Errorf("validator_activity select query failed, err: %v", err.Error())
+	}
+	return activity, nil
 }
 
+// keeps the 5 latest epochs, the partitions of slots & blocks holding older slots only are dropped whole, the older
+// rows of the partition the kept slots begin in are deleted
 func (s *Store) KeepOnlyTop5(ctx context.Context, epochNumber uint64) error {
+	if epochNumber < 5 {
+		return nil
+	}
+	var kept *uint64
+	err := s.pool.QueryRow(ctx, "SELECT MIN(slot_number) FROM slots WHERE epoch_number > $1", epochNumber-5).Scan(&kept)
+	if err != nil {
+		return fmt.Errorf("slots select query failed, err: %v", err.Error())
+	}
+	if kept != nil {
+		_, err = s.partitions.DropBefore(ctx, *kept)
+		if err != nil {
+			return err
+		}
+	}
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	success := false
+	defer func() {
+		if success {
+			tx.Commit(ctx)
+
+		} else {
+			tx.Rollback(ctx)
+		}
+	}()
+	// receipts & contract events don't reference blocks, slots, blocks & stats go along with their epochs
+	for _, table := range []string{"receipts", "contract_events"} {
+		_, err = tx.Exec(ctx, fmt.Sprintf(`DELETE FROM %s WHERE block_root IN (SELECT block_root FROM blocks
+			WHERE slot_number IN (SELECT slot_number FROM slots WHERE epoch_number <= $1))`, table), epochNumber-5)
+		if err != nil {
+			return fmt.Errorf("%s delete query failed, err: %v", table, err.Error())
+		}
+	}
 	qry, args, err := s.builder.Delete("epochs").
 		Where(squirrel.LtOrEq{"epoch_number": epochNumber - 5}).
 		ToSql()
 	if err != nil {
 		return fmt.Errorf("epochs delete query prep failed, err: %v", err.Error())
 	}
-	_, err = s.pool.Exec(ctx, qry, args...)
+	_, err = tx.Exec(ctx, qry, args...)
 	if err != nil {
 		return fmt.Errorf("epochs delete query failed, err: %v", err.Error())
 	}
-	return err
+	success = true
+	return nil
 }
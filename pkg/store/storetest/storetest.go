@@ -0,0 +1,734 @@
+// Package storetest is the conformance suite of store.Repository, every implementation runs it from its own tests
+// so that the backends cannot drift apart
+package storetest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"indexer/pkg/models"
+	"indexer/pkg/store"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// slots per epoch of the epochs written by the suite, kept small for the suite to stay fast
+const slotsPerEpoch = 4
+
+// runs the suite, newRepo is called by every test for an empty repository
+func Run(t *testing.T, newRepo func(t *testing.T) store.Repository) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, repo store.Repository)
+	}{
+		{"create & get", testCreateGet},
+		{"conflict policies", testConflictPolicies},
+		{"reorgs", testReorgs},
+		{"epoch pages", testEpochPages},
+		{"paging through epochs", testEpochPaging},
+		{"slot pages", testSlotPages},
+		{"not found", testNotFound},
+		{"invalid roots", testInvalidRoots},
+		{"demote", testDemote},
+		{"epoch stats", testEpochStats},
+		{"retention", testRetention},
+		{"retention of distant epochs", testRetentionDistant},
+		{"fork schedule", testForkSchedule},
+		{"watched validators", testWatchedValidators},
+		{"validator history", testValidatorHistory},
+		{"contract events", testContractEvents},
+		{"builder share", testBuilderShare},
+		{"export & import", testExportImport},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.test(t, newRepo(t))
+		})
+	}
+}
+
+// epochs of slotsPerEpoch slots, each with a canonical block of 2 receipts on a single chain, as they are read back
+func Epochs(from, n int) []models.Epoch {
+	genesis := time.Unix(1606824023, 0).UTC()
+	epochs := make([]models.Epoch, 0, n)
+	for e := from; e < from+n; e++ {
+		epoch := models.Epoch{
+			EpochNumber: uint64(e),
+			StartTime:   genesis.Add(time.Duration(e*slotsPerEpoch) * 12 * time.Second),
+			EndTime:     genesis.Add(time.Duration((e+1)*slotsPerEpoch) * 12 * time.Second),
+			Revision:    1,
+		}
+		for slot := uint64(e * slotsPerEpoch); slot < uint64((e+1)*slotsPerEpoch); slot++ {
+			block := Block(slot, rootOf(slot-1))
+			if slot == 0 {
+				block.ParentRoot = ""
+			}
+			epoch.Slots = append(epoch.Slots, models.Slot{
+				SlotNumber: slot, EpochNumber: epoch.EpochNumber, Block: block, Revision: 1,
+				StartTime: genesis.Add(time.Duration(slot) * 12 * time.Second),
+				EndTime:   genesis.Add(time.Duration(slot+1) * 12 * time.Second),
+			})
+		}
+		epochs = append(epochs, epoch)
+	}
+	return epochs
+}
+
+// the canonical block of slot with 2 receipts, as it is read back
+func Block(slot uint64, parentRoot string) models.Block {
+	root := rootOf(slot)
+	block := models.Block{
+		BlockNumber: slot, BlockRoot: root, ParentRoot: parentRoot, Canonical: true, StateRoot: root,
+		SlotNumber: slot, GasLimit: 30_000_000, GasUsed: 15_000_000, NoOfTransactions: 2,
+		CreatedAt: time.Unix(1606824023, 0).UTC().Add(time.Duration(slot) * 12 * time.Second), Revision: 1,
+	}
+	for idx := uint64(0); idx < 2; idx++ {
+		block.Receipts = append(block.Receipts, models.Receipt{
+			TransactionHash: fmt.Sprintf("0x%032x%032x", slot, idx), TransactionIndex: idx, BlockNumber: slot,
+			BlockRoot: root, Status: 1, GasUsed: 21_000, EffectiveGasPrice: 1_000_000_000, LogCount: 1,
+		})
+	}
+	return block
+}
+
+func rootOf(slot uint64) string {
+	return fmt.Sprintf("0x%064x", slot)
+}
+
+func create(t *testing.T, repo store.Repository, epochs ...models.Epoch) {
+	t.Helper()
+	for _, e := range epochs {
+		_, err := repo.Create(context.Background(), e, models.ConflictSkip)
+		if err != nil {
+			t.Fatalf("repo.Create() failed, err: %v", err.Error())
+		}
+	}
+}
+
+// backends render timestamps in different locations, they are compared in UTC
+func normalize(epochs []models.Epoch) []models.Epoch {
+	for idx := range epochs {
+		epochs[idx].StartTime, epochs[idx].EndTime = epochs[idx].StartTime.UTC(), epochs[idx].EndTime.UTC()
+		normalizeSlots(epochs[idx].Slots)
+	}
+	return epochs
+}
+
+func normalizeSlots(slots []models.Slot) []models.Slot {
+	for idx := range slots {
+		slots[idx].StartTime, slots[idx].EndTime = slots[idx].StartTime.UTC(), slots[idx].EndTime.UTC()
+		slots[idx].Block = normalizeBlock(slots[idx].Block)
+		for idxCandidate := range slots[idx].Candidates {
+			slots[idx].Candidates[idxCandidate] = normalizeBlock(slots[idx].Candidates[idxCandidate])
+		}
+	}
+	return slots
+}
+
+func normalizeBlock(block models.Block) models.Block {
+	block.CreatedAt = block.CreatedAt.UTC()
+	return block
+}
+
+func testCreateGet(t *testing.T, repo store.Repository) {
+	ctx := context.Background()
+	want := Epochs(0, 3)
+	for _, e := range want {
+		result, err := repo.Create(ctx, e, models.ConflictSkip)
+		assert.NoError(t, err)
+		assert.Equal(t, models.WriteResult{Inserted: 1 + 2*slotsPerEpoch}, result)
+	}
+
+	got, err := repo.Get(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, want, normalize(got))
+
+	epoch, err := repo.GetEpoch(ctx, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, want[1], normalize([]models.Epoch{epoch})[0])
+
+	slot, err := repo.GetSlot(ctx, 5)
+	assert.NoError(t, err)
+	assert.Equal(t, want[1].Slots[1], normalizeSlots([]models.Slot{slot})[0])
+
+	block, err := repo.GetBlock(ctx, rootOf(6))
+	assert.NoError(t, err)
+	assert.Equal(t, want[1].Slots[2].Block, normalizeBlock(block))
+
+	block, err = repo.GetBlockByNumber(ctx, 7)
+	assert.NoError(t, err)
+	assert.Equal(t, want[1].Slots[3].Block, normalizeBlock(block))
+}
+
+func testConflictPolicies(t *testing.T, repo store.Repository) {
+	ctx := context.Background()
+	epoch := Epochs(0, 1)[0]
+	create(t, repo, epoch)
+
+	// the same epoch again
+	result, err := repo.Create(ctx, epoch, models.ConflictSkip)
+	assert.NoError(t, err)
+	assert.Equal(t, models.WriteResult{Skipped: 1 + 2*slotsPerEpoch}, result)
+	result, err = repo.Create(ctx, epoch, models.ConflictVersion)
+	assert.NoError(t, err)
+	assert.Equal(t, models.WriteResult{Skipped: 1 + 2*slotsPerEpoch}, result)
+
+	// a block changed since
+	epoch.Slots[1].Block.GasUsed = 20_000_000
+	result, err = repo.Create(ctx, epoch, models.ConflictSkip)
+	assert.NoError(t, err)
+	assert.Equal(t, models.WriteResult{Skipped: 1 + 2*slotsPerEpoch}, result)
+	block, err := repo.GetBlock(ctx, rootOf(1))
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(15_000_000), block.GasUsed)
+
+	result, err = repo.Create(ctx, epoch, models.ConflictVersion)
+	assert.NoError(t, err)
+	assert.Equal(t, models.WriteResult{Updated: 1, Skipped: 2 * slotsPerEpoch}, result)
+	block, err = repo.GetBlock(ctx, rootOf(1))
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(20_000_000), block.GasUsed)
+	assert.Equal(t, 2, block.Revision)
+
+	// overwriting leaves revisions alone
+	epoch.Slots[1].Block.GasUsed = 25_000_000
+	result, err = repo.Create(ctx, epoch, models.ConflictOverwrite)
+	assert.NoError(t, err)
+	assert.Equal(t, models.WriteResult{Updated: 1 + 2*slotsPerEpoch}, result)
+	block, err = repo.GetBlock(ctx, rootOf(1))
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(25_000_000), block.GasUsed)
+	assert.Equal(t, 2, block.Revision)
+
+	_, err = repo.Create(ctx, epoch, models.ConflictPolicy("merge"))
+	assert.Error(t, err)
+}
+
+func testReorgs(t *testing.T, repo store.Repository) {
+	ctx := context.Background()
+	epochs := Epochs(0, 2)
+	create(t, repo, epochs[0])
+
+	// the head of epoch 0 is orphaned by epoch 1, whose first slot also saw a competing block
+	orphan := epochs[0].Slots[3].Block
+	epochs[1].Slots[0].Block.ParentRoot = rootOf(2)
+	competitor := Block(4, orphan.BlockRoot)
+	competitor.BlockRoot, competitor.Canonical = "0xc0ffee", false
+	for idx := range competitor.Receipts {
+		competitor.Receipts[idx].BlockRoot = competitor.BlockRoot
+	}
+	epochs[1].Slots[0].Candidates = []models.Block{competitor}
+	create(t, repo, epochs[1])
+
+	slot, err := repo.GetSlot(ctx, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, "", slot.Block.BlockRoot)
+	if assert.Len(t, slot.Candidates, 1) {
+		assert.Equal(t, orphan.BlockRoot, slot.Candidates[0].BlockRoot)
+		assert.False(t, slot.Candidates[0].Canonical)
+		// receipts are kept along with orphaned blocks
+		assert.Equal(t, orphan.Receipts, slot.Candidates[0].Receipts)
+	}
+	block, err := repo.GetBlock(ctx, orphan.BlockRoot)
+	assert.NoError(t, err)
+	assert.False(t, block.Canonical)
+	_, err = repo.GetBlockByNumber(ctx, 3)
+	assert.True(t, errors.Is(err, store.ErrNotFound), "GetBlockByNumber() of an orphaned block must return ErrNotFound, got %v", err)
+
+	slot, err = repo.GetSlot(ctx, 4)
+	assert.NoError(t, err)
+	assert.Equal(t, rootOf(4), slot.Block.BlockRoot)
+	assert.Equal(t, []string{"0xc0ffee"}, rootsOf(slot.Candidates))
+	// competitors written as such keep their receipts too
+	if assert.Len(t, slot.Candidates, 1) {
+		assert.Equal(t, competitor.Receipts, slot.Candidates[0].Receipts)
+	}
+	for _, number := range []uint64{0, 1, 2, 4, 5} {
+		block, err = repo.GetBlockByNumber(ctx, number)
+		assert.NoError(t, err)
+		assert.True(t, block.Canonical, "block %d must stay canonical", number)
+	}
+
+	// an older epoch stored late does not override the newer head
+	late := Epochs(0, 1)[0]
+	late.Slots = late.Slots[3:]
+	_, err = repo.Create(ctx, late, models.ConflictOverwrite)
+	assert.NoError(t, err)
+	block, err = repo.GetBlock(ctx, orphan.BlockRoot)
+	assert.NoError(t, err)
+	assert.False(t, block.Canonical)
+}
+
+func rootsOf(blocks []models.Block) []string {
+	roots := make([]string, 0, len(blocks))
+	for _, b := range blocks {
+		roots = append(roots, b.BlockRoot)
+	}
+	return roots
+}
+
+func numbersOf(epochs []models.Epoch) []uint64 {
+	numbers := make([]uint64, 0, len(epochs))
+	for _, e := range epochs {
+		numbers = append(numbers, e.EpochNumber)
+	}
+	return numbers
+}
+
+func testEpochPages(t *testing.T, repo store.Repository) {
+	ctx := context.Background()
+	epochs := Epochs(0, 5)
+	create(t, repo, epochs...)
+
+	page, err := repo.GetEpochs(ctx, models.RangeQuery{Limit: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, []uint64{0, 1}, numbersOf(page.Epochs))
+	assert.NotEmpty(t, page.Next)
+	page, err = repo.GetEpochs(ctx, models.RangeQuery{Limit: 2, Cursor: page.Next})
+	assert.NoError(t, err)
+	assert.Equal(t, []uint64{2, 3}, numbersOf(page.Epochs))
+	page, err = repo.GetEpochs(ctx, models.RangeQuery{Limit: 2, Cursor: page.Next})
+	assert.NoError(t, err)
+	assert.Equal(t, []uint64{4}, numbersOf(page.Epochs))
+	assert.Empty(t, page.Next)
+
+	page, err = repo.GetEpochs(ctx, models.RangeQuery{Descending: true, Limit: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, []uint64{4, 3}, numbersOf(page.Epochs))
+	page, err = repo.GetEpochs(ctx, models.RangeQuery{Descending: true, Limit: 2, Cursor: page.Next})
+	assert.NoError(t, err)
+	assert.Equal(t, []uint64{2, 1}, numbersOf(page.Epochs))
+
+	page, err = repo.GetEpochs(ctx, models.RangeQuery{From: 1, To: 3})
+	assert.NoError(t, err)
+	assert.Equal(t, []uint64{1, 2, 3}, numbersOf(page.Epochs))
+	assert.Empty(t, page.Next)
+
+	page, err = repo.GetEpochs(ctx, models.RangeQuery{FromTime: epochs[2].StartTime, ToTime: epochs[3].StartTime})
+	assert.NoError(t, err)
+	assert.Equal(t, []uint64{2, 3}, numbersOf(page.Epochs))
+
+	page, err = repo.GetEpochs(ctx, models.RangeQuery{From: 10})
+	assert.NoError(t, err)
+	assert.Equal(t, []models.Epoch{}, page.Epochs)
+
+	_, err = repo.GetEpochs(ctx, models.RangeQuery{Cursor: "not a cursor"})
+	assert.True(t, errors.Is(err, store.ErrInvalidCursor), "GetEpochs() of an invalid cursor must return ErrInvalidCursor, got %v", err)
+}
+
+// follows the cursors of a query from its first page to its last, every page must hold at most Limit epochs
+func testEpochPaging(t *testing.T, repo store.Repository) {
+	ctx := context.Background()
+	epochs := Epochs(0, 10)
+	// a gap, which no page must stop at
+	create(t, repo, append(epochs[:5:5], epochs[6:]...)...)
+
+	tests := []struct {
+		name  string
+		query models.RangeQuery
+		want  [][]uint64
+	}{
+		{
+			name:  "ascending",
+			query: models.RangeQuery{Limit: 4},
+			want:  [][]uint64{{0, 1, 2, 3}, {4, 6, 7, 8}, {9}},
+		},
+		{
+			name:  "descending",
+			query: models.RangeQuery{Descending: true, Limit: 3},
+			want:  [][]uint64{{9, 8, 7}, {6, 4, 3}, {2, 1, 0}},
+		},
+		{
+			name:  "descending within a range",
+			query: models.RangeQuery{From: 2, To: 7, Descending: true, Limit: 2},
+			want:  [][]uint64{{7, 6}, {4, 3}, {2}},
+		},
+		{
+			name:  "ascending within a time range",
+			query: models.RangeQuery{FromTime: epochs[3].StartTime, ToTime: epochs[8].StartTime, Limit: 2},
+			want:  [][]uint64{{3, 4}, {6, 7}, {8}},
+		},
+		{
+			name:  "a limit beyond the range",
+			query: models.RangeQuery{From: 8, Limit: 5},
+			want:  [][]uint64{{8, 9}},
+		},
+		{
+			name:  "an empty range",
+			query: models.RangeQuery{From: 5, To: 5, Limit: 5},
+			want:  [][]uint64{{}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query := tt.query
+			var got [][]uint64
+			for len(got) <= len(tt.want) {
+				page, err := repo.GetEpochs(ctx, query)
+				if !assert.NoError(t, err) {
+					return
+				}
+				got = append(got, numbersOf(page.Epochs))
+				if page.Next == "" {
+					break
+				}
+				query.Cursor = page.Next
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func testSlotPages(t *testing.T, repo store.Repository) {
+	ctx := context.Background()
+	epochs := Epochs(0, 2)
+	create(t, repo, epochs...)
+
+	page, err := repo.GetSlots(ctx, models.RangeQuery{From: 2, Limit: 3})
+	assert.NoError(t, err)
+	assert.Equal(t, epochs[0].Slots[2:], normalizeSlots(page.Slots[:2]))
+	assert.Equal(t, epochs[1].Slots[:1], normalizeSlots(page.Slots[2:]))
+	assert.NotEmpty(t, page.Next)
+
+	page, err = repo.GetSlots(ctx, models.RangeQuery{Descending: true, Limit: 2, Cursor: page.Next})
+	assert.NoError(t, err)
+	if assert.Len(t, page.Slots, 2) {
+		assert.Equal(t, uint64(3), page.Slots[0].SlotNumber)
+		assert.Equal(t, uint64(2), page.Slots[1].SlotNumber)
+	}
+
+	page, err = repo.GetSlots(ctx, models.RangeQuery{From: 6})
+	assert.NoError(t, err)
+	assert.Equal(t, epochs[1].Slots[2:], normalizeSlots(page.Slots))
+	assert.Empty(t, page.Next)
+
+	_, err = repo.GetSlots(ctx, models.RangeQuery{Cursor: "not a cursor"})
+	assert.True(t, errors.Is(err, store.ErrInvalidCursor), "GetSlots() of an invalid cursor must return ErrInvalidCursor, got %v", err)
+}
+
+func testNotFound(t *testing.T, repo store.Repository) {
+	ctx := context.Background()
+	create(t, repo, Epochs(0, 1)...)
+
+	_, err := repo.GetEpoch(ctx, 1)
+	assert.True(t, errors.Is(err, store.ErrNotFound), "GetEpoch() must return ErrNotFound, got %v", err)
+	_, err = repo.GetSlot(ctx, slotsPerEpoch)
+	assert.True(t, errors.Is(err, store.ErrNotFound), "GetSlot() must return ErrNotFound, got %v", err)
+	_, err = repo.GetBlock(ctx, rootOf(slotsPerEpoch))
+	assert.True(t, errors.Is(err, store.ErrNotFound), "GetBlock() must return ErrNotFound, got %v", err)
+	_, err = repo.GetBlockByNumber(ctx, slotsPerEpoch)
+	assert.True(t, errors.Is(err, store.ErrNotFound), "GetBlockByNumber() must return ErrNotFound, got %v", err)
+}
+
+// roots & hashes must be 0x prefixed hex in every store, as Postgres stores their bytes
+func testInvalidRoots(t *testing.T, repo store.Repository) {
+	ctx := context.Background()
+	tests := []struct {
+		name   string
+		modify func(e *models.Epoch)
+	}{
+		{"block root", func(e *models.Epoch) {
+			competitor := Block(0, "")
+			competitor.BlockRoot, competitor.Canonical = "0xcompetitor", false
+			e.Slots[0].Candidates = []models.Block{competitor}
+		}},
+		{"parent root", func(e *models.Epoch) { e.Slots[1].Block.ParentRoot = "c0ffee" }},
+		{"transaction hash", func(e *models.Epoch) { e.Slots[2].Block.Receipts[0].TransactionHash = "0xzz" }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			epoch := Epochs(0, 1)[0]
+			tt.modify(&epoch)
+			_, err := repo.Create(ctx, epoch, models.ConflictSkip)
+			assert.Error(t, err)
+			// nothing of the epoch is written
+			_, err = repo.GetEpoch(ctx, 0)
+			assert.True(t, errors.Is(err, store.ErrNotFound), "GetEpoch() of a refused epoch must return ErrNotFound, got %v", err)
+		})
+	}
+
+	if r, ok := repo.(interface {
+		Recanonicalize(context.Context, models.Block) error
+	}); ok {
+		assert.Error(t, r.Recanonicalize(ctx, models.Block{BlockRoot: "0xcompetitor", SlotNumber: 1}))
+	}
+}
+
+func testDemote(t *testing.T, repo store.Repository) {
+	ctx := context.Background()
+	r, ok := repo.(interface {
+		Demote(context.Context, []string) error
+	})
+	if !ok {
+		t.Skip("the repository cannot demote blocks")
+	}
+	create(t, repo, Epochs(0, 2)...)
+	// roots not stored are ignored
+	assert.NoError(t, r.Demote(ctx, []string{rootOf(5), rootOf(7), rootOf(99)}))
+	for slot := uint64(0); slot < 2*slotsPerEpoch; slot++ {
+		block, err := repo.GetBlock(ctx, rootOf(slot))
+		assert.NoError(t, err)
+		assert.Equal(t, slot != 5 && slot != 7, block.Canonical, "canonical of the block of slot %d", slot)
+	}
+	assert.Error(t, r.Demote(ctx, []string{"0xcompetitor"}))
+}
+
+func testEpochStats(t *testing.T, repo store.Repository) {
+	ctx := context.Background()
+	epochs := Epochs(0, 3)
+	for idx := range epochs {
+		epochs[idx].Stats = &models.EpochStats{
+			EpochNumber: epochs[idx].EpochNumber, ProposedSlots: slotsPerEpoch, NoOfTransactions: 2 * slotsPerEpoch,
+			GasUsed: 60_000_000, GasLimit: 120_000_000, AvgGasUsed: 15_000_000, GasUtilisation: 0.5,
+			MinBlockTime: 12, MaxBlockTime: 12, AvgBlockTime: 12,
+		}
+	}
+	create(t, repo, epochs...)
+
+	stats, err := repo.GetEpochStats(ctx, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, []models.EpochStats{*epochs[2].Stats, *epochs[1].Stats}, stats)
+
+	stats, err = repo.GetEpochStats(ctx, 0)
+	assert.NoError(t, err)
+	assert.Len(t, stats, 3)
+
+	stats, err = repo.GetEpochStatsBetween(ctx, 1, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, []models.EpochStats{*epochs[1].Stats, *epochs[2].Stats}, stats)
+
+	stats, err = repo.GetEpochStatsBetween(ctx, 3, 5)
+	assert.NoError(t, err)
+	assert.Empty(t, stats)
+}
+
+// the slots of epochs are spread far apart, as stores may drop whole ranges of slots rather than single epochs,
+// see db.Partitions
+func testRetention(t *testing.T, repo store.Repository) {
+	ctx := context.Background()
+	create(t, repo, Epochs(0, 7)...)
+
+	// too few epochs to drop any
+	assert.NoError(t, repo.KeepOnlyTop5(ctx, 3))
+	got, err := repo.Get(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, got, 7)
+
+	assert.NoError(t, repo.KeepOnlyTop5(ctx, 6))
+	got, err = repo.Get(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []uint64{2, 3, 4, 5, 6}, numbersOf(got))
+
+	// slots, blocks & receipts go along with their epochs
+	_, err = repo.GetSlot(ctx, slotsPerEpoch)
+	assert.True(t, errors.Is(err, store.ErrNotFound), "GetSlot() of a dropped epoch must return ErrNotFound, got %v", err)
+	_, err = repo.GetBlock(ctx, rootOf(slotsPerEpoch))
+	assert.True(t, errors.Is(err, store.ErrNotFound), "GetBlock() of a dropped epoch must return ErrNotFound, got %v", err)
+	block, err := repo.GetBlock(ctx, rootOf(2*slotsPerEpoch))
+	assert.NoError(t, err)
+	assert.Len(t, block.Receipts, 2)
+}
+
+// epochs far enough apart for their slots to be in different partitions of Store
+func testRetentionDistant(t *testing.T, repo store.Repository) {
+	ctx := context.Background()
+	const spread = 300
+	for idx := 0; idx < 7; idx++ {
+		epoch := Epochs(idx*spread, 1)[0]
+		epoch.EpochNumber = uint64(idx)
+		for idxSlot := range epoch.Slots {
+			epoch.Slots[idxSlot].EpochNumber = uint64(idx)
+		}
+		create(t, repo, epoch)
+	}
+
+	// too few epochs to drop any
+	assert.NoError(t, repo.KeepOnlyTop5(ctx, 3))
+	got, err := repo.Get(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []uint64{0, 1, 2, 3, 4, 5, 6}, numbersOf(got))
+
+	assert.NoError(t, repo.KeepOnlyTop5(ctx, 6))
+	got, err = repo.Get(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []uint64{2, 3, 4, 5, 6}, numbersOf(got))
+
+	// slots, blocks & receipts go along with their epochs
+	_, err = repo.GetSlot(ctx, spread*slotsPerEpoch)
+	assert.True(t, errors.Is(err, store.ErrNotFound), "GetSlot() of a dropped epoch must return ErrNotFound, got %v", err)
+	_, err = repo.GetBlock(ctx, rootOf(spread*slotsPerEpoch))
+	assert.True(t, errors.Is(err, store.ErrNotFound), "GetBlock() of a dropped epoch must return ErrNotFound, got %v", err)
+	block, err := repo.GetBlock(ctx, rootOf(2*spread*slotsPerEpoch))
+	assert.NoError(t, err)
+	assert.Len(t, block.Receipts, 2)
+}
+
+func testForkSchedule(t *testing.T, repo store.Repository) {
+	ctx := context.Background()
+	forks := []models.Fork{
+		{Name: "phase0", PreviousVersion: "0x00000000", CurrentVersion: "0x00000000", Epoch: 0},
+		{Name: "altair", PreviousVersion: "0x00000000", CurrentVersion: "0x01000000", Epoch: 74240},
+	}
+	assert.NoError(t, repo.SaveForkSchedule(ctx, forks))
+	forks[1].Epoch = 74241
+	assert.NoError(t, repo.SaveForkSchedule(ctx, forks))
+	assert.NoError(t, repo.SaveForkSchedule(ctx, nil))
+
+	got, err := repo.GetForkSchedule(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, forks, got)
+}
+
+func testWatchedValidators(t *testing.T, repo store.Repository) {
+	ctx := context.Background()
+	got, err := repo.GetWatchedValidators(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{}, got)
+
+	for _, validator := range []string{"42", "0xabc", "42"} {
+		assert.NoError(t, repo.WatchValidator(ctx, validator))
+	}
+	got, err = repo.GetWatchedValidators(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"42", "0xabc"}, got)
+
+	assert.NoError(t, repo.UnwatchValidator(ctx, "42"))
+	assert.NoError(t, repo.UnwatchValidator(ctx, "7"))
+	got, err = repo.GetWatchedValidators(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"0xabc"}, got)
+}
+
+func testValidatorHistory(t *testing.T, repo store.Repository) {
+	ctx := context.Background()
+	activity := []models.ValidatorActivity{
+		{ValidatorIndex: 42, EpochNumber: 1, AttesterDuties: 1, AttestationsIncluded: 1, InclusionDelay: 1, Balance: 32_000_000_000, Reward: 10},
+		{ValidatorIndex: 42, EpochNumber: 2, AttesterDuties: 1, Balance: 32_000_000_010, Reward: -5},
+		{ValidatorIndex: 7, EpochNumber: 2, ProposerDuties: 1, Proposed: 1, Balance: 31_000_000_000},
+	}
+	assert.NoError(t, repo.CreateValidatorActivity(ctx, activity))
+	// late attestations are accounted for by a second write
+	activity[1].AttestationsIncluded, activity[1].InclusionDelay, activity[1].Reward = 1, 3, 8
+	assert.NoError(t, repo.CreateValidatorActivity(ctx, activity[1:2]))
+	assert.NoError(t, repo.CreateValidatorActivity(ctx, nil))
+
+	got, err := repo.GetValidatorHistory(ctx, 42, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []models.ValidatorActivity{activity[1], activity[0]}, got)
+
+	got, err = repo.GetValidatorHistory(ctx, 42, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []models.ValidatorActivity{activity[1]}, got)
+
+	got, err = repo.GetValidatorHistory(ctx, 8, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []models.ValidatorActivity{}, got)
+}
+
+func testContractEvents(t *testing.T, repo store.Repository) {
+	ctx := context.Background()
+	epoch := Epochs(0, 1)[0]
+	for idx := range epoch.Slots[1:] {
+		block := &epoch.Slots[idx+1].Block
+		for logIndex, contract := range []string{"weth", "usdc"} {
+			block.Events = append(block.Events, models.ContractEvent{
+				BlockNumber: block.BlockNumber, BlockRoot: block.BlockRoot, TransactionHash: block.Receipts[logIndex].TransactionHash,
+				LogIndex: uint64(logIndex), Address: fmt.Sprintf("0x%040x", logIndex), Contract: contract, Event: "Transfer",
+				Signature: "Transfer(address,address,uint256)", Args: map[string]interface{}{"from": "0x1", "to": "0x2", "value": "100"},
+			})
+		}
+	}
+	create(t, repo, epoch)
+
+	got, err := repo.GetContractEvents(ctx, models.EventFilter{})
+	assert.NoError(t, err)
+	assert.Len(t, got, 2*(slotsPerEpoch-1))
+
+	got, err = repo.GetContractEvents(ctx, models.EventFilter{Contract: "usdc", FromBlock: 2, Limit: 10})
+	assert.NoError(t, err)
+	assert.Equal(t, []models.ContractEvent{epoch.Slots[2].Block.Events[1], epoch.Slots[3].Block.Events[1]}, got)
+
+	got, err = repo.GetContractEvents(ctx, models.EventFilter{Address: fmt.Sprintf("0X%040X", 0), ToBlock: 2, Limit: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, []models.ContractEvent{epoch.Slots[1].Block.Events[0]}, got)
+
+	got, err = repo.GetContractEvents(ctx, models.EventFilter{Event: "Approval"})
+	assert.NoError(t, err)
+	assert.Equal(t, []models.ContractEvent{}, got)
+}
+
+func testBuilderShare(t *testing.T, repo store.Repository) {
+	ctx := context.Background()
+	epochs := Epochs(0, 2)
+	for idx, builder := range []string{"flashbots", "flashbots", "beaverbuild", "", "titan", "", "", ""} {
+		epochs[idx/slotsPerEpoch].Slots[idx%slotsPerEpoch].Block.Builder = builder
+	}
+	create(t, repo, epochs...)
+
+	got, err := repo.GetBuilderShare(ctx, 0, 0)
+	assert.NoError(t, err)
+	want := []models.BuilderShare{
+		{EpochNumber: 1, Builder: "titan", Blocks: 1, Share: 1},
+		{EpochNumber: 0, Builder: "flashbots", Blocks: 2, Share: 2.0 / 3},
+		{EpochNumber: 0, Builder: "beaverbuild", Blocks: 1, Share: 1.0 / 3},
+	}
+	if assert.Len(t, got, len(want)) {
+		for idx := range want {
+			assert.Equal(t, want[idx].EpochNumber, got[idx].EpochNumber)
+			assert.Equal(t, want[idx].Builder, got[idx].Builder)
+			assert.Equal(t, want[idx].Blocks, got[idx].Blocks)
+			assert.InDelta(t, want[idx].Share, got[idx].Share, 1e-9)
+		}
+	}
+
+	got, err = repo.GetBuilderShare(ctx, 1, 1)
+	assert.NoError(t, err)
+	assert.Len(t, got, 1)
+}
+
+func testExportImport(t *testing.T, repo store.Repository) {
+	ctx := context.Background()
+	// a source with stats, contract events, revisions & a reorg, every one of which must survive
+	source := store.NewMemory()
+	epochs := Epochs(0, 3)
+	epochs[1].Stats = &models.EpochStats{EpochNumber: 1, ProposedSlots: slotsPerEpoch, NoOfTransactions: 2 * slotsPerEpoch,
+		GasUsed: 60_000_000, GasLimit: 120_000_000, AvgGasUsed: 15_000_000, GasUtilisation: 0.5, MinBlockTime: 12, MaxBlockTime: 12, AvgBlockTime: 12}
+	block := &epochs[1].Slots[1].Block
+	block.Events = []models.ContractEvent{{BlockNumber: block.BlockNumber, BlockRoot: block.BlockRoot,
+		TransactionHash: block.Receipts[0].TransactionHash, Address: fmt.Sprintf("0x%040x", 1), Contract: "weth",
+		Event: "Transfer", Signature: "Transfer(address,address,uint256)", Args: map[string]interface{}{"value": "100"}}}
+	competitor := Block(8, rootOf(7))
+	competitor.BlockRoot, competitor.Canonical = "0xc0ffee", false
+	epochs[2].Slots[0].Candidates = []models.Block{competitor}
+	create(t, source, epochs...)
+	epochs[0].Slots[2].Block.GasUsed++
+	_, err := source.Create(ctx, epochs[0], models.ConflictVersion)
+	assert.NoError(t, err)
+
+	var exported bytes.Buffer
+	trailer, err := store.Export(ctx, source, &exported, models.RangeQuery{})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, trailer.Epochs)
+	_, err = store.Import(ctx, repo, bytes.NewReader(exported.Bytes()), models.RangeQuery{}, models.ConflictSkip)
+	assert.NoError(t, err)
+
+	// the export of the imported epochs matches the original line by line, the header aside
+	var reexported bytes.Buffer
+	_, err = store.Export(ctx, repo, &reexported, models.RangeQuery{})
+	assert.NoError(t, err)
+	want, got := bytes.Split(exported.Bytes(), []byte{'\n'}), bytes.Split(reexported.Bytes(), []byte{'\n'})
+	if assert.Len(t, got, len(want)) {
+		for idx := 1; idx < len(want); idx++ {
+			assert.Equal(t, string(want[idx]), string(got[idx]), "line %d", idx+1)
+		}
+	}
+
+	// a range is exported on its own
+	exported.Reset()
+	trailer, err = store.Export(ctx, repo, &exported, models.RangeQuery{From: 1, To: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, trailer.Epochs)
+}
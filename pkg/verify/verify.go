@@ -0,0 +1,199 @@
+package verify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"indexer/pkg/models"
+	"indexer/pkg/store"
+	"sort"
+	"strconv"
+	"time"
+)
+
+type Kind string
+
+const (
+	// a canonical block of the chain is not stored
+	Gap Kind = "gap"
+	// a stored block or slot differs from the chain in a field
+	Mismatch Kind = "mismatch"
+	// a block stored as canonical is not on the chain
+	Orphan Kind = "orphan"
+)
+
+// represents a difference between the stored slots & blocks of an epoch & the chain
+type Finding struct {
+	Kind  Kind   `json:"kind"`
+	Epoch uint64 `json:"epoch"`
+	Slot  uint64 `json:"slot"`
+	// the differing field of a Mismatch, named as in the JSON of models.Slot & models.Block
+	Field  string `json:"field,omitempty"`
+	Stored string `json:"stored,omitempty"`
+	Chain  string `json:"chain,omitempty"`
+}
+
+// fmt.Stringer implementation of a Finding
+func (f Finding) String() string {
+	b, _ := json.Marshal(f)
+	return string(b)
+}
+
+// source of the canonical epochs of the chain, see indexer.BeaconChain
+type Chain interface {
+	Epoch(ctx context.Context, number uint64) (*models.Epoch, error)
+}
+
+// implemented by repositories able to make a chain canonical below a newer head & to demote single blocks, e.g.
+// store.Store
+type recanonicalizer interface {
+	Recanonicalize(context.Context, models.Block) error
+	Demote(ctx context.Context, roots []string) error
+}
+
+// every store implements recanonicalizer
+var (
+	_ recanonicalizer = &store.Store{}
+	_ recanonicalizer = &store.SQLite{}
+	_ recanonicalizer = &store.Memory{}
+)
+
+// compares what is stored with the chain epoch by epoch & repairs it by re-indexing
+type Verifier struct {
+	chain Chain
+	repo  store.Repository
+	// optional, completes re-indexed epochs with execution layer data before they are stored
+	enrich func(context.Context, *models.Epoch)
+}
+
+func New(chain Chain, repo store.Repository, enrich func(context.Context, *models.Epoch)) *Verifier {
+	return &Verifier{chain, repo, enrich}
+}
+
+// compares the stored slots & blocks of an epoch with the chain, on repair an epoch with findings is re-indexed,
+// stored rows are overwritten & blocks off the chain are no longer canonical, wherever they are in the epoch
+func (v *Verifier) Epoch(ctx context.Context, number uint64, repair bool) ([]Finding, error) {
+	onChain, err := v.chain.Epoch(ctx, number)
+	if err != nil {
+		return nil, fmt.Errorf("chain.Epoch() failed, err: %v", err.Error())
+	}
+	stored, err := v.repo.GetEpoch(ctx, number)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		return nil, fmt.Errorf("repo.GetEpoch() failed, err: %v", err.Error())
+	}
+	findings := Compare(stored, *onChain)
+	if !repair || len(findings) == 0 {
+		return findings, nil
+	}
+
+	if v.enrich != nil {
+		v.enrich(ctx, onChain)
+	}
+	_, err = v.repo.Create(ctx, *onChain, models.ConflictOverwrite)
+	if err != nil {
+		return findings, fmt.Errorf("repo.Create() failed, err: %v", err.Error())
+	}
+	// Create leaves canonical flags alone below a newer head & past the chain's last block, which the chain overrules
+	if r, ok := v.repo.(recanonicalizer); ok {
+		for idx := len(onChain.Slots) - 1; idx >= 0; idx-- {
+			if head := onChain.Slots[idx].Block; head.BlockRoot != "" {
+				err = r.Recanonicalize(ctx, head)
+				if err != nil {
+					return findings, fmt.Errorf("repo.Recanonicalize() failed, err: %v", err.Error())
+				}
+				break
+			}
+		}
+		if orphans := orphansOf(stored, *onChain); len(orphans) > 0 {
+			err = r.Demote(ctx, orphans)
+			if err != nil {
+				return findings, fmt.Errorf("repo.Demote() failed, err: %v", err.Error())
+			}
+		}
+	}
+	return findings, nil
+}
+
+// roots of the blocks stored as canonical which are not on the chain, wherever they are in the epoch
+func orphansOf(stored, chain models.Epoch) []string {
+	onChain := make(map[string]bool)
+	for _, slot := range chain.Slots {
+		onChain[slot.Block.BlockRoot] = true
+	}
+	var roots []string
+	for _, slot := range stored.Slots {
+		if root := slot.Block.BlockRoot; root != "" && !onChain[root] {
+			roots = append(roots, root)
+		}
+	}
+	return roots
+}
+
+// lists the differences between the slots & canonical blocks of a stored epoch & the same epoch of the chain,
+// ordered by slot, stored is the zero Epoch when it is not stored at all
+func Compare(stored, chain models.Epoch) []Finding {
+	storedSlots := make(map[uint64]models.Slot)
+	for _, slot := range stored.Slots {
+		storedSlots[slot.SlotNumber] = slot
+	}
+	chainSlots := make(map[uint64]models.Slot)
+	var numbers []uint64
+	for _, slot := range chain.Slots {
+		chainSlots[slot.SlotNumber] = slot
+		numbers = append(numbers, slot.SlotNumber)
+	}
+	for _, slot := range stored.Slots {
+		if _, ok := chainSlots[slot.SlotNumber]; !ok {
+			numbers = append(numbers, slot.SlotNumber)
+		}
+	}
+	sort.Slice(numbers, func(i, j int) bool { return numbers[i] < numbers[j] })
+
+	var findings []Finding
+	for _, number := range numbers {
+		s, c := storedSlots[number], chainSlots[number]
+		finding := Finding{Epoch: chain.EpochNumber, Slot: number}
+		switch {
+		case s.Block.BlockRoot == "" && c.Block.BlockRoot == "":
+			// the slot of blocks which were all reorged out
+		case s.Block.BlockRoot == "":
+			finding.Kind, finding.Chain = Gap, c.Block.BlockRoot
+			findings = append(findings, finding)
+		case s.Block.BlockRoot != c.Block.BlockRoot:
+			finding.Kind, finding.Stored, finding.Chain = Orphan, s.Block.BlockRoot, c.Block.BlockRoot
+			findings = append(findings, finding)
+		default:
+			for _, f := range fields(s, c) {
+				if f.stored != f.chain {
+					finding.Kind, finding.Field, finding.Stored, finding.Chain = Mismatch, f.name, f.stored, f.chain
+					findings = append(findings, finding)
+				}
+			}
+		}
+	}
+	return findings
+}
+
+// the compared fields of a slot & its block, rendered as text
+func fields(s, c models.Slot) []struct{ name, stored, chain string } {
+	number := func(n uint64) string { return strconv.FormatUint(n, 10) }
+	// times are compared as instants, whatever their location
+	instant := func(t time.Time) string { return t.UTC().Format(time.RFC3339Nano) }
+	sb, cb := s.Block, c.Block
+	return []struct{ name, stored, chain string }{
+		{"epochNumber", number(s.EpochNumber), number(c.EpochNumber)},
+		{"startTime", instant(s.StartTime), instant(c.StartTime)},
+		{"endTime", instant(s.EndTime), instant(c.EndTime)},
+		{"parentRoot", sb.ParentRoot, cb.ParentRoot},
+		{"stateRoot", sb.StateRoot, cb.StateRoot},
+		{"version", sb.Version, cb.Version},
+		{"blockNumber", number(sb.BlockNumber), number(cb.BlockNumber)},
+		{"gasLimit", number(sb.GasLimit), number(cb.GasLimit)},
+		{"gasUsed", number(sb.GasUsed), number(cb.GasUsed)},
+		{"noOfTransactions", strconv.Itoa(sb.NoOfTransactions), strconv.Itoa(cb.NoOfTransactions)},
+		{"created_at", instant(sb.CreatedAt), instant(cb.CreatedAt)},
+		{"feeRecipient", sb.FeeRecipient, cb.FeeRecipient},
+		{"extraData", sb.ExtraData, cb.ExtraData},
+	}
+}
@@ -0,0 +1,181 @@
+package verify
+
+import (
+	"context"
+	"fmt"
+	"indexer/pkg/models"
+	"indexer/pkg/store"
+	"indexer/pkg/store/storetest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func root(slot uint64) string {
+	return fmt.Sprintf("0x%064x", slot)
+}
+
+func TestCompare(t *testing.T) {
+	chain := storetest.Epochs(1, 1)[0]
+	tests := []struct {
+		name   string
+		stored func() models.Epoch
+		want   []Finding
+	}{
+		{
+			name:   "an epoch stored as it is on chain",
+			stored: func() models.Epoch { return storetest.Epochs(1, 1)[0] },
+			want:   nil,
+		},
+		{
+			name:   "an epoch not stored at all",
+			stored: func() models.Epoch { return models.Epoch{} },
+			want: []Finding{
+				{Kind: Gap, Epoch: 1, Slot: 4, Chain: root(4)}, {Kind: Gap, Epoch: 1, Slot: 5, Chain: root(5)},
+				{Kind: Gap, Epoch: 1, Slot: 6, Chain: root(6)}, {Kind: Gap, Epoch: 1, Slot: 7, Chain: root(7)},
+			},
+		},
+		{
+			name: "fields compared as instants & numbers",
+			stored: func() models.Epoch {
+				e := storetest.Epochs(1, 1)[0]
+				e.Slots[0].StartTime = e.Slots[0].StartTime.In(time.FixedZone("CET", 3600))
+				e.Slots[1].Block.GasUsed++
+				e.Slots[2].Block.StateRoot = root(99)
+				return e
+			},
+			want: []Finding{
+				{Kind: Mismatch, Epoch: 1, Slot: 5, Field: "gasUsed", Stored: "15000001", Chain: "15000000"},
+				{Kind: Mismatch, Epoch: 1, Slot: 6, Field: "stateRoot", Stored: root(99), Chain: root(6)},
+			},
+		},
+		{
+			name: "blocks off the chain",
+			stored: func() models.Epoch {
+				e := storetest.Epochs(1, 1)[0]
+				e.Slots[3].Block.BlockRoot = root(99)
+				// a slot missed on chain
+				extra := e.Slots[3]
+				extra.SlotNumber, extra.Block.BlockRoot = 8, root(8)
+				e.Slots = append(e.Slots, extra)
+				return e
+			},
+			want: []Finding{
+				{Kind: Orphan, Epoch: 1, Slot: 7, Stored: root(99), Chain: root(7)},
+				{Kind: Orphan, Epoch: 1, Slot: 8, Stored: root(8)},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Compare(tt.stored(), chain))
+		})
+	}
+}
+
+// serves its epochs as the beacon node would
+type chain []models.Epoch
+
+func (c chain) Epoch(ctx context.Context, number uint64) (*models.Epoch, error) {
+	for _, e := range c {
+		if e.EpochNumber == number {
+			return &e, nil
+		}
+	}
+	return nil, fmt.Errorf("epoch %d not found", number)
+}
+
+func TestVerifier_Epoch(t *testing.T) {
+	ctx := context.Background()
+	repo := store.NewMemory()
+	stored := storetest.Epochs(0, 3)
+	// slot 5 is missing, slot 6 differs & slot 7 holds a block off the chain, below the newer head of epoch 2
+	stored[1].Slots[2].Block.GasUsed = 1
+	orphan := storetest.Block(7, root(6))
+	orphan.BlockRoot, orphan.Receipts = root(99), nil
+	stored[1].Slots[3].Block = orphan
+	stored[1].Slots = append(stored[1].Slots[:1], stored[1].Slots[2:]...)
+	for _, e := range stored {
+		_, err := repo.Create(ctx, e, models.ConflictSkip)
+		assert.NoError(t, err)
+	}
+	want := []Finding{
+		{Kind: Gap, Epoch: 1, Slot: 5, Chain: root(5)},
+		{Kind: Mismatch, Epoch: 1, Slot: 6, Field: "gasUsed", Stored: "1", Chain: "15000000"},
+		{Kind: Orphan, Epoch: 1, Slot: 7, Stored: root(99), Chain: root(7)},
+	}
+
+	v := New(chain(storetest.Epochs(0, 3)), repo, nil)
+	findings, err := v.Epoch(ctx, 0, false)
+	assert.NoError(t, err)
+	assert.Empty(t, findings)
+	findings, err = v.Epoch(ctx, 1, false)
+	assert.NoError(t, err)
+	assert.Equal(t, want, findings)
+
+	// repairs report what they repaired
+	findings, err = v.Epoch(ctx, 1, true)
+	assert.NoError(t, err)
+	assert.Equal(t, want, findings)
+	findings, err = v.Epoch(ctx, 1, false)
+	assert.NoError(t, err)
+	assert.Empty(t, findings)
+	block, err := repo.GetBlock(ctx, root(99))
+	assert.NoError(t, err)
+	assert.False(t, block.Canonical, "the orphan must no longer be canonical")
+}
+
+func TestVerifier_Epoch_orphans(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name string
+		// slots of epoch 1 missed on chain, whose stored blocks are orphans
+		missed []uint64
+	}{
+		{name: "past the last block of the chain", missed: []uint64{7}},
+		{name: "in an epoch without blocks on chain", missed: []uint64{4, 5, 6, 7}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := store.NewMemory()
+			for _, e := range storetest.Epochs(0, 3) {
+				_, err := repo.Create(ctx, e, models.ConflictSkip)
+				assert.NoError(t, err)
+			}
+			onChain := storetest.Epochs(0, 3)
+			var slots []models.Slot
+			for _, slot := range onChain[1].Slots {
+				if !contains(tt.missed, slot.SlotNumber) {
+					slots = append(slots, slot)
+				}
+			}
+			onChain[1].Slots = slots
+
+			v := New(chain(onChain), repo, nil)
+			findings, err := v.Epoch(ctx, 1, true)
+			assert.NoError(t, err)
+			assert.Len(t, findings, len(tt.missed))
+			findings, err = v.Epoch(ctx, 1, false)
+			assert.NoError(t, err)
+			assert.Empty(t, findings)
+			for _, slot := range tt.missed {
+				block, err := repo.GetBlock(ctx, root(slot))
+				assert.NoError(t, err)
+				assert.False(t, block.Canonical, "the orphan of slot %d must no longer be canonical", slot)
+			}
+			head, err := repo.GetBlock(ctx, root(11))
+			assert.NoError(t, err)
+			assert.True(t, head.Canonical, "the newer head must stay canonical")
+		})
+	}
+}
+
+func contains(numbers []uint64, n uint64) bool {
+	for _, number := range numbers {
+		if number == n {
+			return true
+		}
+	}
+	return false
+}